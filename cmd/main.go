@@ -1,40 +1,125 @@
 package main
 
 import (
+	"expvar"
 	"log"
+	"net/http"
+	"os"
 
 	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/cache"
 	"olbcloud.com/webapi/internal/config"
 	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
 	"olbcloud.com/webapi/internal/database/mongodb"
 	"olbcloud.com/webapi/internal/database/postgresql"
-	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/database/sqlite"
+	"olbcloud.com/webapi/internal/grpcapi"
 	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/tenant"
 )
 
 func main() {
 	cfg := config.LoadConfig()
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(cfg, os.Args[2:])
+		case "export":
+			runExport(cfg, os.Args[2:])
+		case "import":
+			runImport(cfg, os.Args[2:])
+		case "migrate-data":
+			runMigrateData(cfg, os.Args[2:])
+		default:
+			log.Fatalf("Unknown command %q. Available commands: migrate, export, import, migrate-data", os.Args[1])
+		}
+		return
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	if cfg.AutoMigrate {
+		migrateUp(db)
+	}
+
+	if sp, ok := db.(database.StatsProvider); ok {
+		expvar.Publish("db_pool", expvar.Func(func() any { return sp.PoolStats() }))
+	}
+
+	resolver, err := tenant.NewResolver(cfg.Tenants)
+	if err != nil {
+		log.Fatal("Invalid tenants: ", err)
+	}
+	if _, ok := db.(database.Outbox); cfg.DBOptions.Outbox && !ok {
+		log.Println("Warning: DB_OUTBOX is only supported by postgresql and mongodb, notifying observers directly")
+	}
+
+	var postCache cache.Cache
+	switch cfg.CacheBackend {
+	case "":
+	case "memory":
+		postCache = cache.NewLRU(cfg.CacheSize)
+	case "redis":
+		redis := cache.NewRedis(cache.RedisOptions{Addr: cfg.RedisAddr, Password: cfg.RedisPassword, DB: cfg.RedisDB})
+		defer redis.Close()
+		postCache = redis
+	default:
+		log.Fatal("Invalid CACHE_BACKEND. Must be empty, 'memory' or 'redis'")
+	}
+
+	// gRPC has no notion of hosts or paths, so it serves the default
+	// tenant, or the first one when there is no default.
+	sites := make(map[string]http.Handler, len(cfg.Tenants))
+	var onShutdown []func()
+	var grpcPosts services.PostService
+	for _, t := range cfg.Tenants {
+		s := newSite(cfg, db.ForTenant(t.ID), t, postCache)
+		sites[t.ID] = s.handler
+		onShutdown = append(onShutdown, s.broker.Close, s.hub.Close)
+		if grpcPosts == nil || t.ID == database.DefaultTenant {
+			grpcPosts = s.posts
+		}
+	}
+
+	// The gRPC API can write posts, so it is only served with a token.
+	if cfg.GRPCAuthToken == "" {
+		log.Println("Warning: GRPC_AUTH_TOKEN is not set, not starting the gRPC API")
+	} else {
+		grpcServer := grpcapi.NewServer(grpcPosts, grpcapi.Options{AuthToken: cfg.GRPCAuthToken})
+		go grpcapi.StartServer(grpcServer, cfg.GRPCPort)
+	}
+
+	apiserver.StartServer(resolver.Handler(sites), cfg, onShutdown...)
+}
+
+// openDB connects to the backend selected by DB_TYPE.
+func openDB(cfg *config.Config) database.DB {
+	return openDBType(cfg, cfg.DBType)
+}
+
+// openDBType connects to the given backend using its settings from cfg.
+func openDBType(cfg *config.Config, dbType string) database.DB {
 	var db database.DB
 	var err error
 
-	switch cfg.DBType {
+	switch dbType {
 	case "postgresql":
-		db, err = postgresql.NewPostgreSQL(cfg.PostgresURL)
+		db, err = postgresql.NewPostgreSQL(cfg.PostgresURL, cfg.DBOptions)
 	case "mongodb":
-		db, err = mongodb.NewMongoDB(cfg.MongoDBURL)
+		db, err = mongodb.NewMongoDB(cfg.MongoDBURL, cfg.MongoDBDatabase, cfg.DBOptions)
+	case "memory":
+		db, err = memory.NewMemory(cfg.MemorySnapshotPath)
+	case "sqlite":
+		db, err = sqlite.NewSQLite(cfg.SQLitePath)
 	default:
-		log.Fatal("Invalid DB_TYPE. Must be 'postgresql' or 'mongodb'")
+		log.Fatal("Invalid DB_TYPE. Must be 'postgresql', 'mongodb', 'memory' or 'sqlite'")
 	}
 
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
-	defer db.Close()
-
-	postService := services.NewPostService(db)
-	hd := handlers.NewHandlers(postService)
-
-	mux := apiserver.NewServer(hd)
-	apiserver.StartServer(mux)
+	return db
 }
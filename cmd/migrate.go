@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"olbcloud.com/webapi/internal/config"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/migrate"
+)
+
+const migrateUsage = "Usage: blog-api migrate up|down [steps]|status"
+
+// runMigrate implements `blog-api migrate up|down [steps]|status`.
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		migrateUp(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatal(migrateUsage)
+			}
+			steps = n
+		}
+		n, err := migrator(db).Down(context.Background(), steps)
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		log.Printf("Reverted %d migration(s)", n)
+	case "status":
+		status, err := migrator(db).Status(context.Background())
+		if err != nil {
+			log.Fatal("Failed to read migration status: ", err)
+		}
+		printStatus(status)
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
+
+// migrateUp applies all pending migrations. Backends without versioned
+// migrations create their schema on connect and are skipped.
+func migrateUp(db database.DB) {
+	if _, ok := db.(database.Migrater); !ok {
+		log.Printf("DB_TYPE does not use migrations, skipping")
+		return
+	}
+
+	n, err := migrator(db).Up(context.Background())
+	if err != nil {
+		log.Fatal("Migration failed: ", err)
+	}
+	log.Printf("Applied %d migration(s)", n)
+}
+
+func migrator(db database.DB) *migrate.Migrator {
+	m, ok := db.(database.Migrater)
+	if !ok {
+		log.Fatal("DB_TYPE does not support migrations")
+	}
+
+	mig, err := m.Migrator()
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+	return mig
+}
+
+func printStatus(status migrate.Status) {
+	fmt.Printf("Current version: %d", status.Version)
+	if status.Dirty {
+		fmt.Print(" (dirty)")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, m := range status.Migrations {
+		fmt.Fprintf(w, "%d\t%s\t%t\n", m.Version, m.Name, m.Applied)
+	}
+	w.Flush()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/audit"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/cache"
+	"olbcloud.com/webapi/internal/config"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/oidc"
+	"olbcloud.com/webapi/internal/outbox"
+	"olbcloud.com/webapi/internal/presence"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/sitemap"
+	"olbcloud.com/webapi/internal/stream"
+	"olbcloud.com/webapi/internal/tenant"
+	"olbcloud.com/webapi/internal/webhooks"
+)
+
+// site is everything that serves one tenant.
+type site struct {
+	handler http.Handler
+	posts   services.PostService
+	broker  *stream.Broker
+	hub     *presence.Hub
+}
+
+// newSite wires the services of tenant t on top of db, its view of the
+// database. postCache, if not nil, is shared by every tenant.
+func newSite(cfg *config.Config, db database.DB, t tenant.Tenant, postCache cache.Cache) site {
+	siteMap := sitemap.New(t.BaseURL, db.EachPost)
+	// local observers serve this process only; the webhook dispatcher
+	// queues deliveries shared by every server.
+	local := []services.Observer{func(e services.Event) error {
+		siteMap.Set(e.Post.ID, e.Post.UpdatedAt)
+		return nil
+	}}
+	presenceOpts := cfg.Presence
+	if presenceOpts.AllowedOrigins == nil {
+		presenceOpts.AllowedOrigins = t.CORSOrigins
+	}
+	hub := presence.New(presenceOpts)
+	local = append(local, hub.Observe)
+	var shared []services.Observer
+	var dispatcher *webhooks.Dispatcher
+	if store, ok := db.(database.WebhookStore); ok {
+		dispatcher = webhooks.New(store, cfg.Webhooks)
+		shared = append(shared, dispatcher.Observe)
+		go dispatcher.Run(context.Background())
+	}
+	// With the outbox the backend records every change, instead of the
+	// service reporting it after the write. The relay hands each event to
+	// the shared observers until they take it and marks it dispatched,
+	// while every server follows the outbox for the local ones, which the
+	// relay of another server would not reach. The event stream then
+	// resumes from the outbox too, so its event IDs hold across restarts
+	// and servers.
+	var broker *stream.Broker
+	var observers []services.Observer
+	if store, ok := db.(database.Outbox); ok && cfg.DBOptions.Outbox {
+		broker = stream.New(stream.NewOutboxHistory(store, cfg.Stream.HistorySize), cfg.Stream)
+		go outbox.NewRelay(store, outbox.Observers(shared...), cfg.Outbox).Run(context.Background())
+		go outbox.NewFollower(store, outbox.Publishers(outbox.Observers(local...), broker), cfg.Outbox).Run(context.Background())
+	} else {
+		broker = stream.New(nil, cfg.Stream)
+		observers = slices.Concat(local, shared, []services.Observer{broker.Observe})
+	}
+	postService := services.NewPostService(db, observers...)
+	if postCache != nil {
+		postService = services.NewCachedPostService(postService, cache.Prefixed(postCache, t.ID+":"), cfg.CacheTTL)
+	}
+
+	feed := cfg.Feed
+	feed.Title = t.Title
+	feed.Description = t.Description
+	feed.BaseURL = t.BaseURL
+
+	hd := handlers.NewHandlers(postService)
+	hd.Feed = feed
+	hd.Sitemap = siteMap
+	hd.Robots = sitemap.Robots(t.BaseURL, cfg.RobotsDisallow)
+	hd.GraphQL = cfg.GraphQL
+	hd.Webhooks = dispatcher
+	hd.Stream = broker
+	hd.Presence = hub
+	hd.Auth = &auth.Authenticator{AdminToken: cfg.AdminToken, Anonymous: cfg.AnonymousScopes}
+	if store, ok := db.(database.APIKeyStore); ok {
+		hd.Auth.Keys = auth.NewKeys(store)
+		hd.Auth.Keys.Describe = audit.APIKeys
+	}
+	// Each tenant keeps its own sessions, bound to it and to its prefix,
+	// and its own callback at the provider.
+	sessions := cfg.Sessions
+	sessions.Tenant = t.ID
+	sessions.Path = t.PathPrefix
+	hd.Auth.Sessions = auth.NewSessions(sessions)
+	if cfg.OIDC.Issuer != "" {
+		login := cfg.OIDC
+		login.BaseURL = t.BaseURL
+		hd.OIDC = oidc.New(login)
+	}
+	if auditLog, ok := db.(database.AuditLog); ok {
+		hd.Audit = auditLog
+	}
+
+	mux := apiserver.NewServer(hd, apiserver.Options{
+		MaxBodyBytes:       cfg.MaxBodyBytes,
+		MaxStreamBodyBytes: cfg.MaxStreamBodyBytes,
+		V1Deprecation:      cfg.V1Deprecation,
+		V1Sunset:           cfg.V1Sunset,
+	})
+	// Browsers signed in with this tenant's session cookie must send its
+	// CSRF token.
+	handler := apiserver.CSRFMiddleware(mux, apiserver.CSRFOptions{
+		Secret: sessions.Secret,
+		Path:   sessions.Path,
+		Secure: sessions.Secure,
+		Exempt: cfg.CSRFExempt,
+	})
+	return site{
+		handler: apiserver.CORS(handler, t.CORSOrigins),
+		posts:   postService,
+		broker:  broker,
+		hub:     hub,
+	}
+}
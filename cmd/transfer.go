@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/config"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// runExport implements `blog-api export [-tenant id] [-o file]`.
+func runExport(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("o", "", "write to `file` instead of stdout")
+	tenantID := fs.String("tenant", database.DefaultTenant, "export the posts of tenant `id`")
+	fs.Parse(args)
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal("Failed to create export file: ", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := services.NewPostService(db.ForTenant(*tenantID)).ExportPosts(context.Background(), bw); err != nil {
+		log.Fatal("Export failed: ", err)
+	}
+	if err := bw.Flush(); err != nil {
+		log.Fatal("Export failed: ", err)
+	}
+}
+
+// runImport implements `blog-api import [-tenant id] [-mode insert|upsert] [-atomic] [-actor user] [file]`,
+// reading stdin when no file is given.
+func runImport(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	mode := fs.String("mode", string(database.ImportInsert), "insert or upsert")
+	atomic := fs.Bool("atomic", false, "import all lines or none")
+	tenantID := fs.String("tenant", database.DefaultTenant, "import into tenant `id`")
+	actor := fs.String("actor", os.Getenv("USER"), "name `user` in the audit log")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			log.Fatal("Failed to open import file: ", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	ctx := context.Background()
+	if *actor != "" {
+		ctx = auth.NewContext(ctx, auth.Principal{User: *actor})
+	}
+	result, err := services.NewPostService(db.ForTenant(*tenantID)).ImportPosts(ctx, r, services.ImportOptions{
+		Mode:   database.ImportMode(*mode),
+		Atomic: *atomic,
+	})
+	if err != nil {
+		log.Fatal("Import failed: ", err)
+	}
+
+	for _, e := range result.Errors {
+		log.Printf("line %d: %s", e.Line, e.Error)
+	}
+	log.Printf("Imported %d post(s), %d error(s)", result.Imported, len(result.Errors))
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,27 +1,90 @@
 package apiserver
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/rs/cors"
+	"olbcloud.com/webapi/internal/config"
 	"olbcloud.com/webapi/internal/handlers"
 )
 
-func NewServer(hd handlers.Handlers) *http.ServeMux {
+// Options configures the server. Zero values use the defaults.
+type Options struct {
+	// MaxBodyBytes caps JSON request bodies, which are read whole to be
+	// validated.
+	MaxBodyBytes int64
+	// MaxStreamBodyBytes caps bodies the handler streams, such as an NDJSON
+	// import.
+	MaxStreamBodyBytes int64
+	// V1Deprecation and V1Sunset are announced on every v1 response.
+	V1Deprecation time.Time
+	V1Sunset      time.Time
+}
+
+const (
+	DefaultMaxBodyBytes       = 1 << 20
+	DefaultMaxStreamBodyBytes = 64 << 20
+)
+
+func (o Options) withDefaults() Options {
+	if o.MaxBodyBytes <= 0 {
+		o.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if o.MaxStreamBodyBytes <= 0 {
+		o.MaxStreamBodyBytes = DefaultMaxStreamBodyBytes
+	}
+	if o.V1Deprecation.IsZero() {
+		o.V1Deprecation = DefaultV1Deprecation
+	}
+	if o.V1Sunset.IsZero() {
+		o.V1Sunset = DefaultV1Sunset
+	}
+	return o
+}
+
+// NewServer registers every route from Routes plus /openapi.json and /docs,
+// which describe them. Each request gets an ID, then callers are
+// authenticated against hd.Auth and checked for the scopes each route
+// declares, then requests are validated against the same document before
+// they reach a handler.
+func NewServer(hd handlers.Handlers, opts Options) *http.ServeMux {
 	mux := http.NewServeMux()
+	opts = opts.withDefaults()
 
-	mux.HandleFunc("GET /posts", hd.GetPostsHandler)
-	mux.HandleFunc("POST /posts", hd.CreatePostHandler)
-	mux.HandleFunc("PUT /posts/{id}", hd.UpdatePostHandler)
-	mux.HandleFunc("GET /posts/{id}", hd.GetPostByIDHandler)
+	routes := Routes(hd, opts)
+	spec := Spec(routes)
+	for _, r := range slices.Concat(routes, docRoutes(spec)) {
+		mux.Handle(r.Pattern(), identifyRequest(authenticate(validateRequest(r.Handler, r.Doc, spec.Components, opts), r.Doc, hd.Auth)))
+	}
 
 	return mux
 }
 
-func StartServer(mux *http.ServeMux) {
+// CORS lets browsers on origins call h; "*" allows any origin.
+func CORS(h http.Handler, origins []string) http.Handler {
+	c := cors.New(cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", RequestIDHeader, CSRFHeader},
+		ExposedHeaders:   []string{"Location", "Deprecation", "Sunset", "Link", RequestIDHeader},
+		AllowCredentials: true,
+	})
+	return c.Handler(h)
+}
+
+// StartServer serves h until SIGINT or SIGTERM, then stops accepting
+// connections and waits up to cfg.ShutdownTimeout for requests in flight.
+// The onShutdown functions run as shutdown starts, to end long-lived
+// responses such as event streams that would otherwise hold it up.
+func StartServer(h http.Handler, cfg *config.Config, onShutdown ...func()) {
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
 		port = "8080"
@@ -30,16 +93,43 @@ func StartServer(mux *http.ServeMux) {
 	addr := fmt.Sprintf(":%s", port)
 	log.Printf("Starting server on %s\n", addr)
 
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-	})
+	handler := LogMiddleware(
+		SecurityMiddleware(
+			CompressMiddleware(
+				CacheMiddleware(h, cfg.HTTPCacheControl),
+				cfg.CompressionMinSize, cfg.CompressionZstd,
+			),
+			SecurityOptions{
+				HSTSMaxAge:            cfg.HSTSMaxAge,
+				ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+				FrameAncestors:        cfg.FrameAncestors,
+				ReferrerPolicy:        cfg.ReferrerPolicy,
+			},
+		),
+	)
+
+	srv := &http.Server{Addr: addr, Handler: handler}
+	for _, f := range onShutdown {
+		srv.RegisterOnShutdown(f)
+	}
 
-	handler := LogMiddleware(c.Handler(mux))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := http.ListenAndServe(addr, handler); err != nil {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Println("Shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown: %v", err)
 	}
 }
@@ -0,0 +1,136 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/openapi"
+)
+
+// apiKeyScheme names the security scheme routes list the scopes they need
+// under.
+const apiKeyScheme = "apiKey"
+
+// sessionScheme is the cookie of users signed in through the identity
+// provider. Their scopes follow from their roles.
+const sessionScheme = "session"
+
+var securitySchemes = map[string]openapi.SecurityScheme{
+	apiKeyScheme: {
+		Type: "apiKey", In: "header", Name: "Authorization",
+		Description: "\"ApiKey <key>\", with a key from POST /api-keys. Callers without credentials get the scopes " +
+			"the server grants anonymous callers, by default posts:read and posts:write.",
+	},
+	"adminToken": {
+		Type: "http", Scheme: "bearer",
+		Description: "The operator's admin token, which holds every scope and may manage every user's API keys.",
+	},
+	sessionScheme: {
+		Type: "apiKey", In: "cookie", Name: auth.SessionCookie,
+		Description: "Set by signing in at GET /auth/oidc/login. Admins and editors hold every scope, other users posts:read. " +
+			"POST, PUT and DELETE requests must repeat the " + CSRFCookie + " cookie in the " + CSRFHeader + " header.",
+	},
+}
+
+// requires declares that an operation needs scope.
+func requires(scope string) []openapi.SecurityRequirement {
+	return []openapi.SecurityRequirement{{apiKeyScheme: {scope}}, {"adminToken": {}}, {sessionScheme: {}}}
+}
+
+// requiresUser declares that an operation needs an authenticated user
+// rather than a scope.
+var requiresUser = []openapi.SecurityRequirement{{"adminToken": {}}, {sessionScheme: {}}}
+
+// adminOnly refuses callers that are not admins. Routes using it declare
+// requiresUser.
+func adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := auth.FromContext(r.Context())
+		if !ok || p.Anonymous() {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, r, http.StatusUnauthorized, "authentication required", nil)
+			return
+		}
+		if !p.IsAdmin() {
+			writeError(w, r, http.StatusForbidden, "only admins may do this", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiredScopes returns the scopes op lists under the API key scheme.
+func requiredScopes(op *openapi.Operation) []string {
+	if op == nil {
+		return nil
+	}
+	var scopes []string
+	for _, req := range op.Security {
+		scopes = append(scopes, req[apiKeyScheme]...)
+	}
+	return scopes
+}
+
+// authenticate puts the caller's principal in the request context and
+// refuses callers without the scopes op requires. Bad credentials are
+// refused on every route. Without an authenticator every request passes
+// and carries no principal.
+func authenticate(next http.Handler, op *openapi.Operation, a *auth.Authenticator) http.Handler {
+	if a == nil {
+		return next
+	}
+	scopes := requiredScopes(op)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			w.Header().Set("WWW-Authenticate", "ApiKey")
+			writeError(w, r, http.StatusUnauthorized, "invalid credentials", nil)
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "could not check credentials", nil)
+			return
+		}
+
+		if i := slices.IndexFunc(scopes, func(s string) bool { return !p.Can(s) }); i >= 0 {
+			if p.Anonymous() {
+				w.Header().Set("WWW-Authenticate", "ApiKey")
+				writeError(w, r, http.StatusUnauthorized, "authentication required", nil)
+				return
+			}
+			writeError(w, r, http.StatusForbidden, "missing scope "+scopes[i], nil)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), p)))
+	})
+}
+
+// withAuthResponses documents the 401 and 403 answers of every route that
+// declares security, in the route's API version.
+func withAuthResponses(routes []Route) []Route {
+	for i, r := range routes {
+		if r.Doc == nil || len(r.Doc.Security) == 0 {
+			continue
+		}
+		unauthorized := errorResponse("The credentials are invalid, or missing and anonymous callers may not do this.")
+		forbidden := errorResponse("The caller lacks the scope.")
+		if strings.HasPrefix(r.Path, "/v2/") {
+			unauthorized = v2Error(unauthorized.Description)
+			forbidden = v2Error(forbidden.Description)
+		}
+
+		doc := *r.Doc
+		doc.Responses = make(map[string]openapi.Response, len(r.Doc.Responses)+2)
+		doc.Responses["401"] = unauthorized
+		doc.Responses["403"] = forbidden
+		for code, resp := range r.Doc.Responses {
+			doc.Responses[code] = resp
+		}
+		routes[i].Doc = &doc
+	}
+	return routes
+}
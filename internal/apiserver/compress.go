@@ -0,0 +1,227 @@
+package apiserver
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var gzipPool = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return w
+}}
+
+var zstdPool = sync.Pool{New: func() any {
+	w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	return w
+}}
+
+// CompressMiddleware compresses responses of at least minSize bytes with
+// gzip, or zstd when enableZstd is set and the client prefers it.
+// Responses are buffered until minSize is reached so small bodies are sent
+// as they are.
+func CompressMiddleware(next http.Handler, minSize int, enableZstd bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), enableZstd)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the encoding with the highest q-value, preferring
+// zstd over gzip on ties. It returns "" for identity.
+func negotiateEncoding(header string, enableZstd bool) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		switch {
+		case name == "zstd" && enableZstd:
+			if q > bestQ || (q == bestQ && best != "zstd") {
+				best, bestQ = "zstd", q
+			}
+		case name == "gzip":
+			if q > bestQ {
+				best, bestQ = "gzip", q
+			}
+		}
+	}
+	return best
+}
+
+// compressWriter holds the body back until it knows whether the response
+// is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if cw.decided {
+		return cw.write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush commits to the current decision so streamed responses are not held
+// back by the buffer.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide()
+	}
+	if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close writes whatever is still buffered and finishes the encoder.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 {
+			return nil
+		}
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	switch enc := cw.encoder.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(enc)
+	case *zstd.Encoder:
+		zstdPool.Put(enc)
+	}
+	cw.encoder = nil
+	return err
+}
+
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+
+	if cw.shouldCompress() {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		cw.encoder = newEncoder(cw.encoding, cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.write(buf)
+	return err
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	h := cw.Header()
+	if len(cw.buf) < cw.minSize || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	return compressible(h.Get("Content-Type"))
+}
+
+func (cw *compressWriter) write(p []byte) (int, error) {
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// compressible reports whether a content type is text-like. Event streams
+// are excluded because they are flushed message by message.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/javascript",
+		mediaType == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == "zstd" {
+		enc := zstdPool.Get().(*zstd.Encoder)
+		enc.Reset(w)
+		return enc
+	}
+	gz := gzipPool.Get().(*gzip.Writer)
+	gz.Reset(w)
+	return gz
+}
@@ -0,0 +1,91 @@
+package apiserver
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// CacheMiddleware adds cacheControl to successful GET responses that don't
+// set their own Cache-Control, and answers If-Modified-Since with 304 Not
+// Modified when the handler's Last-Modified header is not newer.
+func CacheMiddleware(next http.Handler, cacheControl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&conditionalWriter{ResponseWriter: w, r: r, cacheControl: cacheControl}, r)
+	})
+}
+
+type conditionalWriter struct {
+	http.ResponseWriter
+	r            *http.Request
+	cacheControl string
+	wroteHeader  bool
+	notModified  bool
+}
+
+func (cw *conditionalWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	if code == http.StatusOK {
+		h := cw.Header()
+		if cw.cacheControl != "" && h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", cw.cacheControl)
+		}
+		if notModified(cw.r, h.Get("Last-Modified")) {
+			cw.notModified = true
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			code = http.StatusNotModified
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *conditionalWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.notModified {
+		return len(p), nil
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *conditionalWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *conditionalWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+func (cw *conditionalWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// notModified applies RFC 9110's If-Modified-Since evaluation. It is
+// ignored when If-None-Match is present, which takes precedence.
+func notModified(r *http.Request, lastModified string) bool {
+	if lastModified == "" || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,56 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// swaggerUIScript points Swagger UI at the openapi.json next to /docs, so
+// a tenant served under a path prefix loads its own spec. The default
+// Content-Security-Policy allows it by its hash.
+const swaggerUIScript = `
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+    };
+  `
+
+// swaggerUIPage loads Swagger UI from a CDN and starts it.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Blog API</title>
+  <link rel="stylesheet" href="` + swaggerUICDN + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="` + swaggerUICDN + `/swagger-ui-bundle.js"></script>
+  <script>` + swaggerUIScript + `</script>
+</body>
+</html>
+`
+
+// swaggerUIOrigin serves Swagger UI's files, under swaggerUICDN.
+const (
+	swaggerUIOrigin = "https://unpkg.com"
+	swaggerUICDN    = swaggerUIOrigin + "/swagger-ui-dist@5"
+)
+
+func swaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
+
+// serveJSON encodes v once and serves the bytes.
+func serveJSON(v any) http.Handler {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+}
@@ -0,0 +1,233 @@
+package apiserver_test
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/handlers"
+)
+
+func textHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "999")
+		io.WriteString(w, body)
+	})
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"title":"post"}`, 200)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		zstd           bool
+		wantEncoding   string
+	}{
+		{name: "gzip", acceptEncoding: "gzip, deflate", body: large, wantEncoding: "gzip"},
+		{name: "below threshold", acceptEncoding: "gzip", body: "{}", wantEncoding: ""},
+		{name: "no accept-encoding", acceptEncoding: "", body: large, wantEncoding: ""},
+		{name: "gzip refused", acceptEncoding: "gzip;q=0", body: large, wantEncoding: ""},
+		{name: "zstd disabled", acceptEncoding: "zstd", body: large, wantEncoding: ""},
+		{name: "zstd preferred", acceptEncoding: "gzip, zstd", body: large, zstd: true, wantEncoding: "zstd"},
+		{name: "gzip weighted higher", acceptEncoding: "gzip;q=1, zstd;q=0.5", body: large, zstd: true, wantEncoding: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := apiserver.CompressMiddleware(textHandler(tt.body), 1024, tt.zstd)
+			req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantEncoding, w.Header().Get("Content-Encoding"))
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+
+			var r io.Reader = w.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				gz, err := gzip.NewReader(w.Body)
+				require.NoError(t, err)
+				r = gz
+				assert.Empty(t, w.Header().Get("Content-Length"))
+			case "zstd":
+				zr, err := zstd.NewReader(w.Body)
+				require.NoError(t, err)
+				defer zr.Close()
+				r = zr
+			}
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(got))
+		})
+	}
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	updated := time.Date(2025, 3, 1, 12, 0, 0, 500, time.UTC)
+	h := apiserver.CacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", updated.Format(http.TimeFormat))
+		io.WriteString(w, `{"post":{}}`)
+	}), "no-cache")
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		wantStatus      int
+	}{
+		{name: "unconditional", wantStatus: http.StatusOK},
+		{name: "unchanged", ifModifiedSince: updated.Format(http.TimeFormat), wantStatus: http.StatusNotModified},
+		{name: "changed since", ifModifiedSince: updated.Add(-time.Hour).Format(http.TimeFormat), wantStatus: http.StatusOK},
+		{name: "invalid date", ifModifiedSince: "yesterday", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+			if tt.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+			if tt.wantStatus == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			} else {
+				assert.Equal(t, `{"post":{}}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCacheMiddlewareSkipsErrorsAndWrites(t *testing.T) {
+	h := apiserver.CacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}), "no-cache")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/9", nil))
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/posts", nil))
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+}
+
+func TestSecurityMiddleware(t *testing.T) {
+	serve := func(opts apiserver.SecurityOptions) http.Header {
+		w := httptest.NewRecorder()
+		apiserver.SecurityMiddleware(textHandler("{}"), opts).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts", nil))
+		return w.Header()
+	}
+
+	h := serve(apiserver.SecurityOptions{HSTSMaxAge: 24 * time.Hour})
+	assert.Equal(t, "max-age=86400", h.Get("Strict-Transport-Security"))
+	assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+	assert.Equal(t, "no-referrer", h.Get("Referrer-Policy"))
+	assert.Equal(t, apiserver.DefaultContentSecurityPolicy+"; frame-ancestors 'none'", h.Get("Content-Security-Policy"))
+	assert.Equal(t, "application/json", h.Get("Content-Type"), "the handler's headers are kept")
+
+	h = serve(apiserver.SecurityOptions{
+		ContentSecurityPolicy: "default-src 'self';",
+		FrameAncestors:        "https://cms.example",
+		ReferrerPolicy:        "same-origin",
+	})
+	assert.Empty(t, h.Get("Strict-Transport-Security"))
+	assert.Equal(t, "same-origin", h.Get("Referrer-Policy"))
+	assert.Equal(t, "default-src 'self'; frame-ancestors https://cms.example", h.Get("Content-Security-Policy"))
+
+	h = serve(apiserver.SecurityOptions{ContentSecurityPolicy: "-", FrameAncestors: "-", ReferrerPolicy: "-"})
+	assert.Empty(t, h.Get("Content-Security-Policy"))
+	assert.Empty(t, h.Get("Referrer-Policy"))
+	assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+}
+
+func TestDocsAllowedByDefaultPolicy(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiserver.NewServer(handlers.Handlers{}, apiserver.Options{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The inline script must hash to the value the policy allows.
+	body := w.Body.String()
+	start := strings.LastIndex(body, "<script>") + len("<script>")
+	end := strings.LastIndex(body, "</script>")
+	sum := sha256.Sum256([]byte(body[start:end]))
+	assert.Contains(t, apiserver.DefaultContentSecurityPolicy, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	assert.Contains(t, apiserver.DefaultContentSecurityPolicy, "script-src https://unpkg.com ")
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	h := apiserver.CSRFMiddleware(textHandler("{}"), apiserver.CSRFOptions{
+		Secret: secret,
+		Path:   "/team",
+		Exempt: []string{"POST /graphql"},
+	})
+	serve := func(method, path, session, token string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: session})
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		if token != "" {
+			req.Header.Set(apiserver.CSRFHeader, token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/posts", "alice-session", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	issued := cookies[0]
+	assert.Equal(t, apiserver.CSRFCookie, issued.Name)
+	assert.Equal(t, "/team", issued.Path)
+	assert.False(t, issued.HttpOnly, "scripts read the token")
+
+	w = serve(http.MethodPost, "/posts", "alice-session", issued.Value, issued)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Result().Cookies(), "a current token is not set again")
+
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/posts", "alice-session", "").Code)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/posts", "alice-session", issued.Value).Code,
+		"the token must also be in the cookie")
+	planted := &http.Cookie{Name: apiserver.CSRFCookie, Value: "planted"}
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPut, "/posts/1", "alice-session", "planted", planted).Code,
+		"the token must belong to the session")
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "/api-keys/1", "bob-session", issued.Value, issued).Code,
+		"the token must belong to this session")
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/graphql", "alice-session", "").Code, "exempt route")
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/posts", "", "").Code, "no session, no CSRF")
+
+	req := httptest.NewRequest(http.MethodPost, "/posts", nil)
+	req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: "alice-session"})
+	req.Header.Set("Authorization", "Bearer admin-token")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "the Authorization header, not the cookie, authenticates the request")
+}
@@ -0,0 +1,40 @@
+package apiserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+
+	"olbcloud.com/webapi/internal/audit"
+)
+
+// RequestIDHeader carries the request ID, in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// identifyRequest gives every request an ID, kept from RequestIDHeader when
+// the client or a proxy sent a usable one, echoes it in the response and
+// records it in the context with the client's address for the audit log.
+// The address is the connection's peer; forwarding headers are not
+// trusted.
+func identifyRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !audit.ValidRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithRequest(r.Context(), audit.Request{ID: id, ClientIP: ip})))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
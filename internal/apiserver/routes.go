@@ -0,0 +1,792 @@
+package apiserver
+
+import (
+	"expvar"
+	"maps"
+	"net/http"
+	"slices"
+
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/graph"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/openapi"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// Route is an endpoint registered on the mux together with its OpenAPI
+// operation. Every route must be documented; TestEveryRouteIsDocumented
+// enforces it.
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.Handler
+	Doc     *openapi.Operation
+}
+
+// Pattern is the route's http.ServeMux pattern.
+func (r Route) Pattern() string {
+	return r.Method + " " + r.Path
+}
+
+var apiInfo = openapi.Info{
+	Title:       "Blog API",
+	Description: "Posts for the blog frontend and anything else that wants them.",
+	Version:     "1.0.0",
+}
+
+// Routes lists every endpoint the server exposes.
+func Routes(hd handlers.Handlers, opts Options) []Route {
+	return withAuthResponses(slices.Concat(postRoutes(hd, opts), webhookRoutes(hd), apiKeyRoutes(hd), authRoutes(hd), []Route{
+		{http.MethodGet, "/posts/stream", http.HandlerFunc(hd.StreamPostsHandler), &openapi.Operation{
+			OperationID: "streamPosts",
+			Summary:     "Stream post changes as Server-Sent Events",
+			Description: "Sends post.created and post.updated events whose data is {\"post\": ...}, with heartbeat comments in between. " +
+				"A reset event means the missed events are no longer kept and the posts should be reloaded.",
+			Tags:     []string{"posts"},
+			Security: requires(auth.ScopeRead),
+			Parameters: []openapi.Parameter{
+				{Name: "Last-Event-ID", In: "header", Description: "Resume after this event.", Schema: openapi.Integer()},
+				{Name: "last_event_id", In: "query", Description: "Resume after this event, for clients that cannot set Last-Event-ID.", Schema: openapi.Integer()},
+			},
+			Responses: map[string]openapi.Response{
+				"200": {Description: "The event stream.", Content: map[string]openapi.MediaType{"text/event-stream": {Schema: openapi.String()}}},
+				"400": invalidRequest("The last event ID is not an integer."),
+				"404": errorResponse("Streaming is not enabled."),
+				"500": errorResponse("The missed events could not be loaded."),
+				"503": errorResponse("The server is shutting down."),
+			},
+		}},
+		{http.MethodGet, "/posts/{id}/presence", http.HandlerFunc(hd.PresenceHandler), &openapi.Operation{
+			OperationID: "postPresence",
+			Summary:     "Join the editors of a post over a WebSocket",
+			Description: "Peers are named after the signed-in user, and anonymous ones go unnamed. " +
+				"Messages are JSON objects with a type. The server sends welcome (peer is you, peers the others), join, leave, " +
+				"mode, cursor and update (post is the saved post); clients send {\"type\": \"cursor\", \"cursor\": ...} and " +
+				"{\"type\": \"mode\", \"mode\": \"editing\"}.",
+			Tags:     []string{"posts"},
+			Security: requires(auth.ScopeRead),
+			Parameters: []openapi.Parameter{
+				postID,
+				{Name: "mode", In: "query", Description: "Whether the caller is viewing or editing the post.",
+					Schema: &openapi.Schema{Type: "string", Enum: []any{"viewing", "editing"}, Default: "viewing"}},
+			},
+			Responses: map[string]openapi.Response{
+				"101": {Description: "Switched to the WebSocket protocol."},
+				"400": invalidRequest("The ID is not an integer or the mode is unknown."),
+				"404": errorResponse("There is no post with this ID, or presence is not enabled."),
+				"426": errorResponse("The request is not a WebSocket upgrade."),
+				"500": errorResponse("The post could not be loaded."),
+			},
+		}},
+		{http.MethodGet, "/audit", http.HandlerFunc(hd.GetAuditHandler), &openapi.Operation{
+			OperationID: "listAudit",
+			Summary:     "List audit log entries, newest first",
+			Description: "Every change to a post is recorded with who made it, from which request, and the post before and after.",
+			Tags:        []string{"audit"},
+			Security:    requiresUser,
+			Parameters: []openapi.Parameter{
+				{Name: "actor", In: "query", Description: "Only changes by this user.", Schema: openapi.String()},
+				{Name: "entity", In: "query", Description: "Only changes to this kind of entity, such as post.", Schema: openapi.String()},
+				{Name: "entity_id", In: "query", Description: "Only changes to the entity with this ID.", Schema: openapi.String()},
+				{Name: "since", In: "query", Description: "Only changes at or after this time.",
+					Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+				{Name: "until", In: "query", Description: "Only changes before this time.",
+					Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+				{Name: "limit", In: "query", Description: "At most this many entries, up to 1000.",
+					Schema: &openapi.Schema{Type: "integer", Default: 100}},
+			},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("The matching entries.",
+					openapi.Object(map[string]*openapi.Schema{"entries": openapi.ArrayOf(openapi.SchemaOf(models.AuditEntry{}))}), nil),
+				"400": invalidRequest("A time is not RFC 3339, or the limit is out of range."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+				"404": errorResponse("The audit log is not enabled."),
+				"500": errorResponse("The audit log could not be loaded."),
+			},
+		}},
+		{http.MethodGet, "/posts/export", http.HandlerFunc(hd.ExportPostsHandler), &openapi.Operation{
+			OperationID: "exportPosts",
+			Summary:     "Export every post as newline-delimited JSON",
+			Tags:        []string{"transfer"},
+			Security:    requires(auth.ScopeRead),
+			Responses: map[string]openapi.Response{
+				"200": {Description: "One post per line.", Content: map[string]openapi.MediaType{
+					"application/x-ndjson": {Schema: openapi.SchemaOf(models.Post{})},
+				}},
+			},
+		}},
+		{http.MethodPost, "/posts/import", http.HandlerFunc(hd.ImportPostsHandler), &openapi.Operation{
+			OperationID: "importPosts",
+			Summary:     "Import newline-delimited JSON posts",
+			Tags:        []string{"transfer"},
+			Security:    requires(auth.ScopeWrite),
+			Parameters: []openapi.Parameter{
+				{Name: "mode", In: "query", Description: "insert gives every post a new ID; upsert keeps IDs and overwrites.",
+					Schema: &openapi.Schema{Type: "string", Enum: []any{"insert", "upsert"}, Default: "insert"}},
+				{Name: "atomic", In: "query", Description: "Store every line or, if any line fails, none.",
+					Schema: &openapi.Schema{Type: "boolean", Default: false}},
+			},
+			RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				"application/x-ndjson": {Schema: openapi.SchemaOf(models.Post{})},
+			}},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("What was imported and which lines failed.", openapi.SchemaOf(services.ImportResult{}), nil),
+				"400": invalidRequest("The mode or atomic parameter is invalid."),
+				"413": tooLarge,
+				"415": unsupportedMediaType,
+				"422": jsonResponse("An atomic import had failing lines, so nothing was stored.", openapi.SchemaOf(services.ImportResult{}), nil),
+				"500": errorResponse("The import failed."),
+			},
+		}},
+		{http.MethodPost, "/graphql", http.HandlerFunc(hd.GraphQLHandler), &openapi.Operation{
+			OperationID: "graphql",
+			Summary:     "Run a GraphQL query or mutation",
+			Description: "Mutations also need posts:write.",
+			Tags:        []string{"graphql"},
+			Security:    requires(auth.ScopeRead),
+			RequestBody: jsonBody(openapi.SchemaOf(graph.Request{})),
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("The result; errors in the query are reported in errors.", &openapi.Schema{
+					Type: "object",
+					Properties: map[string]*openapi.Schema{
+						"data":   openapi.JSON(),
+						"errors": openapi.ArrayOf(openapi.JSON()),
+					},
+				}, nil),
+				"400": invalidRequest("The body is not a GraphQL request."),
+				"413": tooLarge,
+				"415": unsupportedMediaType,
+			},
+		}},
+		{http.MethodGet, "/feed.rss", http.HandlerFunc(hd.RSSFeedHandler), feedDoc("rssFeed", "RSS 2.0 feed of the latest posts", "application/rss+xml")},
+		{http.MethodGet, "/feed.atom", http.HandlerFunc(hd.AtomFeedHandler), feedDoc("atomFeed", "Atom 1.0 feed of the latest posts", "application/atom+xml")},
+		{http.MethodGet, "/sitemap.xml", http.HandlerFunc(hd.SitemapHandler), &openapi.Operation{
+			OperationID: "sitemap",
+			Summary:     "Sitemap of every post, or a sitemap index beyond 50,000 posts",
+			Tags:        []string{"seo"},
+			Responses: map[string]openapi.Response{
+				"200": xmlResponse("The sitemap or sitemap index.", "application/xml"),
+				"404": {Description: "No sitemap is configured."},
+			},
+		}},
+		{http.MethodGet, "/sitemaps/{file}", http.HandlerFunc(hd.SitemapPageHandler), &openapi.Operation{
+			OperationID: "sitemapPage",
+			Summary:     "One page of a split sitemap",
+			Tags:        []string{"seo"},
+			Parameters: []openapi.Parameter{
+				{Name: "file", In: "path", Required: true, Description: "sitemap-N.xml, as listed in the index.", Schema: openapi.String()},
+			},
+			Responses: map[string]openapi.Response{
+				"200": xmlResponse("The sitemap page.", "application/xml"),
+				"404": {Description: "There is no such page."},
+			},
+		}},
+		{http.MethodGet, "/robots.txt", http.HandlerFunc(hd.RobotsHandler), &openapi.Operation{
+			OperationID: "robots",
+			Summary:     "Crawler rules",
+			Tags:        []string{"seo"},
+			Responses: map[string]openapi.Response{
+				"200": {Description: "robots.txt", Content: map[string]openapi.MediaType{"text/plain": {Schema: openapi.String()}}},
+			},
+		}},
+		{http.MethodGet, "/debug/vars", adminOnly(expvar.Handler()), &openapi.Operation{
+			OperationID: "debugVars",
+			Summary:     "Runtime, pool, cache and gRPC metrics",
+			Description: "Includes the command line and memory statistics, so only admins may read it.",
+			Tags:        []string{"operations"},
+			Security:    requiresUser,
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("expvar variables by name.", &openapi.Schema{Type: "object", AdditionalProperties: openapi.JSON()}, nil),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+			},
+		}},
+	}))
+}
+
+// postRoutes serve the post endpoints under /v1 and /v2 and, negotiated by
+// Accept, on their unversioned paths.
+func postRoutes(hd handlers.Handlers, opts Options) []Route {
+	return slices.Concat(
+		versioned{
+			method: http.MethodGet, path: "/posts",
+			v1: http.HandlerFunc(hd.GetPostsHandler), v2: http.HandlerFunc(hd.GetPostsV2Handler),
+			doc: &openapi.Operation{
+				OperationID: "listPosts",
+				Summary:     "List all posts",
+				Tags:        []string{"posts"},
+				Security:    requires(auth.ScopeRead),
+			},
+			v1Responses: map[string]openapi.Response{
+				"200": jsonResponse("All posts, with Last-Modified set to the latest update.",
+					openapi.Object(map[string]*openapi.Schema{"posts": openapi.ArrayOf(openapi.SchemaOf(models.Post{}))}),
+					lastModified),
+				"304": notModifiedDoc,
+				"500": errorResponse("The posts could not be loaded."),
+			},
+			v2Responses: map[string]openapi.Response{
+				"200": v2Response("All posts, with Last-Modified set to the latest update.",
+					envelope(openapi.ArrayOf(openapi.SchemaOf(models.Post{})), map[string]*openapi.Schema{"count": openapi.Integer()}),
+					lastModified),
+				"304": notModifiedDoc,
+				"500": v2Error("The posts could not be loaded."),
+			},
+		}.routes(opts),
+		versioned{
+			method: http.MethodPost, path: "/posts",
+			v1: http.HandlerFunc(hd.CreatePostHandler), v2: http.HandlerFunc(hd.CreatePostV2Handler),
+			doc: &openapi.Operation{
+				OperationID: "createPost",
+				Summary:     "Create a post",
+				Tags:        []string{"posts"},
+				Security:    requires(auth.ScopeWrite),
+				RequestBody: jsonBody(postInput),
+			},
+			v1Responses: map[string]openapi.Response{
+				"201": jsonResponse("The created post.", postWritten, nil),
+				"400": errorResponse("The body is not a post or misses required fields."),
+				"413": tooLarge,
+				"415": unsupportedMediaType,
+				"500": errorResponse("The post could not be stored."),
+			},
+			v2Responses: map[string]openapi.Response{
+				"201": v2Response("The created post, with its address in Location.", postEnvelope,
+					map[string]openapi.Header{"Location": {Schema: openapi.String()}}),
+				"400": v2Error("The body does not match the schema."),
+				"413": v2Error("The body is larger than the server accepts."),
+				"415": v2Error("The Content-Type is not one the operation accepts."),
+				"500": v2Error("The post could not be stored."),
+			},
+		}.routes(opts),
+		versioned{
+			method: http.MethodPut, path: "/posts/{id}",
+			v1: http.HandlerFunc(hd.UpdatePostHandler), v2: http.HandlerFunc(hd.UpdatePostV2Handler),
+			doc: &openapi.Operation{
+				OperationID: "updatePost",
+				Summary:     "Update a post's title and body",
+				Tags:        []string{"posts"},
+				Security:    requires(auth.ScopeWrite),
+				Parameters:  []openapi.Parameter{postID},
+				RequestBody: jsonBody(postInput),
+			},
+			v1Responses: map[string]openapi.Response{
+				"202": jsonResponse("The post was updated; post echoes the request.", postWritten, nil),
+				"400": errorResponse("The ID is not an integer, or the body is not a post or misses required fields."),
+				"404": errorResponse("There is no post with this ID."),
+				"413": tooLarge,
+				"415": unsupportedMediaType,
+				"500": errorResponse("The post could not be stored."),
+			},
+			v2Responses: map[string]openapi.Response{
+				"202": v2Response("The post as stored.", postEnvelope, nil),
+				"400": v2Error("The ID is not an integer or the body does not match the schema."),
+				"404": v2Error("There is no post with this ID."),
+				"413": v2Error("The body is larger than the server accepts."),
+				"415": v2Error("The Content-Type is not one the operation accepts."),
+				"500": v2Error("The post could not be stored."),
+			},
+		}.routes(opts),
+		versioned{
+			method: http.MethodGet, path: "/posts/{id}",
+			v1: http.HandlerFunc(hd.GetPostByIDHandler), v2: http.HandlerFunc(hd.GetPostByIDV2Handler),
+			doc: &openapi.Operation{
+				OperationID: "getPost",
+				Summary:     "Get a post",
+				Tags:        []string{"posts"},
+				Security:    requires(auth.ScopeRead),
+				Parameters:  []openapi.Parameter{postID},
+			},
+			v1Responses: map[string]openapi.Response{
+				"200": jsonResponse("The post, with Last-Modified set to its update time.",
+					openapi.Object(map[string]*openapi.Schema{"post": openapi.SchemaOf(models.Post{})}),
+					lastModified),
+				"304": notModifiedDoc,
+				"404": errorResponse("There is no post with this ID."),
+				"500": errorResponse("The post could not be loaded."),
+			},
+			v2Responses: map[string]openapi.Response{
+				"200": v2Response("The post, with Last-Modified set to its update time.", postEnvelope, lastModified),
+				"304": notModifiedDoc,
+				"400": v2Error("The ID is not an integer."),
+				"404": v2Error("There is no post with this ID."),
+				"500": v2Error("The post could not be loaded."),
+			},
+		}.routes(opts),
+	)
+}
+
+// webhookRoutes manage webhook subscriptions and their delivery logs. They
+// make the server send requests to any URL, so they are for admins only.
+func webhookRoutes(hd handlers.Handlers) []Route {
+	disabled := errorResponse("Webhooks are not enabled, or there is no such webhook.")
+	return []Route{
+		{http.MethodPost, "/webhooks", adminOnly(http.HandlerFunc(hd.CreateWebhookHandler)), &openapi.Operation{
+			OperationID: "createWebhook",
+			Summary:     "Subscribe a URL to post events",
+			Description: "Deliveries are signed in X-Webhook-Signature as t=<unix seconds>,v1=<hex HMAC-SHA256 of \"<t>.<body>\"> " +
+				"keyed with the secret, which is only returned here. A secret is generated when none is given.",
+			Tags:        []string{"webhooks"},
+			Security:    requiresUser,
+			RequestBody: jsonBody(webhookInput),
+			Responses: map[string]openapi.Response{
+				"201": jsonResponse("The webhook, including its secret.", webhookBody,
+					map[string]openapi.Header{"Location": {Schema: openapi.String()}}),
+				"400": invalidRequest("The body does not match the schema."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+				"404": errorResponse("Webhooks are not enabled."),
+				"413": tooLarge,
+				"415": unsupportedMediaType,
+				"500": errorResponse("The webhook could not be stored."),
+			},
+		}},
+		{http.MethodGet, "/webhooks", adminOnly(http.HandlerFunc(hd.GetWebhooksHandler)), &openapi.Operation{
+			OperationID: "listWebhooks",
+			Summary:     "List webhooks",
+			Tags:        []string{"webhooks"},
+			Security:    requiresUser,
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("Every webhook, without secrets.",
+					openapi.Object(map[string]*openapi.Schema{"webhooks": openapi.ArrayOf(openapi.SchemaOf(models.Webhook{}))}), nil),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+				"404": errorResponse("Webhooks are not enabled."),
+				"500": errorResponse("The webhooks could not be loaded."),
+			},
+		}},
+		{http.MethodGet, "/webhooks/{id}", adminOnly(http.HandlerFunc(hd.GetWebhookHandler)), &openapi.Operation{
+			OperationID: "getWebhook",
+			Summary:     "Get a webhook",
+			Tags:        []string{"webhooks"},
+			Security:    requiresUser,
+			Parameters:  []openapi.Parameter{webhookID},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("The webhook, without its secret.", webhookBody, nil),
+				"400": invalidRequest("The ID is not an integer."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+				"404": disabled,
+				"500": errorResponse("The webhook could not be loaded."),
+			},
+		}},
+		{http.MethodDelete, "/webhooks/{id}", adminOnly(http.HandlerFunc(hd.DeleteWebhookHandler)), &openapi.Operation{
+			OperationID: "deleteWebhook",
+			Summary:     "Unsubscribe a webhook and drop its delivery log",
+			Tags:        []string{"webhooks"},
+			Security:    requiresUser,
+			Parameters:  []openapi.Parameter{webhookID},
+			Responses: map[string]openapi.Response{
+				"204": {Description: "The webhook was deleted."},
+				"400": invalidRequest("The ID is not an integer."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+				"404": disabled,
+				"500": errorResponse("The webhook could not be deleted."),
+			},
+		}},
+		{http.MethodGet, "/webhooks/{id}/deliveries", adminOnly(http.HandlerFunc(hd.GetWebhookDeliveriesHandler)), &openapi.Operation{
+			OperationID: "listWebhookDeliveries",
+			Summary:     "Delivery log of a webhook, newest first",
+			Tags:        []string{"webhooks"},
+			Security:    requiresUser,
+			Parameters:  []openapi.Parameter{webhookID},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("Every delivery with its status, attempts and last error.",
+					openapi.Object(map[string]*openapi.Schema{"deliveries": openapi.ArrayOf(openapi.SchemaOf(models.WebhookDelivery{}))}), nil),
+				"400": invalidRequest("The ID is not an integer."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+				"404": disabled,
+				"500": errorResponse("The deliveries could not be loaded."),
+			},
+		}},
+		{http.MethodPost, "/webhooks/{id}/deliveries/{deliveryID}/redeliver", adminOnly(http.HandlerFunc(hd.RedeliverWebhookHandler)), &openapi.Operation{
+			OperationID: "redeliverWebhook",
+			Summary:     "Queue a delivery again, typically a dead one",
+			Tags:        []string{"webhooks"},
+			Security:    requiresUser,
+			Parameters: []openapi.Parameter{
+				webhookID,
+				{Name: "deliveryID", In: "path", Required: true, Schema: openapi.Integer()},
+			},
+			Responses: map[string]openapi.Response{
+				"202": jsonResponse("The delivery, pending again with a fresh set of attempts.",
+					openapi.Object(map[string]*openapi.Schema{"delivery": openapi.SchemaOf(models.WebhookDelivery{})}), nil),
+				"400": invalidRequest("An ID is not an integer."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller is not an admin."),
+				"404": errorResponse("Webhooks are not enabled, or there is no such webhook or delivery."),
+				"500": errorResponse("The delivery could not be queued."),
+			},
+		}},
+	}
+}
+
+// apiKeyRoutes issue, list and revoke API keys. They are for people, so
+// they refuse anonymous callers and API keys.
+func apiKeyRoutes(hd handlers.Handlers) []Route {
+	return []Route{
+		{http.MethodPost, "/api-keys", http.HandlerFunc(hd.CreateAPIKeyHandler), &openapi.Operation{
+			OperationID: "createAPIKey",
+			Summary:     "Issue an API key",
+			Description: "The key is only returned here; the server keeps a hash. Send it as \"Authorization: ApiKey <key>\".",
+			Tags:        []string{"api-keys"},
+			Security:    requiresUser,
+			RequestBody: jsonBody(apiKeyInput),
+			Responses: map[string]openapi.Response{
+				"201": jsonResponse("The key's details and, once, the key.", openapi.Object(map[string]*openapi.Schema{
+					"api_key": openapi.SchemaOf(models.APIKey{}),
+					"key":     openapi.String(),
+				}), map[string]openapi.Header{"Location": {Schema: openapi.String()}}),
+				"400": invalidRequest("The body does not match the schema, or expires_at is in the past."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller used an API key, is not an admin and named another user, or asked for a scope it lacks."),
+				"404": errorResponse("API keys are not enabled."),
+				"413": tooLarge,
+				"415": unsupportedMediaType,
+				"500": errorResponse("The key could not be stored."),
+			},
+		}},
+		{http.MethodGet, "/api-keys", http.HandlerFunc(hd.GetAPIKeysHandler), &openapi.Operation{
+			OperationID: "listAPIKeys",
+			Summary:     "List API keys, without the keys themselves",
+			Tags:        []string{"api-keys"},
+			Security:    requiresUser,
+			Parameters: []openapi.Parameter{
+				{Name: "user", In: "query", Description: "Only this user's keys. Admins see every user's keys by default, others their own.",
+					Schema: openapi.String()},
+			},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("The keys, revoked and expired ones included.",
+					openapi.Object(map[string]*openapi.Schema{"api_keys": openapi.ArrayOf(openapi.SchemaOf(models.APIKey{}))}), nil),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller used an API key, or is not an admin and named another user."),
+				"404": errorResponse("API keys are not enabled."),
+				"500": errorResponse("The keys could not be loaded."),
+			},
+		}},
+		{http.MethodDelete, "/api-keys/{id}", http.HandlerFunc(hd.RevokeAPIKeyHandler), &openapi.Operation{
+			OperationID: "revokeAPIKey",
+			Summary:     "Revoke an API key",
+			Tags:        []string{"api-keys"},
+			Security:    requiresUser,
+			Parameters:  []openapi.Parameter{{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("The revoked key.", openapi.Object(map[string]*openapi.Schema{"api_key": openapi.SchemaOf(models.APIKey{})}), nil),
+				"400": invalidRequest("The ID is not an integer."),
+				"401": errorResponse("The caller is not an authenticated user."),
+				"403": errorResponse("The caller used an API key."),
+				"404": errorResponse("API keys are not enabled, or the caller has no key with this ID."),
+				"500": errorResponse("The key could not be revoked."),
+			},
+		}},
+	}
+}
+
+// authRoutes sign staff in through the identity provider and out again.
+func authRoutes(hd handlers.Handlers) []Route {
+	redirect := func(description string) openapi.Response {
+		return openapi.Response{Description: description, Headers: map[string]openapi.Header{"Location": {Schema: openapi.String()}}}
+	}
+	return []Route{
+		{http.MethodGet, "/auth/oidc/login", http.HandlerFunc(hd.OIDCLoginHandler), &openapi.Operation{
+			OperationID: "oidcLogin",
+			Summary:     "Sign in through the identity provider",
+			Description: "Sends the browser to the identity provider, which sends it back to /auth/oidc/callback.",
+			Tags:        []string{"auth"},
+			Parameters: []openapi.Parameter{
+				{Name: "return_to", In: "query", Description: "Local path to return to once signed in.",
+					Schema: &openapi.Schema{Type: "string", Default: "/"}},
+			},
+			Responses: map[string]openapi.Response{
+				"302": redirect("To the identity provider."),
+				"400": errorResponse("return_to is not a local path."),
+				"404": errorResponse("OIDC login is not enabled."),
+				"502": errorResponse("The identity provider could not be reached."),
+			},
+		}},
+		{http.MethodGet, "/auth/oidc/callback", http.HandlerFunc(hd.OIDCCallbackHandler), &openapi.Operation{
+			OperationID: "oidcCallback",
+			Summary:     "Finish signing in",
+			Description: "The identity provider sends the browser here. On success the session cookie " + auth.SessionCookie + " is set.",
+			Tags:        []string{"auth"},
+			Parameters: []openapi.Parameter{
+				{Name: "code", In: "query", Schema: openapi.String()},
+				{Name: "state", In: "query", Schema: openapi.String()},
+				{Name: "error", In: "query", Description: "Set by the provider when the sign-in failed.", Schema: openapi.String()},
+			},
+			Responses: map[string]openapi.Response{
+				"302": redirect("Back to return_to, signed in."),
+				"400": errorResponse("The sign-in failed at the provider, expired, or the state does not match."),
+				"401": errorResponse("The ID token did not check out, or names no user."),
+				"404": errorResponse("OIDC login is not enabled."),
+				"500": errorResponse("The session could not be started."),
+				"502": errorResponse("The identity provider refused the code."),
+			},
+		}},
+		{http.MethodPost, "/auth/logout", http.HandlerFunc(hd.LogoutHandler), &openapi.Operation{
+			OperationID: "logout",
+			Summary:     "Sign out",
+			Tags:        []string{"auth"},
+			Responses: map[string]openapi.Response{
+				"204": {Description: "The session cookie was cleared."},
+				"404": errorResponse("Sessions are not enabled."),
+			},
+		}},
+		{http.MethodGet, "/auth/me", http.HandlerFunc(hd.GetMeHandler), &openapi.Operation{
+			OperationID: "getMe",
+			Summary:     "Who the caller is",
+			Tags:        []string{"auth"},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("The caller's user, roles and scopes.", openapi.Object(map[string]*openapi.Schema{
+					"user":   openapi.String(),
+					"roles":  openapi.ArrayOf(openapi.String()),
+					"scopes": openapi.ArrayOf(openapi.String()),
+				}), nil),
+				"401": errorResponse("The caller is anonymous or its credentials are invalid."),
+			},
+		}},
+	}
+}
+
+// versioned is an endpoint with a v1 and a v2 handler. doc holds what both
+// versions share; its OperationID names the unversioned route.
+type versioned struct {
+	method, path             string
+	v1, v2                   http.Handler
+	doc                      *openapi.Operation
+	v1Responses, v2Responses map[string]openapi.Response
+}
+
+func (v versioned) routes(opts Options) []Route {
+	v1 := legacyV1{deprecated(v.v1, opts)}
+
+	v1Doc := *v.doc
+	v1Doc.OperationID += "V1"
+	v1Doc.Deprecated = true
+	v1Doc.Responses = withDeprecationHeaders(v.v1Responses)
+
+	v2Doc := *v.doc
+	v2Doc.OperationID += "V2"
+	v2Doc.Responses = v.v2Responses
+
+	negotiated := *v.doc
+	negotiated.Description = "Answers as v1 unless Accept asks for " + handlers.MediaTypeV2 + "."
+	negotiated.Responses = map[string]openapi.Response{}
+	for code, resp := range v1Doc.Responses {
+		negotiated.Responses[code] = resp
+	}
+	for code, resp := range v2Doc.Responses {
+		merged, ok := negotiated.Responses[code]
+		if !ok {
+			negotiated.Responses[code] = resp
+			continue
+		}
+		content := map[string]openapi.MediaType{}
+		maps.Copy(content, merged.Content)
+		maps.Copy(content, resp.Content)
+		headers := map[string]openapi.Header{}
+		maps.Copy(headers, merged.Headers)
+		maps.Copy(headers, resp.Headers)
+		merged.Content, merged.Headers = content, headers
+		negotiated.Responses[code] = merged
+	}
+
+	return []Route{
+		{v.method, v.path, legacyV1{negotiate(v1, v.v2)}, &negotiated},
+		{v.method, "/v1" + v.path, v1, &v1Doc},
+		{v.method, "/v2" + v.path, v.v2, &v2Doc},
+	}
+}
+
+var deprecationHeaders = map[string]openapi.Header{
+	"Deprecation": {Description: "When v1 was deprecated, as @<unix seconds>.", Schema: openapi.String()},
+	"Sunset":      {Description: "When v1 will be removed.", Schema: openapi.String()},
+	"Link":        {Description: "The v2 successor, rel=\"successor-version\".", Schema: openapi.String()},
+}
+
+func withDeprecationHeaders(responses map[string]openapi.Response) map[string]openapi.Response {
+	out := make(map[string]openapi.Response, len(responses))
+	for code, resp := range responses {
+		headers := maps.Clone(deprecationHeaders)
+		maps.Copy(headers, resp.Headers)
+		resp.Headers = headers
+		out[code] = resp
+	}
+	return out
+}
+
+// docRoutes serve the document built from routes.
+func docRoutes(spec openapi.Document) []Route {
+	return []Route{
+		{http.MethodGet, "/openapi.json", serveJSON(spec), &openapi.Operation{
+			OperationID: "openapi",
+			Summary:     "This OpenAPI document",
+			Tags:        []string{"docs"},
+			Responses: map[string]openapi.Response{
+				"200": jsonResponse("OpenAPI 3.1 document.", openapi.JSON(), nil),
+			},
+		}},
+		{http.MethodGet, "/docs", http.HandlerFunc(swaggerUI), &openapi.Operation{
+			OperationID: "docs",
+			Summary:     "Swagger UI for this API",
+			Tags:        []string{"docs"},
+			Responses: map[string]openapi.Response{
+				"200": {Description: "HTML page.", Content: map[string]openapi.MediaType{"text/html": {Schema: openapi.String()}}},
+			},
+		}},
+	}
+}
+
+// Spec builds the OpenAPI document for routes, including the routes that
+// serve it.
+func Spec(routes []Route) openapi.Document {
+	all := slices.Concat(routes, docRoutes(openapi.Document{}))
+	docs := make([]openapi.Route, 0, len(all))
+	for _, r := range all {
+		docs = append(docs, openapi.Route{Method: r.Method, Path: r.Path, Operation: r.Doc})
+	}
+	doc := openapi.Build(apiInfo, docs)
+	doc.Components.SecuritySchemes = securitySchemes
+	return doc
+}
+
+var (
+	// postInput lists the read-only fields so v1 clients that send back
+	// the post they read are documented; only v1 accepts them.
+	postInput = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"title":      {Type: "string", MinLength: intPtr(1)},
+			"body":       {Type: "string", MinLength: intPtr(1)},
+			"id":         {Type: "integer", ReadOnly: true},
+			"created_at": {Type: "string", Format: "date-time", ReadOnly: true},
+			"updated_at": {Type: "string", Format: "date-time", ReadOnly: true},
+		},
+		Required:             []string{"body", "title"},
+		AdditionalProperties: false,
+	}
+	postWritten = openapi.Object(map[string]*openapi.Schema{
+		"message": openapi.String(),
+		"status":  {Type: "string", Enum: []any{"success"}},
+		"post":    openapi.SchemaOf(models.Post{}),
+	})
+	postEnvelope = envelope(openapi.SchemaOf(models.Post{}), nil)
+	postID       = openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+
+	webhookInput = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"url": {Type: "string", Format: "uri", MinLength: intPtr(1)},
+			"events": openapi.ArrayOf(&openapi.Schema{Type: "string", Enum: []any{
+				string(services.EventPostCreated), string(services.EventPostUpdated), string(services.EventPostDeleted),
+			}}),
+			"secret": {Type: "string", Description: "Signing secret; generated when omitted."},
+		},
+		Required:             []string{"events", "url"},
+		AdditionalProperties: false,
+	}
+	webhookBody = openapi.Object(map[string]*openapi.Schema{"webhook": openapi.SchemaOf(models.Webhook{})})
+	webhookID   = openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+
+	apiKeyInput = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"name":       {Type: "string", MinLength: intPtr(1), Description: "What the key is for."},
+			"user":       {Type: "string", MinLength: intPtr(1), Description: "Whose key it is; the caller when omitted."},
+			"scopes":     openapi.ArrayOf(&openapi.Schema{Type: "string", Enum: []any{auth.ScopeRead, auth.ScopeWrite}}),
+			"expires_at": {Type: "string", Format: "date-time", Description: "When the key stops working; never when omitted."},
+		},
+		Required:             []string{"name", "scopes"},
+		AdditionalProperties: false,
+	}
+
+	lastModified = map[string]openapi.Header{
+		"Last-Modified": {Schema: openapi.String()},
+	}
+	notModifiedDoc = openapi.Response{Description: "Unchanged since If-Modified-Since."}
+
+	tooLarge             = errorResponse("The body is larger than the server accepts.")
+	unsupportedMediaType = errorResponse("The Content-Type is not one the operation accepts.")
+)
+
+func jsonBody(s *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{"application/json": {Schema: s}}}
+}
+
+func jsonResponse(description string, s *openapi.Schema, headers map[string]openapi.Header) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Headers:     headers,
+		Content:     map[string]openapi.MediaType{"application/json": {Schema: s}},
+	}
+}
+
+func errorResponse(description string) openapi.Response {
+	return jsonResponse(description, openapi.Object(map[string]*openapi.Schema{"error": openapi.String()}), nil)
+}
+
+// invalidRequest documents the per-field errors of request validation.
+func invalidRequest(description string) openapi.Response {
+	return jsonResponse(description, &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"error":  openapi.String(),
+			"fields": openapi.ArrayOf(openapi.SchemaOf(openapi.FieldError{})),
+		},
+		Required:             []string{"error"},
+		AdditionalProperties: false,
+	}, nil)
+}
+
+// envelope is the v2 response body around data.
+func envelope(data *openapi.Schema, meta map[string]*openapi.Schema) *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"data":  data,
+		"meta":  openapi.Object(meta),
+		"links": {Type: "object", AdditionalProperties: openapi.String()},
+	})
+}
+
+func v2Response(description string, s *openapi.Schema, headers map[string]openapi.Header) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Headers:     headers,
+		Content:     map[string]openapi.MediaType{handlers.MediaTypeV2: {Schema: s}},
+	}
+}
+
+func v2Error(description string) openapi.Response {
+	return v2Response(description, openapi.Object(map[string]*openapi.Schema{
+		"data":   {Type: "null"},
+		"errors": openapi.ArrayOf(openapi.SchemaOf(handlers.EnvelopeError{})),
+		"meta":   openapi.Object(nil),
+		"links":  {Type: "object", AdditionalProperties: openapi.String()},
+	}), nil)
+}
+
+func xmlResponse(description, contentType string) openapi.Response {
+	return openapi.Response{Description: description, Content: map[string]openapi.MediaType{contentType: {Schema: openapi.String()}}}
+}
+
+func feedDoc(id, summary, contentType string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     summary,
+		Tags:        []string{"feeds"},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "The feed, with an ETag for If-None-Match.",
+				Headers:     map[string]openapi.Header{"ETag": {Schema: openapi.String()}},
+				Content:     map[string]openapi.MediaType{contentType: {Schema: openapi.String()}},
+			},
+			"304": {Description: "Unchanged since the ETag in If-None-Match."},
+		},
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,177 @@
+package apiserver_test
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/handlers"
+)
+
+// servedSpec fetches /openapi.json as generic JSON.
+func servedSpec(t *testing.T) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	apiserver.NewServer(handlers.Handlers{}, apiserver.Options{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	return spec
+}
+
+func documented(spec map[string]any, method, path string) bool {
+	item, ok := spec["paths"].(map[string]any)[path].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, ok = item[strings.ToLower(method)]
+	return ok
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	spec := servedSpec(t)
+	assert.Equal(t, "3.1.0", spec["openapi"])
+
+	routes := apiserver.Routes(handlers.Handlers{}, apiserver.Options{})
+	ids := map[string]bool{}
+	for _, r := range routes {
+		require.NotNil(t, r.Doc, "%s has no OpenAPI operation", r.Pattern())
+		assert.True(t, documented(spec, r.Method, r.Path), "%s missing from /openapi.json", r.Pattern())
+
+		assert.False(t, ids[r.Doc.OperationID], "duplicate operationId %q", r.Doc.OperationID)
+		ids[r.Doc.OperationID] = true
+
+		for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+			found := false
+			for _, p := range r.Doc.Parameters {
+				found = found || (p.In == "path" && p.Name == m[1])
+			}
+			assert.True(t, found, "%s does not document path parameter %s", r.Pattern(), m[1])
+		}
+	}
+	assert.True(t, documented(spec, http.MethodGet, "/openapi.json"))
+	assert.True(t, documented(spec, http.MethodGet, "/docs"))
+}
+
+// TestNoUndocumentedMuxRoutes catches routes registered on the mux directly
+// instead of through Routes.
+func TestNoUndocumentedMuxRoutes(t *testing.T) {
+	spec := servedSpec(t)
+
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		require.NoError(t, err)
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok {
+				return true
+			}
+			pattern, _ := strconv.Unquote(lit.Value)
+			method, path, _ := strings.Cut(pattern, " ")
+			assert.True(t, documented(spec, method, path), "%s at %s is not documented", pattern, fset.Position(lit.Pos()))
+			return true
+		})
+	}
+}
+
+func TestSpecReferencesResolve(t *testing.T) {
+	spec := servedSpec(t)
+	data, err := json.Marshal(spec)
+	require.NoError(t, err)
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+
+	refs := regexp.MustCompile(`"\$ref":"#/components/schemas/(\w+)"`).FindAllStringSubmatch(string(data), -1)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		assert.Contains(t, schemas, ref[1])
+	}
+
+	post := schemas["Post"].(map[string]any)
+	assert.ElementsMatch(t, []any{"title", "body"}, post["required"])
+	assert.Equal(t, "date-time", post["properties"].(map[string]any)["created_at"].(map[string]any)["format"])
+}
+
+// TestSpecDeclaresScopes checks that scopes are declared where the
+// middleware enforces them, and that every scheme used is defined.
+func TestSpecDeclaresScopes(t *testing.T) {
+	spec := servedSpec(t)
+	schemes := spec["components"].(map[string]any)["securitySchemes"].(map[string]any)
+	assert.Contains(t, schemes, "apiKey")
+
+	paths := spec["paths"].(map[string]any)
+	for path, item := range paths {
+		for method, op := range item.(map[string]any) {
+			security, _ := op.(map[string]any)["security"].([]any)
+			for _, req := range security {
+				for name := range req.(map[string]any) {
+					assert.Contains(t, schemes, name, "%s %s", method, path)
+				}
+			}
+		}
+	}
+
+	scopes := func(method, path string) []any {
+		op := paths[path].(map[string]any)[method].(map[string]any)
+		return op["security"].([]any)[0].(map[string]any)["apiKey"].([]any)
+	}
+	assert.Equal(t, []any{"posts:read"}, scopes("get", "/posts"))
+	assert.Equal(t, []any{"posts:write"}, scopes("post", "/v2/posts"))
+	assert.Equal(t, []any{"posts:write"}, scopes("post", "/posts/import"))
+	assert.Contains(t, paths["/v1/posts"].(map[string]any)["post"].(map[string]any)["responses"], "403")
+	assert.NotContains(t, paths["/feed.rss"].(map[string]any)["get"], "security", "feeds stay public")
+}
+
+func TestSwaggerUI(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiserver.NewServer(handlers.Handlers{}, apiserver.Options{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `url: "openapi.json"`, "the spec is found next to the page, under any path prefix")
+}
+
+func TestDebugVarsNeedsAdmin(t *testing.T) {
+	hd := handlers.Handlers{Auth: &auth.Authenticator{AdminToken: "admin-token", Anonymous: auth.Scopes}}
+	mux := apiserver.NewServer(hd, apiserver.Options{})
+	get := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, get(""), "anonymous callers hold every scope but are not admins")
+	assert.Equal(t, http.StatusOK, get("Bearer admin-token"))
+}
@@ -0,0 +1,140 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/openapi"
+)
+
+// validateRequest checks a request against op before next sees it: the
+// content type, path and query parameters, and JSON bodies, which must fit
+// in MaxBodyBytes and may only hold fields the schema knows. Other bodies
+// are only limited in size. v1 requests to legacy endpoints skip the
+// parameter and schema checks.
+func validateRequest(next http.Handler, op *openapi.Operation, components openapi.Components, opts Options) http.Handler {
+	if op == nil || (len(op.Parameters) == 0 && op.RequestBody == nil) {
+		return next
+	}
+	_, legacy := next.(legacyV1)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkSchema := !legacy || apiVersion(r) != 1
+
+		var errs []openapi.FieldError
+		if checkSchema {
+			errs = validateParams(r, op.Parameters, components)
+		}
+
+		if body := op.RequestBody; body != nil {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			content, ok := body.Content[mediaType]
+			if !ok {
+				types := slices.Sorted(maps.Keys(body.Content))
+				writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be "+strings.Join(types, " or "), nil)
+				return
+			}
+
+			if mediaType != "application/json" {
+				r.Body = http.MaxBytesReader(w, r.Body, opts.MaxStreamBodyBytes)
+			} else {
+				data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, opts.MaxBodyBytes))
+				if err != nil {
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large", nil)
+						return
+					}
+					writeError(w, r, http.StatusBadRequest, "could not read request body", nil)
+					return
+				}
+				if checkSchema {
+					errs = append(errs, validateJSON(data, body.Required, content.Schema, components)...)
+				}
+				r.Body = io.NopCloser(bytes.NewReader(data))
+			}
+		}
+
+		if len(errs) > 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid request", errs)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validateParams(r *http.Request, params []openapi.Parameter, components openapi.Components) []openapi.FieldError {
+	var errs []openapi.FieldError
+	query := r.URL.Query()
+	for _, p := range params {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw = r.PathValue(p.Name)
+			present = raw != ""
+		case "query":
+			raw = query.Get(p.Name)
+			present = query.Has(p.Name)
+		default:
+			continue
+		}
+		if err := components.ValidateParam(p, raw, present); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	return errs
+}
+
+func validateJSON(data []byte, required bool, schema *openapi.Schema, components openapi.Components) []openapi.FieldError {
+	if len(bytes.TrimSpace(data)) == 0 {
+		if required {
+			return []openapi.FieldError{{In: "body", Message: "is required"}}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []openapi.FieldError{{In: "body", Message: "is not valid JSON"}}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return []openapi.FieldError{{In: "body", Message: "must hold a single JSON value"}}
+	}
+	return components.ValidateJSON(schema, v)
+}
+
+// writeError matches the handlers' {"error": ...} responses, adding the
+// fields that failed validation. v2 requests get a v2 envelope with one
+// error per field.
+func writeError(w http.ResponseWriter, r *http.Request, code int, msg string, fields []openapi.FieldError) {
+	if apiVersion(r) == 2 {
+		env := handlers.Envelope{Links: map[string]string{"self": r.URL.Path}}
+		for _, f := range fields {
+			env.Errors = append(env.Errors, handlers.EnvelopeError{Message: f.Message, In: f.In, Field: f.Field})
+		}
+		if len(env.Errors) == 0 {
+			env.Errors = []handlers.EnvelopeError{{Message: msg}}
+		}
+		handlers.WriteEnvelope(w, code, env)
+		return
+	}
+
+	body := map[string]any{"error": msg}
+	if len(fields) > 0 {
+		body["fields"] = fields
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
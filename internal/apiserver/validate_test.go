@@ -0,0 +1,157 @@
+package apiserver_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/openapi"
+	"olbcloud.com/webapi/internal/services"
+)
+
+type validationError struct {
+	Error  string               `json:"error"`
+	Fields []openapi.FieldError `json:"fields"`
+}
+
+func TestRequestValidation(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	mux := apiserver.NewServer(handlers.NewHandlers(services.NewPostService(db)), apiserver.Options{
+		MaxBodyBytes:       128,
+		MaxStreamBodyBytes: 256,
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		accept      string
+		body        string
+		wantStatus  int
+		wantError   string
+		wantFields  []openapi.FieldError
+	}{
+		{
+			name: "valid create", method: http.MethodPost, path: "/posts", contentType: "application/json; charset=utf-8",
+			body: `{"title": "t", "body": "b"}`, wantStatus: http.StatusCreated,
+		},
+		{
+			name: "missing content type", method: http.MethodPost, path: "/posts",
+			body: `{"title": "t", "body": "b"}`, wantStatus: http.StatusUnsupportedMediaType, wantError: "Content-Type must be application/json",
+		},
+		{
+			name: "form body", method: http.MethodPost, path: "/posts", contentType: "application/x-www-form-urlencoded",
+			body: `title=t&body=b`, wantStatus: http.StatusUnsupportedMediaType, wantError: "Content-Type must be application/json",
+		},
+		{
+			name: "too large", method: http.MethodPost, path: "/posts", contentType: "application/json",
+			body: `{"title": "t", "body": "` + strings.Repeat("b", 128) + `"}`, wantStatus: http.StatusRequestEntityTooLarge, wantError: "request body too large",
+		},
+		{
+			name: "not json", method: http.MethodPost, path: "/webhooks", contentType: "application/json",
+			body: `{"url": `, wantStatus: http.StatusBadRequest, wantError: "invalid request",
+			wantFields: []openapi.FieldError{{In: "body", Message: "is not valid JSON"}},
+		},
+		{
+			name: "trailing data", method: http.MethodPost, path: "/webhooks", contentType: "application/json",
+			body: `{"url": "http://a", "events": []} {}`, wantStatus: http.StatusBadRequest, wantError: "invalid request",
+			wantFields: []openapi.FieldError{{In: "body", Message: "must hold a single JSON value"}},
+		},
+		{
+			name: "wrong types and empty strings", method: http.MethodPost, path: "/webhooks", contentType: "application/json",
+			body: `{"url": "", "events": "post.created"}`, wantStatus: http.StatusBadRequest, wantError: "invalid request",
+			wantFields: []openapi.FieldError{
+				{In: "body", Field: "events", Message: "must be an array"},
+				{In: "body", Field: "url", Message: "must not be empty"},
+			},
+		},
+		{
+			name: "bad path id", method: http.MethodGet, path: "/webhooks/x",
+			wantStatus: http.StatusBadRequest, wantError: "invalid request",
+			wantFields: []openapi.FieldError{{In: "path", Field: "id", Message: "must be an integer"}},
+		},
+		{
+			name: "v1 post with read-only fields", method: http.MethodPost, path: "/posts", contentType: "application/json",
+			body: `{"id": 7, "title": "t", "body": "b", "created_at": "2020-01-01T00:00:00Z"}`, wantStatus: http.StatusCreated,
+		},
+		{
+			name: "v1 post errors keep their v1 bodies", method: http.MethodPut, path: "/v1/posts/x", contentType: "application/json",
+			body: `{"title": "t", "body": "b", "tags": []}`, wantStatus: http.StatusBadRequest, wantError: "invalid request payload",
+		},
+		{
+			name: "v1 post with missing fields", method: http.MethodPost, path: "/posts", contentType: "application/json",
+			body: `{"title": "t", "tags": []}`, wantStatus: http.StatusBadRequest, wantError: "missing required fields",
+		},
+		{
+			name: "bad query parameters", method: http.MethodPost, path: "/posts/import?mode=merge&atomic=maybe", contentType: "application/x-ndjson",
+			wantStatus: http.StatusBadRequest, wantError: "invalid request",
+			wantFields: []openapi.FieldError{
+				{In: "query", Field: "mode", Message: "must be one of insert, upsert"},
+				{In: "query", Field: "atomic", Message: "must be true or false"},
+			},
+		},
+		{
+			name: "import too large", method: http.MethodPost, path: "/posts/import", contentType: "application/x-ndjson",
+			body: strings.Repeat(`{"title": "t", "body": "b"}`+"\n", 16), wantStatus: http.StatusRequestEntityTooLarge, wantError: "request body too large",
+		},
+		{
+			name: "graphql with null variables and extensions", method: http.MethodPost, path: "/graphql", contentType: "application/json",
+			body: `{"query": "{ post(id: 1) { id } }", "variables": null, "extensions": {}}`, wantStatus: http.StatusOK,
+		},
+		{
+			name: "v2 post with read-only fields", method: http.MethodPost, path: "/posts", contentType: "application/json",
+			accept: handlers.MediaTypeV2, body: `{"id": 7, "title": "t", "body": "b"}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "graphql without query", method: http.MethodPost, path: "/graphql", contentType: "application/json",
+			body: `{"variables": {}}`, wantStatus: http.StatusBadRequest, wantError: "invalid request",
+			wantFields: []openapi.FieldError{{In: "body", Field: "query", Message: "is required"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantError == "" {
+				return
+			}
+			var got validationError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.wantError, got.Error)
+			assert.Equal(t, tt.wantFields, got.Fields)
+		})
+	}
+}
+
+func TestEmptyBodyIsABodyError(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	mux := apiserver.NewServer(handlers.NewHandlers(services.NewPostService(db)), apiserver.Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"invalid request","fields":[{"in":"body","message":"is required"}]}`, w.Body.String())
+}
@@ -0,0 +1,82 @@
+package apiserver
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"olbcloud.com/webapi/internal/handlers"
+)
+
+// Deprecation dates announced on v1 responses when Options leaves them zero.
+var (
+	DefaultV1Deprecation = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	DefaultV1Sunset      = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// apiVersion is the version a request is served by: the path prefix if it
+// has one, otherwise v2 only when Accept asks for it.
+func apiVersion(r *http.Request) int {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/"):
+		return 1
+	case strings.HasPrefix(r.URL.Path, "/v2/"):
+		return 2
+	case acceptsV2(r.Header.Values("Accept")):
+		return 2
+	}
+	return 1
+}
+
+// acceptsV2 reports whether the v2 media type is listed with a non-zero
+// quality. Wildcards don't count, so browsers keep getting v1.
+func acceptsV2(accept []string) bool {
+	for _, header := range accept {
+		for _, item := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+			if err != nil || mediaType != handlers.MediaTypeV2 {
+				continue
+			}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// legacyV1 marks the handlers of endpoints that predate v2. Their v1
+// requests are only checked for content type and size, and the handlers
+// answer bad input with the error bodies v1 clients have always seen.
+type legacyV1 struct{ http.Handler }
+
+// negotiate serves unversioned paths with v2 when the client asks for it
+// and v1 otherwise.
+func negotiate(v1, v2 http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if apiVersion(r) == 2 {
+			v2.ServeHTTP(w, r)
+			return
+		}
+		v1.ServeHTTP(w, r)
+	})
+}
+
+// deprecated announces that a v1 endpoint will go away (RFC 9745 and RFC
+// 8594) and links its v2 successor.
+func deprecated(next http.Handler, opts Options) http.Handler {
+	deprecation := "@" + strconv.FormatInt(opts.V1Deprecation.Unix(), 10)
+	sunset := opts.V1Sunset.UTC().Format(http.TimeFormat)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Deprecation", deprecation)
+		h.Set("Sunset", sunset)
+		h.Add("Link", "</v2"+strings.TrimPrefix(r.URL.Path, "/v1")+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
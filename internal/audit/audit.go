@@ -0,0 +1,113 @@
+// Package audit describes changes for the audit log. The request context
+// carries who made a change, as an auth.Principal, and the request it came
+// in, as a Request; Entry combines them with the entity before and after.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// Anonymous is the actor of changes made without credentials.
+const Anonymous = "anonymous"
+
+// Request identifies the request a change came in.
+type Request struct {
+	ID       string
+	ClientIP string
+}
+
+// ValidRequestID accepts up to 128 printable ASCII characters, so an ID
+// taken from a client cannot smuggle anything into logs or headers.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+type contextKey struct{}
+
+// WithRequest returns a copy of ctx carrying r.
+func WithRequest(ctx context.Context, r Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// RequestFrom returns the request ctx carries, or a zero Request.
+func RequestFrom(ctx context.Context) Request {
+	r, _ := ctx.Value(contextKey{}).(Request)
+	return r
+}
+
+// Actor names who makes the changes of ctx.
+func Actor(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok && !p.Anonymous() {
+		return p.User
+	}
+	return Anonymous
+}
+
+// Entry describes action on the entity with id, made in ctx. before and
+// after are encoded as JSON; pass nil for the side that does not exist.
+func Entry(ctx context.Context, action, entity, id string, before, after any) (models.AuditEntry, error) {
+	req := RequestFrom(ctx)
+	e := models.AuditEntry{
+		Actor:     Actor(ctx),
+		Action:    action,
+		Entity:    entity,
+		EntityID:  id,
+		RequestID: req.ID,
+		ClientIP:  req.ClientIP,
+	}
+	var err error
+	if before != nil {
+		if e.Before, err = json.Marshal(before); err != nil {
+			return models.AuditEntry{}, err
+		}
+	}
+	if after != nil {
+		if e.After, err = json.Marshal(after); err != nil {
+			return models.AuditEntry{}, err
+		}
+	}
+	return e, nil
+}
+
+// Func describes the changes made in ctx to entity, whose ID id returns,
+// for a database.AuditedWriter: a change with nothing before is a
+// creation, one with nothing after a deletion and any other an update.
+func Func[T any](ctx context.Context, entity string, id func(T) int) database.AuditFunc[T] {
+	return func(before, after *T) (models.AuditEntry, error) {
+		var b, a any
+		if before != nil {
+			b = *before
+		}
+		if after != nil {
+			a = *after
+		}
+		switch {
+		case before == nil:
+			return Entry(ctx, models.AuditCreate, entity, strconv.Itoa(id(*after)), nil, a)
+		case after == nil:
+			return Entry(ctx, models.AuditDelete, entity, strconv.Itoa(id(*before)), b, nil)
+		default:
+			return Entry(ctx, models.AuditUpdate, entity, strconv.Itoa(id(*after)), b, a)
+		}
+	}
+}
+
+// APIKeys describes the API keys issued and revoked in ctx, for
+// auth.Keys.Describe. Keys never encode their hash.
+func APIKeys(ctx context.Context) database.AuditFunc[models.APIKey] {
+	return Func(ctx, auth.AuditEntityAPIKey, func(k models.APIKey) int { return k.ID })
+}
@@ -0,0 +1,113 @@
+// Package auth identifies API callers and what they may do.
+//
+// Machine clients send "Authorization: ApiKey <key>" with a key issued by
+// Keys; operators send "Authorization: Bearer <admin token>"; staff signed
+// in through the identity provider carry a session cookie. Callers without
+// any of these are anonymous and get the scopes the deployment grants
+// anonymous callers, which by default keeps the API open.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scopes limit what a principal may do with posts.
+const (
+	ScopeRead  = "posts:read"
+	ScopeWrite = "posts:write"
+)
+
+// Scopes lists every scope.
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+// RoleAdmin may manage every user's API keys.
+const RoleAdmin = "admin"
+
+// ErrInvalidCredentials means the Authorization header was present but
+// named no valid key or token.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is who made a request.
+type Principal struct {
+	// User is empty for anonymous callers.
+	User   string
+	Roles  []string
+	Scopes []string
+	// APIKeyID is set when the caller used an API key.
+	APIKeyID int
+	// Session is set when the caller was identified by a session cookie.
+	Session bool
+}
+
+// Can reports whether p holds scope.
+func (p Principal) Can(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+func (p Principal) IsAdmin() bool {
+	return slices.Contains(p.Roles, RoleAdmin)
+}
+
+// Anonymous reports whether the caller sent no credentials.
+func (p Principal) Anonymous() bool {
+	return p.User == ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal of the request ctx belongs to. It
+// reports false when the server runs without authentication.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// Authenticator reads the credentials of a request.
+type Authenticator struct {
+	// Keys checks API keys. Without it the ApiKey scheme is refused.
+	Keys *Keys
+	// AdminToken, if set, is accepted as a Bearer token and gives the
+	// admin role and every scope.
+	AdminToken string
+	// Sessions, if set, identifies callers by their session cookie when
+	// they send no Authorization header.
+	Sessions *Sessions
+	// Anonymous are the scopes of callers without credentials.
+	Anonymous []string
+}
+
+// Authenticate returns the principal of r, or ErrInvalidCredentials when r
+// carries credentials that do not check out. An invalid or expired session
+// cookie is ignored, as browsers keep sending it.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		if a.Sessions != nil {
+			if p, ok := a.Sessions.Read(r); ok {
+				return p, nil
+			}
+		}
+		return Principal{Scopes: a.Anonymous}, nil
+	}
+
+	scheme, credentials, _ := strings.Cut(header, " ")
+	credentials = strings.TrimSpace(credentials)
+	switch {
+	case strings.EqualFold(scheme, "ApiKey") && a.Keys != nil:
+		return a.Keys.Authenticate(r.Context(), credentials)
+	case strings.EqualFold(scheme, "Bearer") && a.AdminToken != "" &&
+		subtle.ConstantTimeCompare([]byte(credentials), []byte(a.AdminToken)) == 1:
+		return Principal{User: RoleAdmin, Roles: []string{RoleAdmin}, Scopes: Scopes}, nil
+	}
+	return Principal{}, ErrInvalidCredentials
+}
@@ -0,0 +1,190 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func newStore(t *testing.T) database.APIKeyStore {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	return db.(database.APIKeyStore)
+}
+
+func TestKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	keys := auth.NewKeys(store)
+
+	key, plain, err := keys.Issue(ctx, "alice", "ci", []string{auth.ScopeWrite, auth.ScopeRead, auth.ScopeRead}, nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(plain, key.Prefix+"_"), "the key starts with its prefix")
+	assert.Regexp(t, `^blog_[0-9a-f]{8}$`, key.Prefix)
+	assert.Equal(t, []string{auth.ScopeRead, auth.ScopeWrite}, key.Scopes)
+	assert.NotContains(t, key.Hash, plain[len(key.Prefix):], "only a hash is stored")
+
+	p, err := keys.Authenticate(ctx, plain)
+	require.NoError(t, err)
+	assert.Equal(t, auth.Principal{User: "alice", Scopes: key.Scopes, APIKeyID: key.ID}, p)
+
+	stored, err := keys.Get(ctx, key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.LastUsedAt, "authenticating records the use")
+
+	_, err = keys.Authenticate(ctx, key.Prefix+"_wrong")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = keys.Authenticate(ctx, "not-a-key")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, err = keys.Revoke(ctx, key.ID)
+	require.NoError(t, err)
+	_, err = keys.Authenticate(ctx, plain)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials, "revoked keys are refused")
+
+	_, err = keys.Revoke(ctx, 99)
+	assert.ErrorIs(t, err, auth.ErrKeyNotFound)
+}
+
+func TestKeysRefuseExpired(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	keys := auth.NewKeys(store)
+
+	plain := "blog_0000abcd_secret"
+	sum := sha256.Sum256([]byte(plain))
+	expired := time.Now().Add(-time.Minute)
+	_, err := store.CreateAPIKey(ctx, models.APIKey{
+		User: "alice", Prefix: "blog_0000abcd", Hash: hex.EncodeToString(sum[:]), Scopes: []string{auth.ScopeRead}, ExpiresAt: &expired,
+	})
+	require.NoError(t, err)
+
+	_, err = keys.Authenticate(ctx, plain)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestIssueRejects(t *testing.T) {
+	keys := auth.NewKeys(newStore(t))
+	past := time.Now().Add(-time.Hour)
+
+	for name, tc := range map[string]struct {
+		user    string
+		scopes  []string
+		expires *time.Time
+	}{
+		"no user":       {"", []string{auth.ScopeRead}, nil},
+		"no scopes":     {"alice", nil, nil},
+		"unknown scope": {"alice", []string{"posts:delete"}, nil},
+		"expired":       {"alice", []string{auth.ScopeRead}, &past},
+	} {
+		_, _, err := keys.Issue(context.Background(), tc.user, "k", tc.scopes, tc.expires)
+		assert.ErrorIs(t, err, auth.ErrInvalidKeyRequest, name)
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	keys := auth.NewKeys(newStore(t))
+	_, plain, err := keys.Issue(context.Background(), "bot", "reader", []string{auth.ScopeRead}, nil)
+	require.NoError(t, err)
+	a := &auth.Authenticator{Keys: keys, AdminToken: "s3cret", Anonymous: []string{auth.ScopeRead}}
+
+	authenticate := func(header string) (auth.Principal, error) {
+		r := httptest.NewRequest(http.MethodGet, "/posts", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		return a.Authenticate(r)
+	}
+
+	p, err := authenticate("")
+	require.NoError(t, err)
+	assert.True(t, p.Anonymous())
+	assert.True(t, p.Can(auth.ScopeRead))
+	assert.False(t, p.Can(auth.ScopeWrite))
+
+	p, err = authenticate("ApiKey " + plain)
+	require.NoError(t, err)
+	assert.Equal(t, "bot", p.User)
+	assert.False(t, p.IsAdmin())
+
+	p, err = authenticate("Bearer s3cret")
+	require.NoError(t, err)
+	assert.True(t, p.IsAdmin())
+	assert.True(t, p.Can(auth.ScopeWrite))
+
+	for _, header := range []string{"Bearer wrong", "ApiKey blog_00000000_wrong", "Basic YTpi"} {
+		_, err = authenticate(header)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials, header)
+	}
+}
+
+// roundTrip returns a request carrying the cookies w set.
+func roundTrip(w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestSessions(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	sessions := auth.NewSessions(auth.SessionOptions{Secret: secret, Tenant: "default", Path: "/blog"})
+
+	w := httptest.NewRecorder()
+	require.NoError(t, sessions.Start(w, auth.Principal{User: "alice", Roles: []string{auth.RoleEditor}}))
+	cookie := w.Result().Cookies()[0]
+	assert.Equal(t, auth.SessionCookie, cookie.Name)
+	assert.Equal(t, "/blog", cookie.Path)
+	assert.True(t, cookie.HttpOnly)
+
+	p, ok := sessions.Read(roundTrip(w))
+	require.True(t, ok)
+	assert.Equal(t, "alice", p.User)
+	assert.True(t, p.Session)
+	assert.True(t, p.Can(auth.ScopeWrite))
+
+	a := &auth.Authenticator{Sessions: sessions, Anonymous: []string{auth.ScopeRead}}
+	p, err := a.Authenticate(roundTrip(w))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", p.User)
+
+	other := auth.NewSessions(auth.SessionOptions{Secret: secret, Tenant: "other"})
+	_, ok = other.Read(roundTrip(w))
+	assert.False(t, ok, "another tenant's cookie is ignored")
+
+	forged := auth.NewSessions(auth.SessionOptions{Secret: []byte("guessed"), Tenant: "default"})
+	_, ok = forged.Read(roundTrip(w))
+	assert.False(t, ok, "a cookie signed with another secret is ignored")
+
+	tampered := roundTrip(httptest.NewRecorder())
+	tampered.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: "e30." + strings.Repeat("A", 43)})
+	_, ok = sessions.Read(tampered)
+	assert.False(t, ok)
+	p, err = a.Authenticate(tampered)
+	require.NoError(t, err, "a bad cookie leaves the caller anonymous")
+	assert.True(t, p.Anonymous())
+
+	w = httptest.NewRecorder()
+	sessions.End(w)
+	assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
+
+func TestRoleScopes(t *testing.T) {
+	assert.Equal(t, auth.Scopes, auth.RoleScopes([]string{auth.RoleAdmin}))
+	assert.Equal(t, auth.Scopes, auth.RoleScopes([]string{"reviewer", auth.RoleEditor}))
+	assert.Equal(t, []string{auth.ScopeRead}, auth.RoleScopes(nil))
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// keyPrefix starts every key, so leaked keys are easy to scan for.
+const keyPrefix = "blog_"
+
+// touchInterval limits how often last_used_at is written for a busy key.
+const touchInterval = time.Minute
+
+// AuditEntityAPIKey is the entity of audit entries about API keys.
+const AuditEntityAPIKey = "api_key"
+
+var (
+	ErrKeyNotFound = errors.New("API key not found")
+	// ErrInvalidKeyRequest wraps what is wrong with a key to issue.
+	ErrInvalidKeyRequest = errors.New("invalid API key request")
+)
+
+// Keys issues, lists and checks API keys.
+//
+// A key is "blog_<id>_<secret>": the id part is stored as the key's prefix
+// to find it again and to tell keys apart in lists, and only a SHA-256 hash
+// of the whole key is stored, so the key cannot be shown again once
+// issued.
+type Keys struct {
+	store database.APIKeyStore
+	audit database.AuditedWriter
+	now   func() time.Time
+
+	// Describe, when set and the store keeps an audit log, records every
+	// key issued or revoked in it, in the transaction making the change.
+	// It is audit.APIKeys, which this package cannot import.
+	Describe func(ctx context.Context) database.AuditFunc[models.APIKey]
+}
+
+func NewKeys(store database.APIKeyStore) *Keys {
+	k := &Keys{store: store, now: time.Now}
+	k.audit, _ = store.(database.AuditedWriter)
+	return k
+}
+
+// Issue stores a key for user with the given name, scopes and optional
+// expiry, and returns it with the key, which is not kept.
+func (k *Keys) Issue(ctx context.Context, user, name string, scopes []string, expiresAt *time.Time) (models.APIKey, string, error) {
+	switch {
+	case user == "":
+		return models.APIKey{}, "", fmt.Errorf("%w: user is required", ErrInvalidKeyRequest)
+	case len(scopes) == 0:
+		return models.APIKey{}, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidKeyRequest)
+	case expiresAt != nil && !expiresAt.After(k.now()):
+		return models.APIKey{}, "", fmt.Errorf("%w: expires_at is in the past", ErrInvalidKeyRequest)
+	}
+	for _, s := range scopes {
+		if !slices.Contains(Scopes, s) {
+			return models.APIKey{}, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidKeyRequest, s)
+		}
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	prefix := keyPrefix + id
+	plain := prefix + "_" + secret
+
+	key := models.APIKey{
+		User:      user,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash(plain),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		ExpiresAt: expiresAt,
+	}
+	if k.audit != nil && k.Describe != nil {
+		key, err = k.audit.CreateAPIKeyAudited(ctx, key, k.Describe(ctx))
+	} else {
+		key, err = k.store.CreateAPIKey(ctx, key)
+	}
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	return key, plain, nil
+}
+
+// List returns the keys of user, or of every user when user is empty.
+func (k *Keys) List(ctx context.Context, user string) ([]models.APIKey, error) {
+	return k.store.ListAPIKeys(ctx, user)
+}
+
+// Get returns the key with id.
+func (k *Keys) Get(ctx context.Context, id int) (models.APIKey, error) {
+	key, err := k.store.GetAPIKey(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return models.APIKey{}, ErrKeyNotFound
+	}
+	return key, err
+}
+
+// Revoke stops the key with id from authenticating. Revoking a revoked key
+// returns it unchanged.
+func (k *Keys) Revoke(ctx context.Context, id int) (models.APIKey, error) {
+	var key models.APIKey
+	var err error
+	if k.audit != nil && k.Describe != nil {
+		key, err = k.audit.RevokeAPIKeyAudited(ctx, id, k.now(), k.Describe(ctx))
+	} else {
+		key, err = k.store.RevokeAPIKey(ctx, id, k.now())
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		return models.APIKey{}, ErrKeyNotFound
+	}
+	return key, err
+}
+
+// Authenticate returns the principal of an active key, recording that it
+// was used. Any other key yields ErrInvalidCredentials.
+func (k *Keys) Authenticate(ctx context.Context, plain string) (Principal, error) {
+	prefix, _, ok := cutPrefix(plain)
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	key, err := k.store.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, database.ErrNotFound) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	now := k.now()
+	if subtle.ConstantTimeCompare([]byte(hash(plain)), []byte(key.Hash)) != 1 || !key.Active(now) {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+		if err := k.store.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.Printf("auth: recording use of API key %s: %v", key.Prefix, err)
+		}
+	}
+	return Principal{User: key.User, Scopes: key.Scopes, APIKeyID: key.ID}, nil
+}
+
+// cutPrefix splits "blog_<id>_<secret>" after the id.
+func cutPrefix(plain string) (prefix, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(plain, keyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return keyPrefix + id, secret, true
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SessionCookie holds the session of a signed-in user.
+const SessionCookie = "blog_session"
+
+// RoleEditor may write posts. Signed-in users with neither it nor
+// RoleAdmin may only read them.
+const RoleEditor = "editor"
+
+// RoleScopes returns the scopes a signed-in user with roles holds.
+func RoleScopes(roles []string) []string {
+	for _, r := range roles {
+		if r == RoleAdmin || r == RoleEditor {
+			return Scopes
+		}
+	}
+	return []string{ScopeRead}
+}
+
+// SessionOptions configures the cookies of one tenant.
+type SessionOptions struct {
+	// Secret signs the cookies. Every server sharing sessions needs the
+	// same secret.
+	Secret []byte
+	// Tenant binds cookies to one tenant, so a cookie sent to another
+	// tenant on the same host is ignored.
+	Tenant string
+	// TTL is how long a session lasts. Defaults to 12 hours.
+	TTL time.Duration
+	// Path scopes the cookies, such as the tenant's path prefix. Defaults
+	// to "/".
+	Path string
+	// Secure restricts the cookies to HTTPS.
+	Secure bool
+}
+
+// Sessions keeps signed-in users in signed cookies, so no server-side
+// state is needed and any server holding the secret can read them.
+type Sessions struct {
+	opts SessionOptions
+	now  func() time.Time
+}
+
+// NewSessions returns the sessions of one tenant.
+func NewSessions(opts SessionOptions) *Sessions {
+	if opts.TTL <= 0 {
+		opts.TTL = 12 * time.Hour
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	return &Sessions{opts: opts, now: time.Now}
+}
+
+type session struct {
+	User  string   `json:"user"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Start signs p in.
+func (s *Sessions) Start(w http.ResponseWriter, p Principal) error {
+	return s.SetCookie(w, SessionCookie, session{User: p.User, Roles: p.Roles}, s.opts.TTL)
+}
+
+// Read returns the principal of the session r carries, if any.
+func (s *Sessions) Read(r *http.Request) (Principal, bool) {
+	var sess session
+	if !s.Cookie(r, SessionCookie, &sess) || sess.User == "" {
+		return Principal{}, false
+	}
+	return Principal{User: sess.User, Roles: sess.Roles, Scopes: RoleScopes(sess.Roles), Session: true}, true
+}
+
+// End signs the user out.
+func (s *Sessions) End(w http.ResponseWriter) {
+	s.ClearCookie(w, SessionCookie)
+}
+
+// sealed is what a cookie holds, before it is signed.
+type sealed struct {
+	Tenant  string          `json:"tenant"`
+	Expires int64           `json:"exp"`
+	Value   json.RawMessage `json:"v"`
+}
+
+// SetCookie stores v, encoded as JSON, in the signed cookie name for ttl.
+func (s *Sessions) SetCookie(w http.ResponseWriter, name string, v any, ttl time.Duration) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	expires := s.now().Add(ttl)
+	payload, err := json.Marshal(sealed{Tenant: s.opts.Tenant, Expires: expires.Unix(), Value: value})
+	if err != nil {
+		return err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	http.SetCookie(w, s.cookie(name, encoded+"."+s.sign(encoded), expires))
+	return nil
+}
+
+// Cookie decodes the signed cookie name into v. It reports false when the
+// cookie is missing, tampered with, expired or another tenant's.
+func (s *Sessions) Cookie(r *http.Request, name string, v any) bool {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return false
+	}
+	encoded, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(encoded))) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	var sc sealed
+	if json.Unmarshal(payload, &sc) != nil || sc.Tenant != s.opts.Tenant || s.now().Unix() >= sc.Expires {
+		return false
+	}
+	return json.Unmarshal(sc.Value, v) == nil
+}
+
+// ClearCookie removes the cookie name.
+func (s *Sessions) ClearCookie(w http.ResponseWriter, name string) {
+	c := s.cookie(name, "", time.Unix(0, 0))
+	c.MaxAge = -1
+	http.SetCookie(w, c)
+}
+
+func (s *Sessions) cookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     s.opts.Path,
+		Expires:  expires,
+		Secure:   s.opts.Secure,
+		HttpOnly: true,
+		// Lax still sends the cookies when the identity provider redirects
+		// back to the callback.
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (s *Sessions) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.opts.Secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,43 @@
+// Package cache provides byte caches with per-entry TTLs behind a common
+// interface, so callers can switch between in-process and Redis storage.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores opaque values by key. A missing or expired key is reported
+// as found == false rather than an error.
+type Cache interface {
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Prefixed returns a view of c that prepends prefix to every key, so
+// several users can share one cache without their keys colliding.
+func Prefixed(c Cache, prefix string) Cache {
+	return prefixed{c, prefix}
+}
+
+type prefixed struct {
+	c      Cache
+	prefix string
+}
+
+func (p prefixed) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return p.c.Get(ctx, p.prefix+key)
+}
+
+func (p prefixed) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return p.c.Set(ctx, p.prefix+key, value, ttl)
+}
+
+func (p prefixed) Delete(ctx context.Context, keys ...string) error {
+	full := make([]string, len(keys))
+	for i, key := range keys {
+		full[i] = p.prefix + key
+	}
+	return p.c.Delete(ctx, full...)
+}
@@ -0,0 +1,207 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/cache"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), 0))
+
+	// Touch a so b becomes the least recently used entry.
+	v, found, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("1"), v)
+
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), 0))
+	assert.Equal(t, 2, c.Len())
+
+	_, found, _ = c.Get(ctx, "b")
+	assert.False(t, found, "b was evicted")
+	_, found, _ = c.Get(ctx, "c")
+	assert.True(t, found)
+
+	require.NoError(t, c.Delete(ctx, "a", "missing"))
+	_, found, _ = c.Get(ctx, "a")
+	assert.False(t, found)
+}
+
+func TestLRUExpiry(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+
+	require.NoError(t, c.Set(ctx, "k", []byte("v"), 10*time.Millisecond))
+	_, found, _ := c.Get(ctx, "k")
+	assert.True(t, found)
+
+	time.Sleep(20 * time.Millisecond)
+	_, found, _ = c.Get(ctx, "k")
+	assert.False(t, found)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestPrefixed(t *testing.T) {
+	ctx := context.Background()
+	shared := cache.NewLRU(10)
+	a := cache.Prefixed(shared, "a:")
+	b := cache.Prefixed(shared, "b:")
+
+	require.NoError(t, a.Set(ctx, "k", []byte("1"), 0))
+	require.NoError(t, b.Set(ctx, "k", []byte("2"), 0))
+
+	v, found, err := a.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("1"), v, "the views do not overwrite each other")
+
+	require.NoError(t, b.Delete(ctx, "k"))
+	_, found, _ = b.Get(ctx, "k")
+	assert.False(t, found)
+	_, found, _ = a.Get(ctx, "k")
+	assert.True(t, found, "deleting in one view leaves the other alone")
+	_, found, _ = shared.Get(ctx, "a:k")
+	assert.True(t, found)
+}
+
+// fakeRedis is a minimal RESP server understanding GET, SET [PX], DEL and
+// AUTH.
+type fakeRedis struct {
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	srv := &fakeRedis{data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv, ln.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			reply = "+OK\r\n"
+		case "GET":
+			if v, ok := s.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			s.data[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "DEL":
+			n := 0
+			for _, k := range args[1:] {
+				if _, ok := s.data[k]; ok {
+					delete(s.data, k)
+					n++
+				}
+			}
+			reply = fmt.Sprintf(":%d\r\n", n)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	srv, addr := startFakeRedis(t)
+	c := cache.NewRedis(cache.RedisOptions{Addr: addr, Password: "secret"})
+	defer c.Close()
+	ctx := context.Background()
+
+	_, found, err := c.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, c.Set(ctx, "k", []byte("value\r\nwith newline"), 1500*time.Millisecond))
+	v, found, err := c.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("value\r\nwith newline"), v)
+
+	require.NoError(t, c.Delete(ctx, "k"))
+	_, found, err = c.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, "AUTH secret", srv.commands[0], "authenticates once per connection")
+	assert.Contains(t, srv.commands, "SET k value\r\nwith newline PX 1500")
+}
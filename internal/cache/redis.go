@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisOptions configures a Redis connection. Anything speaking the RESP
+// protocol (Redis, Valkey, KeyDB, Dragonfly) works.
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+	PoolSize int
+	Timeout  time.Duration
+}
+
+// Redis is a Cache backed by a Redis-protocol server. It only needs GET,
+// SET with PX and DEL, so it talks RESP directly instead of pulling in a
+// client library.
+type Redis struct {
+	opts  RedisOptions
+	conns chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// redisError is an error reply sent by the server. The connection stays
+// usable after one.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// NewRedis creates a client. Connections are dialled lazily and kept in a
+// pool of PoolSize idle connections.
+func NewRedis(opts RedisOptions) *Redis {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 10
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	return &Redis{opts: opts, conns: make(chan *redisConn, opts.PoolSize)}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Close closes the idle connections.
+func (c *Redis) Close() error {
+	for {
+		select {
+		case conn := <-c.conns:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends one command and reads its reply. Connections that fail at the
+// protocol level are discarded rather than returned to the pool.
+func (c *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	reply, err := conn.roundTrip(args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return reply, err
+}
+
+func (c *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.conns:
+		return conn, nil
+	default:
+	}
+
+	d := net.Dialer{Timeout: c.opts.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+
+	if c.opts.Password != "" {
+		if _, err := conn.roundTrip([]string{"AUTH", c.opts.Password}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := conn.roundTrip([]string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *Redis) put(conn *redisConn) {
+	select {
+	case c.conns <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// roundTrip writes args as a RESP array of bulk strings and reads the reply.
+func (c *redisConn) roundTrip(args []string) (interface{}, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply decodes one RESP2 reply: simple strings and bulk strings as
+// []byte, integers as int64, arrays as []interface{} and nil bulk strings
+// or arrays as nil.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return []byte(payload), nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
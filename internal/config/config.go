@@ -1,16 +1,85 @@
 package config
 
 import (
+	"crypto/rand"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/feed"
+	"olbcloud.com/webapi/internal/graph"
+	"olbcloud.com/webapi/internal/oidc"
+	"olbcloud.com/webapi/internal/outbox"
+	"olbcloud.com/webapi/internal/presence"
+	"olbcloud.com/webapi/internal/stream"
+	"olbcloud.com/webapi/internal/tenant"
+	"olbcloud.com/webapi/internal/webhooks"
 )
 
 type Config struct {
-	DBType      string
-	PostgresURL string
-	MongoDBURL  string
+	DBType             string
+	PostgresURL        string
+	MongoDBURL         string
+	MongoDBDatabase    string
+	MemorySnapshotPath string
+	SQLitePath         string
+	AutoMigrate        bool
+	DBOptions          database.Options
+	CacheBackend       string
+	CacheTTL           time.Duration
+	CacheSize          int
+	RedisAddr          string
+	RedisPassword      string
+	RedisDB            int
+	CompressionMinSize int
+	CompressionZstd    bool
+	HTTPCacheControl   string
+	MaxBodyBytes       int64
+	MaxStreamBodyBytes int64
+	V1Deprecation      time.Time
+	V1Sunset           time.Time
+	ShutdownTimeout    time.Duration
+	SiteBaseURL        string
+	RobotsDisallow     []string
+	Feed               feed.Options
+	GraphQL            graph.Limits
+	GRPCPort           string
+	GRPCAuthToken      string
+	Webhooks           webhooks.Options
+	Outbox             outbox.Options
+	Stream             stream.Options
+	Presence           presence.Options
+	// AdminToken is accepted as "Authorization: Bearer <token>" with every
+	// scope, and may manage every user's API keys.
+	AdminToken string
+	// AnonymousScopes are granted to requests without credentials. The
+	// default keeps the API open; posts:read alone requires a key to write.
+	AnonymousScopes []string
+	// Sessions configures the cookies of users signed in through OIDC.
+	// Each tenant sets its own Tenant and Path.
+	Sessions auth.SessionOptions
+	// OIDC signs staff in when OIDC_ISSUER_URL is set. Each tenant sets its
+	// own BaseURL and so its callback.
+	OIDC oidc.Options
+	// HSTSMaxAge, ContentSecurityPolicy, FrameAncestors and ReferrerPolicy
+	// set the security headers of every response; empty strings use the
+	// server's defaults and "-" leaves a header out.
+	HSTSMaxAge            time.Duration
+	ContentSecurityPolicy string
+	FrameAncestors        string
+	ReferrerPolicy        string
+	// CSRFExempt lists routes, as patterns such as "POST /graphql", that
+	// browsers signed in with a session cookie may call without a CSRF
+	// token.
+	CSRFExempt []string
+	// Tenants are the blogs served. Without TENANTS_FILE there is a single
+	// default tenant built from the settings above.
+	Tenants []tenant.Tenant
 }
 
 func LoadConfig() *Config {
@@ -19,9 +88,253 @@ func LoadConfig() *Config {
 		log.Println("Warning: No .env file found, relying on system environment variables")
 	}
 
-	return &Config{
-		DBType:      os.Getenv("DB_TYPE"),
-		PostgresURL: os.Getenv("POSTGRESQL_URL"),
-		MongoDBURL:  os.Getenv("MONGODB_URL"),
+	// FEED_BASE_URL is the older name of SITE_BASE_URL, from when only the
+	// feeds needed it.
+	baseURL := strings.TrimSuffix(getEnv("SITE_BASE_URL", getEnv("FEED_BASE_URL", "http://localhost:8080")), "/")
+
+	cfg := &Config{
+		DBType:             os.Getenv("DB_TYPE"),
+		PostgresURL:        os.Getenv("POSTGRESQL_URL"),
+		MongoDBURL:         os.Getenv("MONGODB_URL"),
+		MongoDBDatabase:    getEnv("MONGODB_DATABASE", "blog"),
+		MemorySnapshotPath: os.Getenv("MEMORY_SNAPSHOT_PATH"),
+		SQLitePath:         os.Getenv("SQLITE_PATH"),
+		AutoMigrate:        os.Getenv("DB_AUTO_MIGRATE") == "true",
+		DBOptions: database.Options{
+			ConnectTimeout:  getEnvDuration("DB_CONNECT_TIMEOUT", 10*time.Second),
+			MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+			MinOpenConns:    getEnvInt("DB_MIN_OPEN_CONNS", 0),
+			MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 25),
+			ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			Retry: database.RetryPolicy{
+				MaxAttempts:    getEnvInt("DB_RETRY_ATTEMPTS", 5),
+				InitialBackoff: getEnvDuration("DB_RETRY_INITIAL_BACKOFF", 200*time.Millisecond),
+				MaxBackoff:     getEnvDuration("DB_RETRY_MAX_BACKOFF", 5*time.Second),
+			},
+			Outbox: os.Getenv("DB_OUTBOX") == "true",
+		},
+		CacheBackend:  os.Getenv("CACHE_BACKEND"),
+		CacheTTL:      getEnvDuration("CACHE_TTL", 30*time.Second),
+		CacheSize:     getEnvInt("CACHE_SIZE", 1000),
+		RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword: os.Getenv("REDIS_PASSWORD"),
+		RedisDB:       getEnvInt("REDIS_DB", 0),
+
+		CompressionMinSize: getEnvInt("HTTP_COMPRESSION_MIN_SIZE", 1024),
+		CompressionZstd:    os.Getenv("HTTP_COMPRESSION_ZSTD") == "true",
+		HTTPCacheControl:   getEnv("HTTP_CACHE_CONTROL", "no-cache"),
+		MaxBodyBytes:       int64(getEnvInt("HTTP_MAX_BODY_BYTES", 1<<20)),
+		MaxStreamBodyBytes: int64(getEnvInt("HTTP_MAX_STREAM_BODY_BYTES", 64<<20)),
+		V1Deprecation:      getEnvDate("API_V1_DEPRECATION"),
+		V1Sunset:           getEnvDate("API_V1_SUNSET"),
+		ShutdownTimeout:    getEnvDuration("HTTP_SHUTDOWN_TIMEOUT", 15*time.Second),
+
+		SiteBaseURL:    baseURL,
+		RobotsDisallow: getEnvList("ROBOTS_DISALLOW", []string{"/debug/", "/posts/export", "/posts/import"}),
+		Feed: feed.Options{
+			Title:         getEnv("FEED_TITLE", "A simple blog"),
+			Description:   os.Getenv("FEED_DESCRIPTION"),
+			BaseURL:       baseURL,
+			Items:         getEnvInt("FEED_ITEMS", 20),
+			FullContent:   os.Getenv("FEED_FULL_CONTENT") == "true",
+			SummaryLength: getEnvInt("FEED_SUMMARY_LENGTH", 280),
+		},
+		GraphQL: graph.Limits{
+			MaxDepth:      getEnvInt("GRAPHQL_MAX_DEPTH", graph.DefaultMaxDepth),
+			MaxComplexity: getEnvInt("GRAPHQL_MAX_COMPLEXITY", graph.DefaultMaxComplexity),
+		},
+		GRPCPort:      getEnv("GRPC_PORT", "9090"),
+		GRPCAuthToken: os.Getenv("GRPC_AUTH_TOKEN"),
+		Webhooks: webhooks.Options{
+			Workers: getEnvInt("WEBHOOK_WORKERS", 4),
+			Retry: database.RetryPolicy{
+				MaxAttempts:    getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+				InitialBackoff: getEnvDuration("WEBHOOK_INITIAL_BACKOFF", 30*time.Second),
+				MaxBackoff:     getEnvDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+			},
+			Timeout: getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		},
+		Outbox: outbox.Options{
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			Retention:    getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		},
+		Stream: stream.Options{
+			HistorySize: getEnvInt("STREAM_HISTORY_SIZE", 1000),
+			BufferSize:  getEnvInt("STREAM_BUFFER_SIZE", 64),
+			Heartbeat:   getEnvDuration("STREAM_HEARTBEAT", 15*time.Second),
+		},
+		Presence: presence.Options{
+			MaxMessageBytes: int64(getEnvInt("PRESENCE_MAX_MESSAGE_BYTES", 4096)),
+			PingInterval:    getEnvDuration("PRESENCE_PING_INTERVAL", 30*time.Second),
+			PongTimeout:     getEnvDuration("PRESENCE_PONG_TIMEOUT", time.Minute),
+			AllowedOrigins:  getEnvList("PRESENCE_ALLOWED_ORIGINS", nil),
+		},
+
+		AdminToken:      os.Getenv("API_ADMIN_TOKEN"),
+		AnonymousScopes: getEnvList("AUTH_ANONYMOUS_SCOPES", auth.Scopes),
+		Sessions: auth.SessionOptions{
+			Secret: sessionSecret(),
+			TTL:    getEnvDuration("SESSION_TTL", 12*time.Hour),
+			Secure: getEnv("SESSION_COOKIE_SECURE", strconv.FormatBool(strings.HasPrefix(baseURL, "https://"))) == "true",
+		},
+		OIDC: oidc.Options{
+			Issuer:       os.Getenv("OIDC_ISSUER_URL"),
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			Scopes:       getEnvList("OIDC_SCOPES", []string{"email", "profile"}),
+			UserClaim:    getEnv("OIDC_USER_CLAIM", "email"),
+			RolesClaim:   getEnv("OIDC_ROLES_CLAIM", "groups"),
+			RoleMap:      getEnvMap("OIDC_ROLE_MAP"),
+			DefaultRoles: getEnvList("OIDC_DEFAULT_ROLES", []string{auth.RoleEditor}),
+		},
+
+		HSTSMaxAge:            getEnvDuration("HTTP_HSTS_MAX_AGE", 365*24*time.Hour),
+		ContentSecurityPolicy: os.Getenv("HTTP_CONTENT_SECURITY_POLICY"),
+		FrameAncestors:        os.Getenv("HTTP_FRAME_ANCESTORS"),
+		ReferrerPolicy:        os.Getenv("HTTP_REFERRER_POLICY"),
+		CSRFExempt:            getEnvList("CSRF_EXEMPT", nil),
+	}
+	cfg.Tenants = loadTenants(cfg, getEnvList("CORS_ALLOWED_ORIGINS", []string{"*"}))
+	return cfg
+}
+
+// loadTenants reads TENANTS_FILE, filling in what a tenant leaves out from
+// the single-blog settings. A broken file is fatal: serving one tenant's
+// requests from another would be worse than not starting.
+func loadTenants(cfg *Config, corsOrigins []string) []tenant.Tenant {
+	defaults := tenant.Tenant{
+		ID:          database.DefaultTenant,
+		Title:       cfg.Feed.Title,
+		Description: cfg.Feed.Description,
+		BaseURL:     cfg.SiteBaseURL,
+		CORSOrigins: corsOrigins,
+	}
+	path := os.Getenv("TENANTS_FILE")
+	if path == "" {
+		return []tenant.Tenant{defaults}
+	}
+
+	tenants, err := tenant.Load(path)
+	if err != nil {
+		log.Fatal("Failed to load TENANTS_FILE: ", err)
+	}
+	for i, t := range tenants {
+		if t.Title == "" {
+			t.Title = defaults.Title
+		}
+		if t.Description == "" {
+			t.Description = defaults.Description
+		}
+		if t.BaseURL == "" {
+			t.BaseURL = defaults.BaseURL + t.PathPrefix
+		}
+		t.BaseURL = strings.TrimSuffix(t.BaseURL, "/")
+		if t.CORSOrigins == nil {
+			t.CORSOrigins = defaults.CORSOrigins
+		}
+		tenants[i] = t
+	}
+	return tenants
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using %d", key, v, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvList splits a comma-separated value. Set the variable to "-" for an
+// empty list.
+func getEnvList(key string, fallback []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" && item != "-" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvMap parses "key=value" pairs separated by commas.
+func getEnvMap(key string) map[string]string {
+	m := map[string]string{}
+	for _, pair := range getEnvList(key, nil) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			log.Printf("Warning: invalid %s entry %q, ignoring it", key, pair)
+			continue
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m
+}
+
+// sessionSecret reads SESSION_SECRET. Without it a random secret is used,
+// so sessions end when the server restarts and are not shared between
+// servers.
+func sessionSecret() []byte {
+	if v := os.Getenv("SESSION_SECRET"); v != "" {
+		if len(v) < 32 {
+			log.Println("Warning: SESSION_SECRET is shorter than 32 bytes")
+		}
+		return []byte(v)
+	}
+	if os.Getenv("OIDC_ISSUER_URL") != "" {
+		log.Println("Warning: SESSION_SECRET is not set, using a random secret; sessions will not survive a restart")
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal("Failed to generate a session secret: ", err)
+	}
+	return secret
+}
+
+// getEnvDuration parses values such as "500ms" or "30s".
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using %s", key, v, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getEnvDate parses a YYYY-MM-DD date as midnight UTC. It returns the zero
+// time when the variable is unset or invalid, leaving the default to the
+// caller.
+func getEnvDate(key string) time.Time {
+	v := os.Getenv(key)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using the default", key, v)
+		return time.Time{}
 	}
+	return t
 }
@@ -1,19 +1,184 @@
 package database
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"olbcloud.com/webapi/internal/migrate"
 	"olbcloud.com/webapi/internal/models"
 )
 
 var ErrFailedConnection = errors.New("failed to connect to the database")
 var ErrNotFound = errors.New("entity not found")
 
+// ErrTenantMismatch is returned when a write would overwrite another
+// tenant's data, such as an upsert import reusing its post IDs.
+var ErrTenantMismatch = errors.New("entity belongs to another tenant")
+
+// DefaultTenant owns the data of single-tenant deployments and everything
+// stored before tenants existed. Backends open scoped to it.
+const DefaultTenant = "default"
+
 // DB interface defines database operations
 type DB interface {
 	GetPosts() ([]models.Post, error)
 	GetPostByID(id string) (models.Post, error)
 	CreatePost(post models.Post) (models.Post, error)
 	UpdatePost(post models.Post) (models.Post, error)
+	// GetPostsByIDs fetches several posts in one query, in ID order. IDs
+	// without a post are skipped rather than reported as ErrNotFound.
+	GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error)
+	// ListPosts returns up to limit posts with an ID above after, in ID
+	// order, so callers can page with the last ID they saw.
+	ListPosts(ctx context.Context, after, limit int) ([]models.Post, error)
+	// CountPosts returns how many posts there are.
+	CountPosts(ctx context.Context) (int, error)
+	// EachPost calls fn for every post in ID order, streaming them from the
+	// backend rather than loading them all. It stops at fn's first error.
+	EachPost(ctx context.Context, fn func(models.Post) error) error
+	// ImportPosts stores posts all-or-nothing and returns them as stored.
+	// Timestamps are kept when set and default to the current time.
+	ImportPosts(ctx context.Context, posts []models.Post, mode ImportMode) ([]models.Post, error)
+	// ForTenant returns a view of the same database that only reads and
+	// writes the tenant's posts, webhooks, API keys, audit entries and
+	// outbox events. Views share the connection, so only the database they
+	// came from is closed.
+	ForTenant(tenantID string) DB
 	Close() error
 }
+
+// ImportMode selects how ImportPosts treats IDs.
+type ImportMode string
+
+const (
+	// ImportInsert stores every post as a new one with a fresh ID.
+	ImportInsert ImportMode = "insert"
+	// ImportUpsert keeps the given IDs, replacing existing posts with the
+	// same ID. Later posts get IDs after the highest imported one.
+	ImportUpsert ImportMode = "upsert"
+)
+
+// WebhookStore keeps webhook subscriptions and their delivery log. Every
+// backend implements it.
+type WebhookStore interface {
+	// CreateWebhook assigns an ID and created_at.
+	CreateWebhook(ctx context.Context, hook models.Webhook) (models.Webhook, error)
+	GetWebhook(ctx context.Context, id int) (models.Webhook, error)
+	// ListWebhooks returns every webhook in ID order, or an empty slice.
+	ListWebhooks(ctx context.Context) ([]models.Webhook, error)
+	// DeleteWebhook removes the webhook and its deliveries.
+	DeleteWebhook(ctx context.Context, id int) error
+
+	// CreateDelivery assigns an ID, created_at and updated_at.
+	CreateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, id int) (models.WebhookDelivery, error)
+	// UpdateDelivery stores status, attempts, the last response and the
+	// next attempt time, and bumps updated_at.
+	UpdateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error)
+	// ListDeliveries returns a webhook's deliveries, newest first.
+	ListDeliveries(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error)
+	// PendingDeliveries returns every pending delivery, by next attempt.
+	PendingDeliveries(ctx context.Context) ([]models.WebhookDelivery, error)
+}
+
+// APIKeyStore keeps API keys. Every backend implements it.
+type APIKeyStore interface {
+	// CreateAPIKey assigns an ID and created_at.
+	CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	GetAPIKey(ctx context.Context, id int) (models.APIKey, error)
+	// GetAPIKeyByPrefix finds a key by the prefix a client presented.
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error)
+	// ListAPIKeys returns the keys of user, or every key when user is
+	// empty, in ID order, or an empty slice.
+	ListAPIKeys(ctx context.Context, user string) ([]models.APIKey, error)
+	// RevokeAPIKey sets revoked_at, keeping an earlier revocation, and
+	// returns the key.
+	RevokeAPIKey(ctx context.Context, id int, at time.Time) (models.APIKey, error)
+	// TouchAPIKey sets last_used_at.
+	TouchAPIKey(ctx context.Context, id int, at time.Time) error
+}
+
+// AuditLog keeps the audit trail append-only: entries can be added and
+// read, never changed or removed. Every backend implements it.
+type AuditLog interface {
+	// AppendAudit assigns an ID and, unless set, the time.
+	AppendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error)
+	// ListAudit returns the entries matching filter, newest first, or an
+	// empty slice.
+	ListAudit(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error)
+}
+
+// AuditFunc describes a change for the audit log from the entity before
+// and after it. before is nil for a creation and after for a deletion.
+type AuditFunc[T any] func(before, after *T) (models.AuditEntry, error)
+
+// AuditedWriter is implemented by backends that append the audit entry of
+// a write in the transaction that makes it, so neither is stored without
+// the other. The entry is described by the AuditFunc, from the entity as
+// read in that transaction; a nil AuditFunc appends nothing. Every backend
+// implements it. MongoDB has no transactions on a standalone server, so
+// there it appends the entry after the write, which stays stored if the
+// entry cannot be.
+type AuditedWriter interface {
+	CreatePostAudited(ctx context.Context, post models.Post, audit AuditFunc[models.Post]) (models.Post, error)
+	UpdatePostAudited(ctx context.Context, post models.Post, audit AuditFunc[models.Post]) (models.Post, error)
+	// ImportPostsAudited appends an entry per post, with the post an
+	// upsert replaced as before.
+	ImportPostsAudited(ctx context.Context, posts []models.Post, mode ImportMode, audit AuditFunc[models.Post]) ([]models.Post, error)
+	CreateWebhookAudited(ctx context.Context, hook models.Webhook, audit AuditFunc[models.Webhook]) (models.Webhook, error)
+	DeleteWebhookAudited(ctx context.Context, id int, audit AuditFunc[models.Webhook]) error
+	CreateAPIKeyAudited(ctx context.Context, key models.APIKey, audit AuditFunc[models.APIKey]) (models.APIKey, error)
+	// RevokeAPIKeyAudited appends nothing for a key already revoked.
+	RevokeAPIKeyAudited(ctx context.Context, id int, at time.Time, audit AuditFunc[models.APIKey]) (models.APIKey, error)
+}
+
+// AuditFilter selects audit entries. Zero fields match everything.
+type AuditFilter struct {
+	Actor    string
+	Entity   string
+	EntityID string
+	// Since and Until bound the entries' time, Since included and Until
+	// excluded.
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of entries; zero means no cap.
+	Limit int
+}
+
+// Matches reports whether e passes every condition of f but Limit.
+func (f AuditFilter) Matches(e models.AuditEntry) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Entity == "" || e.Entity == f.Entity) &&
+		(f.EntityID == "" || e.EntityID == f.EntityID) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Outbox is implemented by backends that can record post events in the
+// transaction that changes the post, so an event is never lost to a crash
+// between the write and its publication. Events are only recorded when
+// Options.Outbox is set.
+type Outbox interface {
+	// PendingEvents returns up to limit events not yet dispatched, oldest
+	// first.
+	PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	// EventsSince returns up to limit events with an ID above afterID,
+	// dispatched or not, oldest first. Events become visible in ID order,
+	// so a reader that saw an ID never misses an event before it.
+	EventsSince(ctx context.Context, afterID, limit int) ([]models.OutboxEvent, error)
+	// LastEventID returns the ID of the newest event, or 0 when there is
+	// none.
+	LastEventID(ctx context.Context) (int, error)
+	// MarkDispatched records that the event was published.
+	MarkDispatched(ctx context.Context, id int) error
+	// PurgeDispatched deletes events dispatched before the given time and
+	// returns how many were deleted.
+	PurgeDispatched(ctx context.Context, before time.Time) (int, error)
+}
+
+// Migrater is implemented by backends that manage their schema through
+// versioned migrations.
+type Migrater interface {
+	Migrator() (*migrate.Migrator, error)
+}
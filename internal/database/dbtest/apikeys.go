@@ -0,0 +1,96 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// RunAPIKeys exercises a database.APIKeyStore. store must hold no keys and
+// its ID sequence must start at 1. db is the root database store came from;
+// the tenant "tenant-keys" must hold no keys either.
+func RunAPIKeys(t *testing.T, db database.DB, store database.APIKeyStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	keys, err := store.ListAPIKeys(ctx, "")
+	require.NoError(t, err)
+	assert.NotNil(t, keys, "ListAPIKeys returns an empty slice, not nil")
+	assert.Empty(t, keys)
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	first, err := store.CreateAPIKey(ctx, models.APIKey{
+		ID: 42, User: "alice", Name: "ci", Prefix: "blog_aaaaaaaa", Hash: "hash-a",
+		Scopes: []string{"posts:read", "posts:write"}, ExpiresAt: &expires,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.ID, "CreateAPIKey assigns IDs and ignores the given one")
+	assert.Equal(t, "hash-a", first.Hash)
+	assert.Equal(t, []string{"posts:read", "posts:write"}, first.Scopes)
+	assert.False(t, first.CreatedAt.IsZero(), "CreateAPIKey sets created_at")
+	require.NotNil(t, first.ExpiresAt)
+	assert.True(t, expires.Equal(*first.ExpiresAt), "expires_at is kept")
+	assert.Nil(t, first.LastUsedAt)
+	assert.Nil(t, first.RevokedAt)
+
+	second, err := store.CreateAPIKey(ctx, models.APIKey{User: "bob", Name: "reader", Prefix: "blog_bbbbbbbb", Hash: "hash-b", Scopes: []string{"posts:read"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.ID)
+	assert.Nil(t, second.ExpiresAt)
+
+	got, err := store.GetAPIKeyByPrefix(ctx, "blog_bbbbbbbb")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, got.ID)
+	assert.Equal(t, "hash-b", got.Hash)
+	_, err = store.GetAPIKeyByPrefix(ctx, "blog_missing")
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	_, err = store.GetAPIKey(ctx, 99)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	keys, err = store.ListAPIKeys(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, first.ID, keys[0].ID)
+	keys, err = store.ListAPIKeys(ctx, "bob")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, second.ID, keys[0].ID)
+
+	used := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, store.TouchAPIKey(ctx, first.ID, used))
+	assert.ErrorIs(t, store.TouchAPIKey(ctx, 99, used), database.ErrNotFound)
+	got, err = store.GetAPIKey(ctx, first.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+	assert.True(t, used.Equal(*got.LastUsedAt))
+
+	revokedAt := used.Add(time.Minute)
+	revoked, err := store.RevokeAPIKey(ctx, first.ID, revokedAt)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	assert.True(t, revokedAt.Equal(*revoked.RevokedAt))
+	assert.False(t, revoked.Active(time.Now()))
+
+	again, err := store.RevokeAPIKey(ctx, first.ID, revokedAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(*again.RevokedAt), "revoking again keeps the first revocation")
+	_, err = store.RevokeAPIKey(ctx, 99, revokedAt)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	other := db.ForTenant("tenant-keys").(database.APIKeyStore)
+	_, err = other.GetAPIKey(ctx, second.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	_, err = other.GetAPIKeyByPrefix(ctx, "blog_bbbbbbbb")
+	assert.ErrorIs(t, err, database.ErrNotFound, "a key only authenticates against its own tenant")
+	keys, err = other.ListAPIKeys(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	_, err = other.RevokeAPIKey(ctx, second.ID, revokedAt)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	assert.ErrorIs(t, other.TouchAPIKey(ctx, second.ID, used), database.ErrNotFound)
+}
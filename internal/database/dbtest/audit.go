@@ -0,0 +1,85 @@
+package dbtest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// RunAudit exercises a database.AuditLog. log must hold no entries. db is
+// the root database log came from; the tenant "tenant-audit" must hold no
+// entries either.
+func RunAudit(t *testing.T, db database.DB, log database.AuditLog) {
+	t.Helper()
+	ctx := context.Background()
+
+	entries, err := log.ListAudit(ctx, database.AuditFilter{})
+	require.NoError(t, err)
+	assert.NotNil(t, entries, "ListAudit returns an empty slice, not nil")
+	assert.Empty(t, entries)
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []models.AuditEntry{
+		{Actor: "alice", Action: models.AuditCreate, Entity: "post", EntityID: "1",
+			After: json.RawMessage(`{"id":1,"title":"Hello"}`), RequestID: "req-1", ClientIP: "192.0.2.1"},
+		{Actor: "bob", Action: models.AuditUpdate, Entity: "post", EntityID: "1",
+			Before: json.RawMessage(`{"id":1,"title":"Hello"}`), After: json.RawMessage(`{"id":1,"title":"Hi"}`)},
+		{Actor: "alice", Action: models.AuditCreate, Entity: "post", EntityID: "2", After: json.RawMessage(`{"id":2}`)},
+	} {
+		e.Time = start.Add(time.Duration(i) * time.Hour)
+		stored, err := log.AppendAudit(ctx, e)
+		require.NoError(t, err)
+		assert.Positive(t, stored.ID)
+		assert.True(t, e.Time.Equal(stored.Time), "AppendAudit keeps a given time")
+	}
+	latest, err := log.AppendAudit(ctx, models.AuditEntry{Actor: "carol", Action: models.AuditDelete, Entity: "webhook", EntityID: "7",
+		Before: json.RawMessage(`{"id":7}`)})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), latest.Time, time.Minute, "AppendAudit defaults the time to now")
+
+	entries, err = log.ListAudit(ctx, database.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, latest.ID, entries[0].ID, "newest first")
+	assert.Equal(t, "carol", entries[0].Actor)
+	assert.Empty(t, entries[0].After, "an empty snapshot stays empty")
+	first := entries[3]
+	assert.Equal(t, "alice", first.Actor)
+	assert.Equal(t, models.AuditCreate, first.Action)
+	assert.Equal(t, "req-1", first.RequestID)
+	assert.Equal(t, "192.0.2.1", first.ClientIP)
+	assert.Empty(t, first.Before)
+	assert.JSONEq(t, `{"id":1,"title":"Hello"}`, string(first.After))
+
+	ids := func(filter database.AuditFilter) []string {
+		t.Helper()
+		entries, err := log.ListAudit(ctx, filter)
+		require.NoError(t, err)
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Actor+":"+e.Entity+":"+e.EntityID)
+		}
+		return got
+	}
+	assert.Equal(t, []string{"alice:post:2", "alice:post:1"}, ids(database.AuditFilter{Actor: "alice"}))
+	assert.Equal(t, []string{"bob:post:1", "alice:post:1"}, ids(database.AuditFilter{Entity: "post", EntityID: "1"}))
+	assert.Equal(t, []string{"carol:webhook:7"}, ids(database.AuditFilter{Entity: "webhook"}))
+	assert.Equal(t, []string{"alice:post:2", "bob:post:1"},
+		ids(database.AuditFilter{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)}), "Since is included, Until excluded")
+	assert.Equal(t, []string{"carol:webhook:7", "alice:post:2"}, ids(database.AuditFilter{Limit: 2}))
+
+	other := db.ForTenant("tenant-audit").(database.AuditLog)
+	entries, err = other.ListAudit(ctx, database.AuditFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, entries, "ListAudit skips other tenants")
+	stored, err := other.AppendAudit(ctx, models.AuditEntry{Actor: "dave", Action: models.AuditCreate, Entity: "post", EntityID: "9"})
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-audit", stored.TenantID)
+	assert.Len(t, ids(database.AuditFilter{}), 4, "entries stay with their tenant")
+}
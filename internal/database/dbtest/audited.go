@@ -0,0 +1,138 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/audit"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// failAudit cannot describe a change, which must undo it.
+func failAudit[T any](before, after *T) (models.AuditEntry, error) {
+	return models.AuditEntry{}, errors.New("cannot audit")
+}
+
+// RunAuditedWrites exercises a database.AuditedWriter through the tenant
+// "tenant-audited" of db, which must hold nothing.
+func RunAuditedWrites(t *testing.T, db database.DB) {
+	t.Helper()
+	ctx := context.Background()
+	view := db.ForTenant("tenant-audited")
+	w := view.(database.AuditedWriter)
+	log := view.(database.AuditLog)
+
+	entries := func(entity string) []models.AuditEntry {
+		t.Helper()
+		entries, err := log.ListAudit(ctx, database.AuditFilter{Entity: entity})
+		require.NoError(t, err)
+		return entries
+	}
+	posts := audit.Func(ctx, "post", func(p models.Post) int { return p.ID })
+
+	created, err := w.CreatePostAudited(ctx, models.Post{Title: "Hello", Body: "World"}, posts)
+	require.NoError(t, err)
+	_, err = w.CreatePostAudited(ctx, models.Post{Title: "Lost", Body: "b"}, failAudit[models.Post])
+	assert.Error(t, err)
+	n, err := view.CountPosts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "a creation that cannot be audited is not stored")
+
+	updated, err := w.UpdatePostAudited(ctx, models.Post{ID: created.ID, Title: "Hi", Body: "World"}, posts)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", updated.Title)
+	_, err = w.UpdatePostAudited(ctx, models.Post{ID: created.ID, Title: "Lost", Body: "b"}, failAudit[models.Post])
+	assert.Error(t, err)
+	got, err := view.GetPostsByIDs(ctx, []int{created.ID})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "Hi", got[0].Title, "an update that cannot be audited is not stored")
+	_, err = w.UpdatePostAudited(ctx, models.Post{ID: created.ID + 100000, Title: "t", Body: "b"}, posts)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	const newID = 7000001
+	_, err = w.ImportPostsAudited(ctx, []models.Post{
+		{ID: created.ID, Title: "Replaced", Body: "b"},
+		{ID: newID, Title: "New", Body: "b"},
+	}, database.ImportUpsert, failAudit[models.Post])
+	assert.Error(t, err)
+	got, err = view.GetPostsByIDs(ctx, []int{created.ID, newID})
+	require.NoError(t, err)
+	require.Len(t, got, 1, "an import that cannot be audited is not stored")
+	assert.Equal(t, "Hi", got[0].Title)
+	_, err = w.ImportPostsAudited(ctx, []models.Post{
+		{ID: created.ID, Title: "Replaced", Body: "b"},
+		{ID: newID, Title: "New", Body: "b"},
+	}, database.ImportUpsert, posts)
+	require.NoError(t, err)
+
+	postEntries := entries("post")
+	require.Len(t, postEntries, 4, "every stored change and only those is audited")
+	for i, want := range []struct{ action, before, after string }{
+		{models.AuditCreate, "", "New"},
+		{models.AuditUpdate, "Hi", "Replaced"},
+		{models.AuditUpdate, "Hello", "Hi"},
+		{models.AuditCreate, "", "Hello"},
+	} {
+		e := postEntries[i]
+		assert.Equal(t, want.action, e.Action, "entry %d", i)
+		assert.Equal(t, "tenant-audited", e.TenantID)
+		if want.before == "" {
+			assert.Empty(t, e.Before, "entry %d", i)
+		} else {
+			assert.Contains(t, string(e.Before), `"title":"`+want.before+`"`, "entry %d", i)
+		}
+		assert.Contains(t, string(e.After), `"title":"`+want.after+`"`, "entry %d", i)
+	}
+
+	webhooks := audit.Func(ctx, "webhook", func(h models.Webhook) int { return h.ID })
+	hook, err := w.CreateWebhookAudited(ctx, models.Webhook{URL: "https://example.com/audited", Events: []string{"post.created"}, Secret: "s"}, webhooks)
+	require.NoError(t, err)
+	_, err = w.CreateWebhookAudited(ctx, models.Webhook{URL: "https://example.com/lost", Events: []string{"post.created"}, Secret: "s"}, failAudit[models.Webhook])
+	assert.Error(t, err)
+	assert.Error(t, w.DeleteWebhookAudited(ctx, hook.ID, failAudit[models.Webhook]))
+	hooks, err := view.(database.WebhookStore).ListWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, hooks, 1, "webhook writes that cannot be audited are not stored")
+	require.NoError(t, w.DeleteWebhookAudited(ctx, hook.ID, webhooks))
+	assert.ErrorIs(t, w.DeleteWebhookAudited(ctx, hook.ID, webhooks), database.ErrNotFound)
+
+	hookEntries := entries("webhook")
+	require.Len(t, hookEntries, 2)
+	assert.Equal(t, models.AuditDelete, hookEntries[0].Action)
+	assert.Contains(t, string(hookEntries[0].Before), "https://example.com/audited")
+	assert.Empty(t, hookEntries[0].After)
+	assert.Equal(t, models.AuditCreate, hookEntries[1].Action)
+
+	keys := audit.Func(ctx, "api_key", func(k models.APIKey) int { return k.ID })
+	key, err := w.CreateAPIKeyAudited(ctx, models.APIKey{User: "alice", Name: "ci", Prefix: "blog_audited1", Hash: "hash", Scopes: []string{"posts:read"}}, keys)
+	require.NoError(t, err)
+	_, err = w.CreateAPIKeyAudited(ctx, models.APIKey{User: "alice", Name: "lost", Prefix: "blog_audited2", Hash: "hash", Scopes: []string{"posts:read"}}, failAudit[models.APIKey])
+	assert.Error(t, err)
+	_, err = w.RevokeAPIKeyAudited(ctx, key.ID, time.Now(), failAudit[models.APIKey])
+	assert.Error(t, err)
+	stored, err := view.(database.APIKeyStore).ListAPIKeys(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, stored, 1, "a key that cannot be audited is not stored")
+	assert.Nil(t, stored[0].RevokedAt, "a revocation that cannot be audited is not stored")
+	revoked, err := w.RevokeAPIKeyAudited(ctx, key.ID, time.Now(), keys)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+	again, err := w.RevokeAPIKeyAudited(ctx, key.ID, time.Now().Add(time.Hour), keys)
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Equal(*again.RevokedAt))
+	_, err = w.RevokeAPIKeyAudited(ctx, key.ID+100000, time.Now(), keys)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	keyEntries := entries("api_key")
+	require.Len(t, keyEntries, 2, "revoking a revoked key is not audited")
+	assert.Equal(t, models.AuditUpdate, keyEntries[0].Action)
+	assert.Contains(t, string(keyEntries[0].After), `"revoked_at"`)
+	assert.Equal(t, models.AuditCreate, keyEntries[1].Action)
+	assert.NotContains(t, string(keyEntries[1].After), "hash", "the key hash is never recorded")
+}
@@ -0,0 +1,154 @@
+// Package dbtest holds a behaviour suite shared by every database.DB
+// implementation so the backends stay interchangeable.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// Run exercises db against the behaviour of the PostgreSQL backend. db must
+// be empty and its ID sequence must start at 1.
+func Run(t *testing.T, db database.DB) {
+	t.Helper()
+
+	_, err := db.GetPosts()
+	assert.ErrorIs(t, err, database.ErrNotFound, "GetPosts on an empty database")
+
+	first, err := db.CreatePost(models.Post{ID: 42, Title: "Post 1", Body: "Content 1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.ID, "CreatePost assigns IDs and ignores the given one")
+	assert.Equal(t, "Post 1", first.Title)
+	assert.False(t, first.CreatedAt.IsZero(), "CreatePost sets created_at")
+	assert.False(t, first.UpdatedAt.IsZero(), "CreatePost sets updated_at")
+
+	second, err := db.CreatePost(models.Post{Title: "Post 2", Body: "Content 2"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.ID)
+
+	got, err := db.GetPostByID(strconv.Itoa(first.ID))
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, got.ID)
+	assert.Equal(t, first.Title, got.Title)
+	assert.Equal(t, first.Body, got.Body)
+	assert.True(t, first.CreatedAt.Equal(got.CreatedAt), "created_at round-trips")
+
+	_, err = db.GetPostByID("999")
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	updated, err := db.UpdatePost(models.Post{ID: first.ID, Title: "Updated", Body: "Updated Content"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, updated.ID)
+	assert.Equal(t, "Updated", updated.Title)
+	assert.Equal(t, "Updated Content", updated.Body)
+	assert.True(t, first.CreatedAt.Equal(updated.CreatedAt), "UpdatePost keeps created_at")
+	assert.False(t, updated.UpdatedAt.Before(first.UpdatedAt), "UpdatePost bumps updated_at")
+
+	_, err = db.UpdatePost(models.Post{ID: 999, Title: "x", Body: "y"})
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	posts, err := db.GetPosts()
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, first.ID, posts[0].ID)
+	assert.Equal(t, "Updated", posts[0].Title)
+	assert.Equal(t, second.ID, posts[1].ID)
+
+	batch, err := db.GetPostsByIDs(context.Background(), []int{second.ID, 999, first.ID, second.ID})
+	require.NoError(t, err)
+	require.Len(t, batch, 2, "GetPostsByIDs skips missing and repeated IDs")
+	assert.Equal(t, first.ID, batch[0].ID)
+	assert.Equal(t, "Updated", batch[0].Title)
+	assert.Equal(t, second.ID, batch[1].ID)
+
+	batch, err = db.GetPostsByIDs(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	runBulk(t, db)
+}
+
+// runBulk covers EachPost, ListPosts, CountPosts and ImportPosts on a database holding posts 1
+// and 2.
+func runBulk(t *testing.T, db database.DB) {
+	ctx := context.Background()
+
+	var ids []int
+	require.NoError(t, db.EachPost(ctx, func(p models.Post) error {
+		ids = append(ids, p.ID)
+		return nil
+	}))
+	assert.Equal(t, []int{1, 2}, ids, "EachPost iterates in ID order")
+
+	stop := errors.New("stop")
+	calls := 0
+	err := db.EachPost(ctx, func(models.Post) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls, "EachPost stops at the first error")
+
+	page, err := db.ListPosts(ctx, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, 1, page[0].ID, "ListPosts starts at the lowest ID")
+	page, err = db.ListPosts(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, 2, page[0].ID, "ListPosts continues after the given ID")
+	page, err = db.ListPosts(ctx, 2, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	count, err := db.CountPosts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	first, err := db.GetPostByID("1")
+	require.NoError(t, err)
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored, err := db.ImportPosts(ctx, []models.Post{
+		{ID: 10, Title: "Imported", Body: "Imported Content", CreatedAt: created, UpdatedAt: created},
+		{ID: 1, Title: "Replaced", Body: "Replaced Content"},
+	}, database.ImportUpsert)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, 10, stored[0].ID)
+	assert.False(t, stored[1].CreatedAt.IsZero(), "missing timestamps default to now")
+
+	got, err := db.GetPostByID("10")
+	require.NoError(t, err)
+	assert.True(t, created.Equal(got.CreatedAt), "upsert keeps created_at")
+	assert.True(t, created.Equal(got.UpdatedAt), "upsert keeps updated_at")
+
+	got, err = db.GetPostByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Replaced", got.Title)
+	assert.True(t, first.CreatedAt.Equal(got.CreatedAt), "upsert without created_at keeps the stored one")
+	assert.True(t, first.CreatedAt.Equal(stored[1].CreatedAt), "and returns it")
+
+	next, err := db.CreatePost(models.Post{Title: "After import", Body: "Content"})
+	require.NoError(t, err)
+	assert.Equal(t, 11, next.ID, "IDs continue after the highest imported one")
+
+	stored, err = db.ImportPosts(ctx, []models.Post{
+		{ID: 1, Title: "Inserted", Body: "Inserted Content", CreatedAt: created},
+	}, database.ImportInsert)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, 12, stored[0].ID, "insert assigns a new ID")
+	assert.True(t, created.Equal(stored[0].CreatedAt))
+
+	got, err = db.GetPostByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Replaced", got.Title, "insert leaves existing posts alone")
+}
@@ -0,0 +1,111 @@
+package dbtest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// RunOutbox exercises a backend opened with Options.Outbox. Its outbox must
+// be empty; posts may already exist.
+func RunOutbox(t *testing.T, db database.DB) {
+	t.Helper()
+	ctx := context.Background()
+	store, ok := db.(database.Outbox)
+	require.True(t, ok, "the backend implements database.Outbox")
+
+	events, err := store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.NotNil(t, events, "PendingEvents returns an empty slice, not nil")
+	assert.Empty(t, events)
+
+	created, err := db.CreatePost(models.Post{Title: "Outbox", Body: "Content"})
+	require.NoError(t, err)
+	updated, err := db.UpdatePost(models.Post{ID: created.ID, Title: "Outbox updated", Body: "Content"})
+	require.NoError(t, err)
+	_, err = db.UpdatePost(models.Post{ID: 99999, Title: "x", Body: "y"})
+	require.ErrorIs(t, err, database.ErrNotFound)
+	imported, err := db.ImportPosts(ctx, []models.Post{{Title: "Imported", Body: "Content"}}, database.ImportInsert)
+	require.NoError(t, err)
+
+	events, err = store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3, "a failed update records no event")
+	assert.Less(t, events[0].ID, events[1].ID)
+	assert.Less(t, events[1].ID, events[2].ID)
+
+	for i, want := range []struct {
+		typ  string
+		post models.Post
+	}{
+		{models.PostCreated, created},
+		{models.PostUpdated, updated},
+		{models.PostCreated, imported[0]},
+	} {
+		assert.Equal(t, want.typ, events[i].Type)
+		assert.False(t, events[i].CreatedAt.IsZero())
+		assert.Nil(t, events[i].DispatchedAt)
+
+		var post models.Post
+		require.NoError(t, json.Unmarshal(events[i].Payload, &post))
+		assert.Equal(t, want.post.ID, post.ID)
+		assert.Equal(t, want.post.Title, post.Title)
+	}
+
+	limited, err := store.PendingEvents(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+
+	require.NoError(t, store.MarkDispatched(ctx, events[0].ID))
+	require.NoError(t, store.MarkDispatched(ctx, events[0].ID), "marking twice is harmless")
+	assert.ErrorIs(t, store.MarkDispatched(ctx, 99999), database.ErrNotFound)
+
+	pending, err := store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, events[1].ID, pending[0].ID)
+
+	since, err := store.EventsSince(ctx, events[0].ID-1, 10)
+	require.NoError(t, err)
+	require.Len(t, since, 3, "EventsSince includes dispatched events")
+	assert.Equal(t, events[0].ID, since[0].ID)
+	assert.NotNil(t, since[0].DispatchedAt)
+	since, err = store.EventsSince(ctx, events[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, events[1].ID, since[0].ID)
+
+	last, err := store.LastEventID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, events[2].ID, last)
+
+	n, err := store.PurgeDispatched(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n, "recently dispatched events are kept")
+
+	n, err = store.PurgeDispatched(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "pending events are never purged")
+
+	pending, err = store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	other := db.ForTenant("tenant-other").(database.Outbox)
+	pending, err = other.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "the relay of one tenant never sees another's events")
+	since, err = other.EventsSince(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, since)
+	last, err = other.LastEventID(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, last)
+	assert.ErrorIs(t, other.MarkDispatched(ctx, events[1].ID), database.ErrNotFound)
+}
@@ -0,0 +1,100 @@
+package dbtest
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// RunTenants checks that tenant views of db cannot see or change each
+// other's posts and webhooks. The tenants "tenant-a" and "tenant-b" must
+// hold nothing; other tenants may.
+func RunTenants(t *testing.T, db database.DB) {
+	t.Helper()
+	ctx := context.Background()
+	a := db.ForTenant("tenant-a")
+	b := db.ForTenant("tenant-b")
+
+	post, err := a.CreatePost(models.Post{Title: "A's post", Body: "Content"})
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", post.TenantID)
+	id := strconv.Itoa(post.ID)
+
+	_, err = b.GetPosts()
+	assert.ErrorIs(t, err, database.ErrNotFound, "GetPosts skips other tenants")
+	_, err = b.GetPostByID(id)
+	assert.ErrorIs(t, err, database.ErrNotFound, "GetPostByID skips other tenants")
+	_, err = b.UpdatePost(models.Post{ID: post.ID, Title: "Taken", Body: "Taken"})
+	assert.ErrorIs(t, err, database.ErrNotFound, "UpdatePost skips other tenants")
+
+	batch, err := b.GetPostsByIDs(ctx, []int{post.ID})
+	require.NoError(t, err)
+	assert.Empty(t, batch, "GetPostsByIDs skips other tenants")
+
+	page, err := b.ListPosts(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page, "ListPosts skips other tenants")
+	count, err := b.CountPosts(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count, "CountPosts skips other tenants")
+
+	require.NoError(t, b.EachPost(ctx, func(p models.Post) error {
+		t.Errorf("EachPost returned post %d of another tenant", p.ID)
+		return nil
+	}))
+
+	_, err = b.ImportPosts(ctx, []models.Post{{ID: post.ID, Title: "Taken", Body: "Taken"}}, database.ImportUpsert)
+	assert.ErrorIs(t, err, database.ErrTenantMismatch, "an upsert cannot take over another tenant's post")
+
+	got, err := a.GetPostByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "A's post", got.Title, "the owner's post is unchanged")
+
+	posts, err := a.GetPosts()
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, post.ID, posts[0].ID)
+
+	if rootPosts, err := db.GetPosts(); err == nil {
+		for _, p := range rootPosts {
+			assert.NotEqual(t, post.ID, p.ID, "the default tenant does not see tenant-a's posts")
+		}
+	}
+
+	storeA, ok := a.(database.WebhookStore)
+	if !ok {
+		return
+	}
+	storeB := b.(database.WebhookStore)
+
+	hook, err := storeA.CreateWebhook(ctx, models.Webhook{URL: "https://a.example.com/hook", Events: []string{"post.created"}, Secret: "s"})
+	require.NoError(t, err)
+	_, err = storeB.GetWebhook(ctx, hook.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	hooks, err := storeB.ListWebhooks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+	assert.ErrorIs(t, storeB.DeleteWebhook(ctx, hook.ID), database.ErrNotFound)
+
+	d, err := storeA.CreateDelivery(ctx, models.WebhookDelivery{
+		WebhookID: hook.ID, Event: "post.created", Payload: json.RawMessage(`{}`), Status: models.DeliveryPending,
+	})
+	require.NoError(t, err)
+	_, err = storeB.GetDelivery(ctx, d.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	_, err = storeB.UpdateDelivery(ctx, models.WebhookDelivery{ID: d.ID, Status: models.DeliveryDead})
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	pending, err := storeB.PendingDeliveries(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "the dispatcher of one tenant never sends another's deliveries")
+
+	pending, err = storeA.PendingDeliveries(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{d.ID}, deliveryIDs(pending))
+}
@@ -0,0 +1,130 @@
+package dbtest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// RunWebhooks exercises a database.WebhookStore. store must hold no webhooks
+// and its ID sequences must start at 1.
+func RunWebhooks(t *testing.T, store database.WebhookStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	hooks, err := store.ListWebhooks(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, hooks, "ListWebhooks returns an empty slice, not nil")
+	assert.Empty(t, hooks)
+
+	first, err := store.CreateWebhook(ctx, models.Webhook{
+		ID: 42, URL: "https://example.com/hook", Events: []string{"post.created", "post.updated"}, Secret: "s1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.ID, "CreateWebhook assigns IDs and ignores the given one")
+	assert.Equal(t, []string{"post.created", "post.updated"}, first.Events)
+	assert.False(t, first.CreatedAt.IsZero(), "CreateWebhook sets created_at")
+
+	second, err := store.CreateWebhook(ctx, models.Webhook{URL: "https://example.org/hook", Events: []string{"post.created"}, Secret: "s2"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.ID)
+
+	got, err := store.GetWebhook(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.URL, got.URL)
+	assert.Equal(t, first.Events, got.Events)
+	assert.Equal(t, "s1", got.Secret)
+
+	_, err = store.GetWebhook(ctx, 999)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	hooks, err = store.ListWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, hooks, 2)
+	assert.Equal(t, first.ID, hooks[0].ID)
+	assert.Equal(t, second.ID, hooks[1].ID)
+
+	payload := json.RawMessage(`{"event":"post.created"}`)
+	later := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	d1, err := store.CreateDelivery(ctx, models.WebhookDelivery{
+		WebhookID: first.ID, Event: "post.created", Payload: payload, Status: models.DeliveryPending, NextAttemptAt: later,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, d1.ID)
+	assert.JSONEq(t, string(payload), string(d1.Payload))
+	assert.True(t, later.Equal(d1.NextAttemptAt))
+	assert.False(t, d1.CreatedAt.IsZero(), "CreateDelivery sets created_at")
+
+	d2, err := store.CreateDelivery(ctx, models.WebhookDelivery{
+		WebhookID: first.ID, Event: "post.updated", Payload: payload, Status: models.DeliveryPending,
+	})
+	require.NoError(t, err)
+	assert.False(t, d2.NextAttemptAt.IsZero(), "next_attempt_at defaults to now")
+
+	d3, err := store.CreateDelivery(ctx, models.WebhookDelivery{
+		WebhookID: second.ID, Event: "post.created", Payload: payload, Status: models.DeliveryPending,
+	})
+	require.NoError(t, err)
+
+	pending, err := store.PendingDeliveries(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	assert.Equal(t, []int{d2.ID, d3.ID, d1.ID}, deliveryIDs(pending), "pending deliveries are due first")
+
+	d2.Status = models.DeliverySucceeded
+	d2.Attempts = 1
+	d2.ResponseStatus = 204
+	updated, err := store.UpdateDelivery(ctx, d2)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliverySucceeded, updated.Status)
+	assert.Equal(t, 1, updated.Attempts)
+	assert.Equal(t, 204, updated.ResponseStatus)
+	assert.Equal(t, "post.updated", updated.Event, "UpdateDelivery keeps the event")
+	assert.False(t, updated.UpdatedAt.Before(d2.UpdatedAt), "UpdateDelivery bumps updated_at")
+
+	_, err = store.UpdateDelivery(ctx, models.WebhookDelivery{ID: 999, Status: models.DeliveryDead})
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	gotDelivery, err := store.GetDelivery(ctx, d2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliverySucceeded, gotDelivery.Status)
+
+	_, err = store.GetDelivery(ctx, 999)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	pending, err = store.PendingDeliveries(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{d3.ID, d1.ID}, deliveryIDs(pending))
+
+	log, err := store.ListDeliveries(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{d2.ID, d1.ID}, deliveryIDs(log), "ListDeliveries is newest first")
+
+	require.NoError(t, store.DeleteWebhook(ctx, first.ID))
+	assert.ErrorIs(t, store.DeleteWebhook(ctx, first.ID), database.ErrNotFound)
+
+	_, err = store.GetDelivery(ctx, d1.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound, "DeleteWebhook removes its deliveries")
+
+	log, err = store.ListDeliveries(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Empty(t, log)
+
+	pending, err = store.PendingDeliveries(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{d3.ID}, deliveryIDs(pending))
+}
+
+func deliveryIDs(deliveries []models.WebhookDelivery) []int {
+	ids := make([]int, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+	}
+	return ids
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func (m *Memory) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	return m.CreateAPIKeyAudited(ctx, key, nil)
+}
+
+func (m *Memory) CreateAPIKeyAudited(ctx context.Context, key models.APIKey, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key.ID = m.nextAPIKeyID
+	key.TenantID = m.tenant
+	key.Scopes = slices.Clone(key.Scopes)
+	key.CreatedAt = now()
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+	if key.ExpiresAt != nil {
+		expires := orNow(*key.ExpiresAt, key.CreatedAt)
+		key.ExpiresAt = &expires
+	}
+	m.apiKeys[key.ID] = key
+	m.nextAPIKeyID++
+
+	if err := saveAudited(m, audit, nil, &key); err != nil {
+		delete(m.apiKeys, key.ID)
+		m.nextAPIKeyID--
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (m *Memory) GetAPIKey(ctx context.Context, id int) (models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok || key.TenantID != m.tenant {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	return key, nil
+}
+
+func (m *Memory) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.apiKeys {
+		if key.Prefix == prefix && key.TenantID == m.tenant {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, database.ErrNotFound
+}
+
+func (m *Memory) ListAPIKeys(ctx context.Context, user string) ([]models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := sortedByID(m.apiKeys, func(k models.APIKey) int { return k.ID })
+	return slices.DeleteFunc(keys, func(k models.APIKey) bool {
+		return k.TenantID != m.tenant || (user != "" && k.User != user)
+	}), nil
+}
+
+func (m *Memory) RevokeAPIKey(ctx context.Context, id int, at time.Time) (models.APIKey, error) {
+	return m.RevokeAPIKeyAudited(ctx, id, at, nil)
+}
+
+func (m *Memory) RevokeAPIKeyAudited(ctx context.Context, id int, at time.Time, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.apiKeys[id]
+	if !ok || existing.TenantID != m.tenant {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	if existing.RevokedAt != nil {
+		return existing, nil
+	}
+
+	revoked := existing
+	at = orNow(at, now())
+	revoked.RevokedAt = &at
+	m.apiKeys[id] = revoked
+
+	if err := saveAudited(m, audit, &existing, &revoked); err != nil {
+		m.apiKeys[id] = existing
+		return models.APIKey{}, err
+	}
+	return revoked, nil
+}
+
+// TouchAPIKey is not written to the snapshot until the next change, so a
+// busy key does not rewrite the file on every request.
+func (m *Memory) TouchAPIKey(ctx context.Context, id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok || key.TenantID != m.tenant {
+		return database.ErrNotFound
+	}
+	at = orNow(at, now())
+	key.LastUsedAt = &at
+	m.apiKeys[id] = key
+	return nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func (m *Memory) AppendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry = m.appendAudit(entry)
+	if err := m.save(); err != nil {
+		m.audit = m.audit[:len(m.audit)-1]
+		return models.AuditEntry{}, err
+	}
+	return entry, nil
+}
+
+// appendAudit assigns the entry its ID and time and appends it. Callers
+// must hold the write lock.
+func (m *Memory) appendAudit(entry models.AuditEntry) models.AuditEntry {
+	entry.ID = len(m.audit) + 1
+	entry.TenantID = m.tenant
+	entry.Time = orNow(entry.Time, now())
+	m.audit = append(m.audit, entry)
+	return entry
+}
+
+// saveAudited appends the entry audit describes, unless audit is nil, and
+// saves the snapshot with it. On failure the entry is dropped again and
+// the caller undoes its change. Callers must hold the write lock.
+func saveAudited[T any](m *Memory, audit database.AuditFunc[T], before, after *T) error {
+	n := len(m.audit)
+	if audit != nil {
+		entry, err := audit(before, after)
+		if err != nil {
+			return err
+		}
+		m.appendAudit(entry)
+	}
+	if err := m.save(); err != nil {
+		m.audit = m.audit[:n]
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) ListAudit(ctx context.Context, filter database.AuditFilter) ([]models.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []models.AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0 && (filter.Limit <= 0 || len(entries) < filter.Limit); i-- {
+		if e := m.audit[i]; e.TenantID == m.tenant && filter.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,503 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// Memory is a database.DB kept entirely in process memory. When a snapshot
+// path is configured the data is loaded from it on start and written back
+// after every change, so it survives restarts.
+type Memory struct {
+	*state
+	tenant string
+}
+
+// state is shared by every tenant's view. IDs are unique across tenants,
+// as they are in the SQL backends.
+type state struct {
+	mu       sync.RWMutex
+	posts    map[int]models.Post
+	nextID   int
+	snapshot string
+
+	webhooks       map[int]models.Webhook
+	deliveries     map[int]models.WebhookDelivery
+	nextWebhookID  int
+	nextDeliveryID int
+
+	apiKeys      map[int]models.APIKey
+	nextAPIKeyID int
+
+	// audit is append-only and kept in ID order.
+	audit []models.AuditEntry
+}
+
+// snapshotFile is the on-disk layout of a snapshot.
+type snapshotFile struct {
+	NextID         int                `json:"next_id"`
+	Posts          []snapshotPost     `json:"posts"`
+	NextWebhookID  int                `json:"next_webhook_id,omitempty"`
+	Webhooks       []snapshotWebhook  `json:"webhooks,omitempty"`
+	NextDeliveryID int                `json:"next_delivery_id,omitempty"`
+	Deliveries     []snapshotDelivery `json:"deliveries,omitempty"`
+	NextAPIKeyID   int                `json:"next_api_key_id,omitempty"`
+	APIKeys        []snapshotAPIKey   `json:"api_keys,omitempty"`
+	Audit          []snapshotAudit    `json:"audit,omitempty"`
+}
+
+// The models keep TenantID out of their JSON, so the snapshot adds it back.
+// It is left out for the default tenant, which older snapshots belong to.
+type snapshotPost struct {
+	models.Post
+	Tenant string `json:"tenant_id,omitempty"`
+}
+
+type snapshotWebhook struct {
+	models.Webhook
+	Tenant string `json:"tenant_id,omitempty"`
+}
+
+type snapshotDelivery struct {
+	models.WebhookDelivery
+	Tenant string `json:"tenant_id,omitempty"`
+}
+
+// snapshotAPIKey also keeps the hash, which the model never encodes.
+type snapshotAPIKey struct {
+	models.APIKey
+	Tenant string `json:"tenant_id,omitempty"`
+	Hash   string `json:"hash"`
+}
+
+type snapshotAudit struct {
+	models.AuditEntry
+	Tenant string `json:"tenant_id,omitempty"`
+}
+
+func snapshotTenant(tenant string) string {
+	if tenant == database.DefaultTenant {
+		return ""
+	}
+	return tenant
+}
+
+func loadedTenant(tenant string) string {
+	if tenant == "" {
+		return database.DefaultTenant
+	}
+	return tenant
+}
+
+// NewMemory creates an in-memory database. snapshotPath is optional; an
+// empty path keeps everything in memory only.
+func NewMemory(snapshotPath string) (database.DB, error) {
+	m := &Memory{tenant: database.DefaultTenant, state: &state{
+		posts:          make(map[int]models.Post),
+		nextID:         1,
+		snapshot:       snapshotPath,
+		webhooks:       make(map[int]models.Webhook),
+		deliveries:     make(map[int]models.WebhookDelivery),
+		nextWebhookID:  1,
+		nextDeliveryID: 1,
+		apiKeys:        make(map[int]models.APIKey),
+		nextAPIKeyID:   1,
+	}}
+
+	if snapshotPath != "" {
+		if err := m.load(); err != nil {
+			log.Println("Memory snapshot load failed:", err)
+			return nil, database.ErrFailedConnection
+		}
+	}
+
+	log.Println("Using in-memory database")
+	return m, nil
+}
+
+func (m *Memory) ForTenant(tenantID string) database.DB {
+	return &Memory{state: m.state, tenant: tenantID}
+}
+
+func (m *Memory) GetPosts() ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := m.sorted()
+	if len(posts) == 0 {
+		return nil, database.ErrNotFound
+	}
+	return posts, nil
+}
+
+func (m *Memory) GetPostByID(id string) (models.Post, error) {
+	postID, err := strconv.Atoi(id)
+	if err != nil {
+		return models.Post{}, database.ErrNotFound
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	post, ok := m.posts[postID]
+	if !ok || post.TenantID != m.tenant {
+		return models.Post{}, database.ErrNotFound
+	}
+	return post, nil
+}
+
+func (m *Memory) CreatePost(post models.Post) (models.Post, error) {
+	return m.CreatePostAudited(context.Background(), post, nil)
+}
+
+func (m *Memory) CreatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := now()
+	post.ID = m.nextID
+	post.TenantID = m.tenant
+	post.CreatedAt = now
+	post.UpdatedAt = now
+
+	m.posts[post.ID] = post
+	m.nextID++
+
+	if err := saveAudited(m, audit, nil, &post); err != nil {
+		delete(m.posts, post.ID)
+		m.nextID--
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+func (m *Memory) UpdatePost(post models.Post) (models.Post, error) {
+	return m.UpdatePostAudited(context.Background(), post, nil)
+}
+
+func (m *Memory) UpdatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.posts[post.ID]
+	if !ok || existing.TenantID != m.tenant {
+		return models.Post{}, database.ErrNotFound
+	}
+
+	updated := existing
+	updated.Title = post.Title
+	updated.Body = post.Body
+	updated.UpdatedAt = now()
+
+	m.posts[post.ID] = updated
+
+	if err := saveAudited(m, audit, &existing, &updated); err != nil {
+		m.posts[post.ID] = existing
+		return models.Post{}, err
+	}
+	return updated, nil
+}
+
+func (m *Memory) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := []models.Post{}
+	for _, id := range uniqueSorted(ids) {
+		if p, ok := m.posts[id]; ok && p.TenantID == m.tenant {
+			posts = append(posts, p)
+		}
+	}
+	return posts, nil
+}
+
+func (m *Memory) ListPosts(ctx context.Context, after, limit int) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := []models.Post{}
+	for _, p := range m.sorted() {
+		if len(posts) == limit {
+			break
+		}
+		if p.ID > after {
+			posts = append(posts, p)
+		}
+	}
+	return posts, nil
+}
+
+func (m *Memory) CountPosts(ctx context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, p := range m.posts {
+		if p.TenantID == m.tenant {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// EachPost iterates over a copy taken under the read lock, so fn may call
+// back into the database.
+func (m *Memory) EachPost(ctx context.Context, fn func(models.Post) error) error {
+	m.mu.RLock()
+	posts := m.sorted()
+	m.mu.RUnlock()
+
+	for _, p := range posts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ImportPosts(ctx context.Context, posts []models.Post, mode database.ImportMode) ([]models.Post, error) {
+	return m.ImportPostsAudited(ctx, posts, mode, nil)
+}
+
+func (m *Memory) ImportPostsAudited(ctx context.Context, posts []models.Post, mode database.ImportMode, audit database.AuditFunc[models.Post]) ([]models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := make(map[int]models.Post, len(m.posts))
+	for id, p := range m.posts {
+		previous[id] = p
+	}
+	previousNextID := m.nextID
+	previousAudit := len(m.audit)
+	undo := func() {
+		m.posts = previous
+		m.nextID = previousNextID
+		m.audit = m.audit[:previousAudit]
+	}
+
+	now := now()
+	stored := make([]models.Post, 0, len(posts))
+	for _, p := range posts {
+		var before *models.Post
+		if mode == database.ImportInsert {
+			p.ID = m.nextID
+			m.nextID++
+		} else if existing, ok := m.posts[p.ID]; ok && existing.TenantID != m.tenant {
+			undo()
+			return nil, database.ErrTenantMismatch
+		} else if ok {
+			before = &existing
+			// A replaced post without a created_at keeps its own.
+			p.CreatedAt = orNow(p.CreatedAt, existing.CreatedAt)
+		}
+		if p.ID >= m.nextID {
+			m.nextID = p.ID + 1
+		}
+		p.TenantID = m.tenant
+		p.CreatedAt = orNow(p.CreatedAt, now)
+		p.UpdatedAt = orNow(p.UpdatedAt, now)
+		m.posts[p.ID] = p
+		if audit != nil {
+			entry, err := audit(before, &p)
+			if err != nil {
+				undo()
+				return nil, err
+			}
+			m.appendAudit(entry)
+		}
+		stored = append(stored, p)
+	}
+
+	if err := m.save(); err != nil {
+		undo()
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Close flushes the snapshot, if any.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.save()
+}
+
+// sorted returns the tenant's posts ordered by ID. Callers must hold the
+// lock.
+func (m *Memory) sorted() []models.Post {
+	posts := make([]models.Post, 0, len(m.posts))
+	for _, p := range m.posts {
+		if p.TenantID == m.tenant {
+			posts = append(posts, p)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts
+}
+
+func (m *Memory) load() error {
+	data, err := os.ReadFile(m.snapshot)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var snap snapshotFile
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	for _, sp := range snap.Posts {
+		p := sp.Post
+		p.TenantID = loadedTenant(sp.Tenant)
+		m.posts[p.ID] = p
+		if p.ID >= m.nextID {
+			m.nextID = p.ID + 1
+		}
+	}
+	if snap.NextID > m.nextID {
+		m.nextID = snap.NextID
+	}
+
+	for _, sw := range snap.Webhooks {
+		w := sw.Webhook
+		w.TenantID = loadedTenant(sw.Tenant)
+		m.webhooks[w.ID] = w
+	}
+	for _, sd := range snap.Deliveries {
+		d := sd.WebhookDelivery
+		d.TenantID = loadedTenant(sd.Tenant)
+		m.deliveries[d.ID] = d
+	}
+	m.nextWebhookID = max(m.nextWebhookID, snap.NextWebhookID)
+	m.nextDeliveryID = max(m.nextDeliveryID, snap.NextDeliveryID)
+
+	for _, sk := range snap.APIKeys {
+		k := sk.APIKey
+		k.TenantID = loadedTenant(sk.Tenant)
+		k.Hash = sk.Hash
+		m.apiKeys[k.ID] = k
+	}
+	m.nextAPIKeyID = max(m.nextAPIKeyID, snap.NextAPIKeyID)
+
+	for _, sa := range snap.Audit {
+		e := sa.AuditEntry
+		e.TenantID = loadedTenant(sa.Tenant)
+		// save indents the entity snapshots along with the file; compact
+		// them back to the bytes that were appended.
+		if e.Before, err = compactJSON(e.Before); err != nil {
+			return err
+		}
+		if e.After, err = compactJSON(e.After); err != nil {
+			return err
+		}
+		m.audit = append(m.audit, e)
+	}
+	return nil
+}
+
+// save writes the snapshot atomically by renaming a temp file over it.
+// Callers must hold the write lock.
+func (m *Memory) save() error {
+	if m.snapshot == "" {
+		return nil
+	}
+
+	snap := snapshotFile{
+		NextID:         m.nextID,
+		Posts:          []snapshotPost{},
+		NextWebhookID:  m.nextWebhookID,
+		NextDeliveryID: m.nextDeliveryID,
+		NextAPIKeyID:   m.nextAPIKeyID,
+	}
+	for _, p := range sortedByID(m.posts, func(p models.Post) int { return p.ID }) {
+		snap.Posts = append(snap.Posts, snapshotPost{p, snapshotTenant(p.TenantID)})
+	}
+	for _, w := range sortedByID(m.webhooks, func(w models.Webhook) int { return w.ID }) {
+		snap.Webhooks = append(snap.Webhooks, snapshotWebhook{w, snapshotTenant(w.TenantID)})
+	}
+	for _, d := range sortedByID(m.deliveries, func(d models.WebhookDelivery) int { return d.ID }) {
+		snap.Deliveries = append(snap.Deliveries, snapshotDelivery{d, snapshotTenant(d.TenantID)})
+	}
+	for _, k := range sortedByID(m.apiKeys, func(k models.APIKey) int { return k.ID }) {
+		snap.APIKeys = append(snap.APIKeys, snapshotAPIKey{k, snapshotTenant(k.TenantID), k.Hash})
+	}
+	for _, e := range m.audit {
+		snap.Audit = append(snap.Audit, snapshotAudit{e, snapshotTenant(e.TenantID)})
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.snapshot), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.snapshot)
+}
+
+func uniqueSorted(ids []int) []int {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	unique := sorted[:0]
+	for i, id := range sorted {
+		if i == 0 || id != sorted[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func compactJSON(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func orNow(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// now mirrors PostgreSQL's NOW() on a TIMESTAMP column: UTC with
+// microsecond precision.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
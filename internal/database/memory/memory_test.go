@@ -0,0 +1,112 @@
+package memory_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/dbtest"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func TestMemory(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	dbtest.Run(t, db)
+	dbtest.RunWebhooks(t, db.(database.WebhookStore))
+	dbtest.RunAPIKeys(t, db, db.(database.APIKeyStore))
+	dbtest.RunAudit(t, db, db.(database.AuditLog))
+	dbtest.RunTenants(t, db)
+	dbtest.RunAuditedWrites(t, db)
+}
+
+func TestMemoryConcurrentCreate(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := db.CreatePost(models.Post{Title: "t", Body: "b"})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	posts, err := db.GetPosts()
+	require.NoError(t, err)
+	assert.Len(t, posts, 50)
+	for i, p := range posts {
+		assert.Equal(t, i+1, p.ID)
+	}
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	db, err := memory.NewMemory(path)
+	require.NoError(t, err)
+	created, err := db.CreatePost(models.Post{Title: "Post 1", Body: "Content 1"})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	reopened, err := memory.NewMemory(path)
+	require.NoError(t, err)
+
+	got, err := reopened.GetPostByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, created.Title, got.Title)
+	assert.True(t, created.CreatedAt.Equal(got.CreatedAt))
+
+	next, err := reopened.CreatePost(models.Post{Title: "Post 2", Body: "Content 2"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.ID)
+}
+
+func TestMemorySnapshotKeepsAPIKeyHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	ctx := context.Background()
+
+	db, err := memory.NewMemory(path)
+	require.NoError(t, err)
+	created, err := db.ForTenant("acme").(database.APIKeyStore).CreateAPIKey(ctx, models.APIKey{
+		User: "alice", Prefix: "blog_aaaaaaaa", Hash: "secret-hash", Scopes: []string{"posts:read"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	reopened, err := memory.NewMemory(path)
+	require.NoError(t, err)
+	got, err := reopened.ForTenant("acme").(database.APIKeyStore).GetAPIKeyByPrefix(ctx, "blog_aaaaaaaa")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+	assert.Equal(t, "secret-hash", got.Hash, "the hash survives although it is never encoded in the API")
+}
+
+func TestMemorySnapshotKeepsAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	ctx := context.Background()
+
+	db, err := memory.NewMemory(path)
+	require.NoError(t, err)
+	created, err := db.ForTenant("acme").(database.AuditLog).AppendAudit(ctx, models.AuditEntry{
+		Actor: "alice", Action: models.AuditCreate, Entity: "post", EntityID: "1", After: []byte(`{"id":1}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	reopened, err := memory.NewMemory(path)
+	require.NoError(t, err)
+	entries, err := reopened.ForTenant("acme").(database.AuditLog).ListAudit(ctx, database.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, created, entries[0])
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func (m *Memory) CreateWebhook(ctx context.Context, hook models.Webhook) (models.Webhook, error) {
+	return m.CreateWebhookAudited(ctx, hook, nil)
+}
+
+func (m *Memory) CreateWebhookAudited(ctx context.Context, hook models.Webhook, audit database.AuditFunc[models.Webhook]) (models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hook.ID = m.nextWebhookID
+	hook.TenantID = m.tenant
+	hook.Events = slices.Clone(hook.Events)
+	hook.CreatedAt = now()
+	m.webhooks[hook.ID] = hook
+	m.nextWebhookID++
+
+	if err := saveAudited(m, audit, nil, &hook); err != nil {
+		delete(m.webhooks, hook.ID)
+		m.nextWebhookID--
+		return models.Webhook{}, err
+	}
+	return hook, nil
+}
+
+func (m *Memory) GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hook, ok := m.webhooks[id]
+	if !ok || hook.TenantID != m.tenant {
+		return models.Webhook{}, database.ErrNotFound
+	}
+	return hook, nil
+}
+
+func (m *Memory) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hooks := sortedByID(m.webhooks, func(w models.Webhook) int { return w.ID })
+	return slices.DeleteFunc(hooks, func(w models.Webhook) bool { return w.TenantID != m.tenant }), nil
+}
+
+func (m *Memory) DeleteWebhook(ctx context.Context, id int) error {
+	return m.DeleteWebhookAudited(ctx, id, nil)
+}
+
+func (m *Memory) DeleteWebhookAudited(ctx context.Context, id int, audit database.AuditFunc[models.Webhook]) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hook, ok := m.webhooks[id]
+	if !ok || hook.TenantID != m.tenant {
+		return database.ErrNotFound
+	}
+	previous := maps.Clone(m.deliveries)
+
+	delete(m.webhooks, id)
+	maps.DeleteFunc(m.deliveries, func(_ int, d models.WebhookDelivery) bool { return d.WebhookID == id })
+
+	if err := saveAudited(m, audit, &hook, nil); err != nil {
+		m.webhooks[id] = hook
+		m.deliveries = previous
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) CreateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := now()
+	d.ID = m.nextDeliveryID
+	d.TenantID = m.tenant
+	d.CreatedAt = now
+	d.UpdatedAt = now
+	d.NextAttemptAt = orNow(d.NextAttemptAt, now)
+	m.deliveries[d.ID] = d
+	m.nextDeliveryID++
+
+	if err := m.save(); err != nil {
+		delete(m.deliveries, d.ID)
+		m.nextDeliveryID--
+		return models.WebhookDelivery{}, err
+	}
+	return d, nil
+}
+
+func (m *Memory) GetDelivery(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, ok := m.deliveries[id]
+	if !ok || d.TenantID != m.tenant {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+	return d, nil
+}
+
+func (m *Memory) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.deliveries[d.ID]
+	if !ok || existing.TenantID != m.tenant {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+
+	updated := existing
+	updated.Status = d.Status
+	updated.Attempts = d.Attempts
+	updated.ResponseStatus = d.ResponseStatus
+	updated.LastError = d.LastError
+	updated.NextAttemptAt = orNow(d.NextAttemptAt, now())
+	updated.UpdatedAt = now()
+	m.deliveries[d.ID] = updated
+
+	if err := m.save(); err != nil {
+		m.deliveries[d.ID] = existing
+		return models.WebhookDelivery{}, err
+	}
+	return updated, nil
+}
+
+func (m *Memory) ListDeliveries(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deliveries := []models.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.WebhookID == webhookID && d.TenantID == m.tenant {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (m *Memory) PendingDeliveries(ctx context.Context) ([]models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pending := []models.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.Status == models.DeliveryPending && d.TenantID == m.tenant {
+			pending = append(pending, d)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].NextAttemptAt.Equal(pending[j].NextAttemptAt) {
+			return pending[i].NextAttemptAt.Before(pending[j].NextAttemptAt)
+		}
+		return pending[i].ID < pending[j].ID
+	})
+	return pending, nil
+}
+
+// sortedByID returns the values of byID ordered by id. Callers must hold
+// the lock.
+func sortedByID[T any](byID map[int]T, id func(T) int) []T {
+	values := slices.Collect(maps.Values(byID))
+	sort.Slice(values, func(i, j int) bool { return id(values[i]) < id(values[j]) })
+	if values == nil {
+		values = []T{}
+	}
+	return values
+}
@@ -0,0 +1,157 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func (m *MongoDB) apiKeys() *mongo.Collection { return m.db.Collection("api_keys") }
+
+func (m *MongoDB) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	return m.CreateAPIKeyAudited(ctx, key, nil)
+}
+
+func (m *MongoDB) CreateAPIKeyAudited(ctx context.Context, key models.APIKey, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var created models.APIKey
+	err := audited(ctx, m, false, audit, func(ctx context.Context) (*models.APIKey, *models.APIKey, error) {
+		id, err := m.nextSeq(ctx, "api_keys")
+		if err != nil {
+			return nil, nil, err
+		}
+		created = key
+		created.ID = id
+		created.TenantID = m.tenant
+		created.CreatedAt = now()
+		created.LastUsedAt = nil
+		created.RevokedAt = nil
+		if key.ExpiresAt != nil {
+			expires := orNow(*key.ExpiresAt, created.CreatedAt)
+			created.ExpiresAt = &expires
+		}
+
+		if _, err := m.apiKeys().InsertOne(ctx, created); err != nil {
+			return nil, nil, err
+		}
+		return nil, &created, nil
+	})
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return created, nil
+}
+
+func (m *MongoDB) GetAPIKey(ctx context.Context, id int) (models.APIKey, error) {
+	return m.getAPIKey(ctx, bson.M{"id": id})
+}
+
+func (m *MongoDB) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	return m.getAPIKey(ctx, bson.M{"prefix": prefix})
+}
+
+func (m *MongoDB) getAPIKey(ctx context.Context, filter bson.M) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var key models.APIKey
+	err := m.retry.Do(ctx, isTransient, func() error {
+		return m.apiKeys().FindOne(ctx, m.scoped(filter)).Decode(&key)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	return key, err
+}
+
+func (m *MongoDB) ListAPIKeys(ctx context.Context, user string) ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if user != "" {
+		filter["user"] = user
+	}
+	keys := []models.APIKey{}
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.apiKeys().Find(ctx, m.scoped(filter), options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		keys = []models.APIKey{}
+		return cursor.All(ctx, &keys)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (m *MongoDB) RevokeAPIKey(ctx context.Context, id int, at time.Time) (models.APIKey, error) {
+	return m.RevokeAPIKeyAudited(ctx, id, at, nil)
+}
+
+// RevokeAPIKeyAudited only sets revoked_at where it is still null, so an
+// earlier revocation is kept and retrying is safe.
+func (m *MongoDB) RevokeAPIKeyAudited(ctx context.Context, id int, at time.Time, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	at = orNow(at, now())
+	var key models.APIKey
+	err := m.retry.Do(ctx, isTransient, func() error {
+		return audited(ctx, m, false, audit, func(ctx context.Context) (*models.APIKey, *models.APIKey, error) {
+			// The key as it was tells whether this revokes it.
+			var before models.APIKey
+			err := m.apiKeys().FindOneAndUpdate(ctx, m.scoped(bson.M{"id": id}),
+				bson.A{bson.M{"$set": bson.M{"revoked_at": bson.M{"$ifNull": bson.A{"$revoked_at", at}}}}},
+				options.FindOneAndUpdate().SetReturnDocument(options.Before),
+			).Decode(&before)
+			if err != nil {
+				return nil, nil, err
+			}
+			key = before
+			if before.RevokedAt != nil {
+				return nil, nil, nil
+			}
+			key.RevokedAt = &at
+			return &before, &key, nil
+		})
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (m *MongoDB) TouchAPIKey(ctx context.Context, id int, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var res *mongo.UpdateResult
+	err := m.retry.Do(ctx, isTransient, func() (err error) {
+		res, err = m.apiKeys().UpdateOne(ctx, m.scoped(bson.M{"id": id}),
+			bson.M{"$set": bson.M{"last_used_at": orNow(at, now())}})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
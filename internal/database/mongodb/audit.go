@@ -0,0 +1,124 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// audit is only ever inserted into and read. MongoDB has no triggers to
+// refuse other writes, so grant the server's user no more than insert and
+// find on it where that matters.
+func (m *MongoDB) audit() *mongo.Collection { return m.db.Collection("audit_log") }
+
+func (m *MongoDB) AppendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return m.appendAudit(ctx, entry)
+}
+
+func (m *MongoDB) appendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	id, err := m.nextSeq(ctx, "audit_log")
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	entry.ID = id
+	entry.TenantID = m.tenant
+	entry.Time = orNow(entry.Time, now())
+
+	if _, err := m.audit().InsertOne(ctx, entry); err != nil {
+		return models.AuditEntry{}, err
+	}
+	return entry, nil
+}
+
+// audited runs write directly or, when audit is set or inTx asks for it,
+// in a session transaction that also appends the entry audit describes
+// from the entity before and after write. A write that changed nothing
+// returns neither and appends no entry. write may run more than once when
+// the transaction is retried.
+//
+// A standalone server has no transactions, so there the entry is appended
+// after write, and a write whose entry cannot be appended stays stored.
+// inTx is only set with the outbox, which such a server does not open with.
+func audited[T any](ctx context.Context, m *MongoDB, inTx bool, audit database.AuditFunc[T], write func(ctx context.Context) (before, after *T, err error)) error {
+	if audit == nil && !inTx {
+		_, _, err := write(ctx)
+		return err
+	}
+
+	run := func(ctx context.Context) (interface{}, error) {
+		before, after, err := write(ctx)
+		if err != nil || audit == nil || (before == nil && after == nil) {
+			return nil, err
+		}
+		entry, err := audit(before, after)
+		if err != nil {
+			return nil, err
+		}
+		return m.appendAudit(ctx, entry)
+	}
+	if !m.transactions {
+		_, err := run(ctx)
+		return err
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return run(sc)
+	})
+	return err
+}
+
+func (m *MongoDB) ListAudit(ctx context.Context, filter database.AuditFilter) ([]models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	for field, value := range map[string]string{"actor": filter.Actor, "entity": filter.Entity, "entity_id": filter.EntityID} {
+		if value != "" {
+			query[field] = value
+		}
+	}
+	between := bson.M{}
+	if !filter.Since.IsZero() {
+		between["$gte"] = filter.Since.UTC()
+	}
+	if !filter.Until.IsZero() {
+		between["$lt"] = filter.Until.UTC()
+	}
+	if len(between) > 0 {
+		query["time"] = between
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+
+	entries := []models.AuditEntry{}
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.audit().Find(ctx, m.scoped(query), opts)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		entries = []models.AuditEntry{}
+		return cursor.All(ctx, &entries)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,229 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/migrate"
+)
+
+// lockTTL is how long a migration lock is honoured before another runner
+// may take it over, in case the holder died without releasing it.
+const lockTTL = 15 * time.Minute
+
+// lockPollInterval is how often a waiting runner retries the lock.
+const lockPollInterval = time.Second
+
+// migrations are the MongoDB counterpart of the SQL files in migrations/.
+// Versions line up with the SQL ones where the change is equivalent.
+func migrations(db *mongo.Database) []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version: 1,
+			Name:    "create_posts_collection",
+			Up: func(ctx context.Context) error {
+				return createCollections(ctx, db, "posts")
+			},
+			Down: func(ctx context.Context) error {
+				return db.Collection("posts").Drop(ctx)
+			},
+		},
+		{
+			Version: 2,
+			Name:    "seed_posts",
+			Up: func(ctx context.Context) error {
+				now := time.Now()
+				_, err := db.Collection("posts").InsertMany(ctx, []interface{}{
+					bson.M{"id": 1, "title": "Post 1", "body": "Content 1", "created_at": now, "updated_at": now},
+					bson.M{"id": 2, "title": "Post 2", "body": "Content 2", "created_at": now, "updated_at": now},
+				})
+				if err != nil {
+					return err
+				}
+				return syncCounter(ctx, db)
+			},
+			Down: func(ctx context.Context) error {
+				_, err := db.Collection("posts").DeleteMany(ctx, bson.M{"title": bson.M{"$in": bson.A{"Post 1", "Post 2"}}})
+				return err
+			},
+		},
+		{
+			Version: 3,
+			Name:    "create_webhooks",
+			Up: func(ctx context.Context) error {
+				return createCollections(ctx, db, "webhooks", "webhook_deliveries")
+			},
+			Down: func(ctx context.Context) error {
+				if err := db.Collection("webhook_deliveries").Drop(ctx); err != nil {
+					return err
+				}
+				if err := db.Collection("webhooks").Drop(ctx); err != nil {
+					return err
+				}
+				_, err := db.Collection("counters").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": bson.A{"webhooks", "webhook_deliveries"}}})
+				return err
+			},
+		},
+		{
+			Version: 4,
+			Name:    "create_outbox",
+			// Transactions cannot create collections on servers before 4.4,
+			// so the outbox has to exist before the first post is written.
+			Up: func(ctx context.Context) error {
+				return createCollections(ctx, db, "outbox")
+			},
+			Down: func(ctx context.Context) error {
+				if err := db.Collection("outbox").Drop(ctx); err != nil {
+					return err
+				}
+				_, err := db.Collection("counters").DeleteOne(ctx, bson.M{"_id": "outbox"})
+				return err
+			},
+		},
+		{
+			Version: 5,
+			Name:    "add_tenants",
+			Up: func(ctx context.Context) error {
+				return eachTenanted(db, func(c *mongo.Collection) error {
+					_, err := c.UpdateMany(ctx, bson.M{"tenant_id": bson.M{"$exists": false}},
+						bson.M{"$set": bson.M{"tenant_id": database.DefaultTenant}})
+					return err
+				})
+			},
+			Down: func(ctx context.Context) error {
+				return eachTenanted(db, func(c *mongo.Collection) error {
+					_, err := c.UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"tenant_id": ""}})
+					return err
+				})
+			},
+		},
+		{
+			Version: 6,
+			Name:    "create_api_keys",
+			Up: func(ctx context.Context) error {
+				return createCollections(ctx, db, "api_keys")
+			},
+			Down: func(ctx context.Context) error {
+				if err := db.Collection("api_keys").Drop(ctx); err != nil {
+					return err
+				}
+				_, err := db.Collection("counters").DeleteOne(ctx, bson.M{"_id": "api_keys"})
+				return err
+			},
+		},
+		{
+			Version: 7,
+			Name:    "create_audit_log",
+			Up: func(ctx context.Context) error {
+				return createCollections(ctx, db, "audit_log")
+			},
+			Down: func(ctx context.Context) error {
+				if err := db.Collection("audit_log").Drop(ctx); err != nil {
+					return err
+				}
+				_, err := db.Collection("counters").DeleteOne(ctx, bson.M{"_id": "audit_log"})
+				return err
+			},
+		},
+	}
+}
+
+// eachTenanted calls fn with every collection that has a tenant_id.
+func eachTenanted(db *mongo.Database, fn func(*mongo.Collection) error) error {
+	for _, name := range []string{"posts", "webhooks", "webhook_deliveries", "outbox"} {
+		if err := fn(db.Collection(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCollections creates the named collections, skipping those that
+// already exist.
+func createCollections(ctx context.Context, db *mongo.Database, names ...string) error {
+	for _, name := range names {
+		err := db.CreateCollection(ctx, name)
+		var cmdErr mongo.CommandError
+		if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceExists") {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationDriver stores the version in schema_migrations and uses a
+// document with a fixed _id in migration_lock as a mutex between replicas.
+type migrationDriver struct {
+	versions *mongo.Collection
+	locks    *mongo.Collection
+}
+
+// Migrator returns a runner for the MongoDB migrations.
+func (m *MongoDB) Migrator() (*migrate.Migrator, error) {
+	d := &migrationDriver{
+		versions: m.db.Collection("schema_migrations"),
+		locks:    m.db.Collection("migration_lock"),
+	}
+	return migrate.New(d, migrations(m.db)), nil
+}
+
+func (d *migrationDriver) Lock(ctx context.Context) error {
+	for {
+		// Clear a lock left behind by a runner that crashed.
+		if _, err := d.locks.DeleteOne(ctx, bson.M{"_id": "lock", "locked_at": bson.M{"$lt": time.Now().Add(-lockTTL)}}); err != nil {
+			return err
+		}
+
+		_, err := d.locks.InsertOne(ctx, bson.M{"_id": "lock", "locked_at": time.Now()})
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (d *migrationDriver) Unlock(ctx context.Context) error {
+	_, err := d.locks.DeleteOne(ctx, bson.M{"_id": "lock"})
+	return err
+}
+
+func (d *migrationDriver) Version(ctx context.Context) (uint, bool, error) {
+	var doc struct {
+		Version int64 `bson:"version"`
+		Dirty   bool  `bson:"dirty"`
+	}
+	err := d.versions.FindOne(ctx, bson.M{"_id": "version"}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return uint(doc.Version), doc.Dirty, nil
+}
+
+func (d *migrationDriver) SetVersion(ctx context.Context, version uint, dirty bool) error {
+	if version == 0 && !dirty {
+		_, err := d.versions.DeleteOne(ctx, bson.M{"_id": "version"})
+		return err
+	}
+	_, err := d.versions.UpdateOne(ctx,
+		bson.M{"_id": "version"},
+		bson.M{"$set": bson.M{"version": int64(version), "dirty": dirty}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
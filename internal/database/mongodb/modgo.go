@@ -3,7 +3,9 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -15,29 +17,228 @@ import (
 
 // MongoDB struct
 type MongoDB struct {
-	client *mongo.Client
-	posts  *mongo.Collection
+	client   *mongo.Client
+	db       *mongo.Database
+	posts    *mongo.Collection
+	counters *mongo.Collection
+	retry    database.RetryPolicy
+	pool     *poolMonitor
+	outbox   bool
+	tenant   string
+	// transactions is whether the server is a replica set member or a
+	// mongos; a standalone server has no transactions.
+	transactions bool
 }
 
-// NewMongoDB initializes the connection
-func NewMongoDB(uri string) (database.DB, error) {
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+// NewMongoDB connects to uri and uses the dbName database, retrying the
+// initial connection according to opts.Retry.
+func NewMongoDB(uri, dbName string, opts database.Options) (database.DB, error) {
+	pool := &poolMonitor{}
+	clientOpts := options.Client().ApplyURI(uri).SetPoolMonitor(pool.monitor())
+	if opts.MaxOpenConns > 0 {
+		clientOpts.SetMaxPoolSize(uint64(opts.MaxOpenConns))
+	}
+	if opts.MinOpenConns > 0 {
+		clientOpts.SetMinPoolSize(uint64(opts.MinOpenConns))
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		clientOpts.SetMaxConnIdleTime(opts.ConnMaxIdleTime)
+	}
+	if opts.ConnectTimeout > 0 {
+		clientOpts.SetConnectTimeout(opts.ConnectTimeout)
+		clientOpts.SetServerSelectionTimeout(opts.ConnectTimeout)
+	}
+
+	client, err := mongo.Connect(context.Background(), clientOpts)
 	if err != nil {
 		log.Println("MongoDB connection failed:", err)
 		return nil, database.ErrFailedConnection
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	err = client.Connect(ctx)
+	err = opts.Retry.Do(context.Background(), database.Always, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout())
+		defer cancel()
+
+		err := client.Ping(ctx, nil)
+		if err != nil {
+			log.Println("MongoDB connect failed:", err)
+		}
+		return err
+	})
 	if err != nil {
-		log.Println("MongoDB connect failed:", err)
+		client.Disconnect(context.Background())
 		return nil, database.ErrFailedConnection
 	}
 
 	log.Println("Connected to MongoDB")
-	db := client.Database("blog")
-	return &MongoDB{client: client, posts: db.Collection("posts")}, nil
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout())
+	defer cancel()
+
+	transactions, err := supportsTransactions(ctx, client)
+	if err != nil {
+		log.Println("MongoDB hello failed:", err)
+		client.Disconnect(context.Background())
+		return nil, database.ErrFailedConnection
+	}
+	if opts.Outbox && !transactions {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("%w: the outbox needs a replica set or sharded cluster, not a standalone server", database.ErrFailedConnection)
+	}
+
+	db := client.Database(dbName)
+	m := &MongoDB{
+		client:   client,
+		db:       db,
+		posts:    db.Collection("posts"),
+		counters: db.Collection("counters"),
+		retry:    opts.Retry,
+		pool:     pool,
+		outbox:   opts.Outbox,
+		tenant:   database.DefaultTenant,
+
+		transactions: transactions,
+	}
+
+	if err := m.ensureIndexes(ctx); err != nil {
+		log.Println("MongoDB index creation failed:", err)
+		return nil, database.ErrFailedConnection
+	}
+	if err := syncCounter(ctx, db); err != nil {
+		log.Println("MongoDB counter sync failed:", err)
+		return nil, database.ErrFailedConnection
+	}
+
+	return m, nil
+}
+
+// supportsTransactions reports whether the server is a replica set member
+// or a mongos, which hello tells by a set name or "isdbgrid".
+func supportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+func (m *MongoDB) ForTenant(tenantID string) database.DB {
+	view := *m
+	view.tenant = tenantID
+	return &view
+}
+
+// scoped adds the tenant to filter.
+func (m *MongoDB) scoped(filter bson.M) bson.M {
+	filter["tenant_id"] = m.tenant
+	return filter
+}
+
+// isTransient reports whether err is a network failure or carries one of
+// the server's retryable labels.
+func isTransient(err error) bool {
+	if mongo.IsNetworkError(err) {
+		return true
+	}
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) {
+		return labeled.HasErrorLabel("RetryableWriteError") || labeled.HasErrorLabel("TransientTransactionError")
+	}
+	return false
+}
+
+// ensureIndexes creates the indexes the queries rely on. The unique index
+// on id is what makes the integer IDs behave like a primary key.
+func (m *MongoDB) ensureIndexes(ctx context.Context) error {
+	idUnique := mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("id_unique"),
+	}
+	tenant := mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "id", Value: 1}},
+		Options: options.Index().SetName("tenant"),
+	}
+	if _, err := m.posts.Indexes().CreateMany(ctx, []mongo.IndexModel{idUnique, tenant}); err != nil {
+		return err
+	}
+	if _, err := m.webhooks().Indexes().CreateMany(ctx, []mongo.IndexModel{idUnique, tenant}); err != nil {
+		return err
+	}
+	if _, err := m.deliveries().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		idUnique,
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "id", Value: -1}}, Options: options.Index().SetName("webhook")},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}, Options: options.Index().SetName("pending")},
+	}); err != nil {
+		return err
+	}
+	if _, err := m.outboxEvents().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		idUnique,
+		{Keys: bson.D{{Key: "dispatched_at", Value: 1}, {Key: "id", Value: 1}}, Options: options.Index().SetName("pending")},
+	}); err != nil {
+		return err
+	}
+	if _, err := m.apiKeys().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		idUnique,
+		{Keys: bson.D{{Key: "prefix", Value: 1}}, Options: options.Index().SetUnique(true).SetName("prefix_unique")},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user", Value: 1}, {Key: "id", Value: 1}}, Options: options.Index().SetName("tenant_user")},
+	}); err != nil {
+		return err
+	}
+	_, err := m.audit().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		idUnique,
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "time", Value: 1}}, Options: options.Index().SetName("tenant_time")},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "actor", Value: 1}, {Key: "time", Value: 1}}, Options: options.Index().SetName("tenant_actor")},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "entity", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "time", Value: 1}},
+			Options: options.Index().SetName("tenant_entity")},
+	})
+	return err
+}
+
+// syncCounter moves the posts counter up to the highest existing ID, so
+// posts inserted without going through nextID (seeds, restores) never
+// collide with new ones.
+func syncCounter(ctx context.Context, db *mongo.Database) error {
+	var last models.Post
+	err := db.Collection("posts").FindOne(ctx, bson.D{},
+		options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}}).SetProjection(bson.M{"id": 1}),
+	).Decode(&last)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = db.Collection("counters").UpdateOne(ctx,
+		bson.M{"_id": "posts"},
+		bson.M{"$max": bson.M{"seq": last.ID}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// nextID atomically increments the posts counter, the equivalent of the
+// SERIAL sequence in PostgreSQL.
+func (m *MongoDB) nextID(ctx context.Context) (int, error) {
+	return m.nextSeq(ctx, "posts")
+}
+
+// nextSeq atomically increments the counter of the named collection.
+func (m *MongoDB) nextSeq(ctx context.Context, name string) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := m.counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": name},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
 }
 
 // GetPosts retrieves all posts from MongoDB
@@ -45,19 +246,19 @@ func (m *MongoDB) GetPosts() ([]models.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := m.posts.Find(ctx, bson.D{})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
 	var posts []models.Post
-	for cursor.Next(ctx) {
-		var post models.Post
-		if err := cursor.Decode(&post); err != nil {
-			return nil, err
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.posts.Find(ctx, m.scoped(bson.M{}), options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+		if err != nil {
+			return err
 		}
-		posts = append(posts, post)
+		defer cursor.Close(ctx)
+
+		posts = nil
+		return cursor.All(ctx, &posts)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if len(posts) == 0 {
@@ -72,8 +273,15 @@ func (m *MongoDB) GetPostByID(id string) (models.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	postID, err := strconv.Atoi(id)
+	if err != nil {
+		return models.Post{}, database.ErrNotFound
+	}
+
 	var post models.Post
-	err := m.posts.FindOne(ctx, bson.M{"id": id}).Decode(&post)
+	err = m.retry.Do(ctx, isTransient, func() error {
+		return m.posts.FindOne(ctx, m.scoped(bson.M{"id": postID})).Decode(&post)
+	})
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return models.Post{}, database.ErrNotFound
@@ -84,28 +292,269 @@ func (m *MongoDB) GetPostByID(id string) (models.Post, error) {
 }
 
 func (m *MongoDB) CreatePost(post models.Post) (models.Post, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return m.CreatePostAudited(context.Background(), post, nil)
+}
+
+func (m *MongoDB) CreatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := m.posts.InsertOne(ctx, post)
-	if err != nil {
-		return models.Post{}, err
-	}
-	return post, nil
+	return m.write(ctx, models.PostCreated, audit, func(ctx context.Context) (*models.Post, models.Post, error) {
+		id, err := m.nextID(ctx)
+		if err != nil {
+			return nil, models.Post{}, err
+		}
+
+		now := now()
+		created := post
+		created.ID = id
+		created.TenantID = m.tenant
+		created.CreatedAt = now
+		created.UpdatedAt = now
+
+		if _, err := m.posts.InsertOne(ctx, created); err != nil {
+			return nil, models.Post{}, err
+		}
+		return nil, created, nil
+	})
 }
 
 func (m *MongoDB) UpdatePost(post models.Post) (models.Post, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return m.UpdatePostAudited(context.Background(), post, nil)
+}
+
+// UpdatePostAudited changes title and body only, like the PostgreSQL
+// UPDATE, and returns the stored document.
+func (m *MongoDB) UpdatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := m.posts.ReplaceOne(ctx, bson.M{"id": post.ID}, post)
+	var stored models.Post
+	err := m.retry.Do(ctx, isTransient, func() (err error) {
+		stored, err = m.write(ctx, models.PostUpdated, audit, func(ctx context.Context) (*models.Post, models.Post, error) {
+			// The document as it was is the audit entry's before; the
+			// update is applied to it rather than read back.
+			var before models.Post
+			at := now()
+			err := m.posts.FindOneAndUpdate(ctx,
+				m.scoped(bson.M{"id": post.ID}),
+				bson.M{"$set": bson.M{"title": post.Title, "body": post.Body, "updated_at": at}},
+				options.FindOneAndUpdate().SetReturnDocument(options.Before),
+			).Decode(&before)
+			if err != nil {
+				return nil, models.Post{}, err
+			}
+			updated := before
+			updated.Title = post.Title
+			updated.Body = post.Body
+			updated.UpdatedAt = at
+			return &before, updated, nil
+		})
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return models.Post{}, database.ErrNotFound
 		}
 		return models.Post{}, err
 	}
-	return post, nil
+	return stored, nil
+}
+
+func (m *MongoDB) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	posts := []models.Post{}
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.posts.Find(ctx, m.scoped(bson.M{"id": bson.M{"$in": ids}}), options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		posts = []models.Post{}
+		return cursor.All(ctx, &posts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (m *MongoDB) ListPosts(ctx context.Context, after, limit int) ([]models.Post, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	posts := []models.Post{}
+	if limit <= 0 {
+		return posts, nil
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetLimit(int64(limit))
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.posts.Find(ctx, m.scoped(bson.M{"id": bson.M{"$gt": after}}), opts)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		posts = []models.Post{}
+		return cursor.All(ctx, &posts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (m *MongoDB) CountPosts(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var n int64
+	err := m.retry.Do(ctx, isTransient, func() error {
+		var err error
+		n, err = m.posts.CountDocuments(ctx, m.scoped(bson.M{}))
+		return err
+	})
+	return int(n), err
+}
+
+func (m *MongoDB) EachPost(ctx context.Context, fn func(models.Post) error) error {
+	cursor, err := m.posts.Find(ctx, m.scoped(bson.M{}), options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var post models.Post
+		if err := cursor.Decode(&post); err != nil {
+			return err
+		}
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// ImportPosts runs multi-post imports in a transaction, which MongoDB only
+// supports on replica sets and sharded clusters. On a standalone server
+// single posts are written directly, so per-line imports work there too.
+func (m *MongoDB) ImportPosts(ctx context.Context, posts []models.Post, mode database.ImportMode) ([]models.Post, error) {
+	return m.ImportPostsAudited(ctx, posts, mode, nil)
+}
+
+func (m *MongoDB) ImportPostsAudited(ctx context.Context, posts []models.Post, mode database.ImportMode, audit database.AuditFunc[models.Post]) ([]models.Post, error) {
+	if len(posts) <= 1 && !m.transactions {
+		return m.importPosts(ctx, posts, mode, audit)
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	stored, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return m.importPosts(sc, posts, mode, audit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored.([]models.Post), nil
+}
+
+func (m *MongoDB) importPosts(ctx context.Context, posts []models.Post, mode database.ImportMode, audit database.AuditFunc[models.Post]) ([]models.Post, error) {
+	now := now()
+	stored := make([]models.Post, 0, len(posts))
+	for _, post := range posts {
+		if mode == database.ImportInsert {
+			id, err := m.nextID(ctx)
+			if err != nil {
+				return nil, err
+			}
+			post.ID = id
+		}
+		post.TenantID = m.tenant
+		post.UpdatedAt = orNow(post.UpdatedAt, now)
+		set := bson.M{"title": post.Title, "body": post.Body, "updated_at": post.UpdatedAt}
+		// A replaced post without a created_at keeps its own.
+		update := bson.M{"$set": set, "$setOnInsert": bson.M{"created_at": now}}
+		if !post.CreatedAt.IsZero() {
+			post.CreatedAt = orNow(post.CreatedAt, now)
+			set["created_at"] = post.CreatedAt
+			delete(update, "$setOnInsert")
+		}
+
+		// A post of another tenant does not match the filter, so the upsert
+		// inserts and collides with it on the unique id index. The post it
+		// replaced, if any, is the audit entry's before.
+		var before *models.Post
+		var replaced models.Post
+		err := m.posts.FindOneAndUpdate(ctx, m.scoped(bson.M{"id": post.ID}), update,
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+		).Decode(&replaced)
+		if err == nil {
+			before = &replaced
+			if post.CreatedAt.IsZero() {
+				post.CreatedAt = replaced.CreatedAt
+			}
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, database.ErrTenantMismatch
+			}
+			return nil, err
+		} else if post.CreatedAt.IsZero() {
+			post.CreatedAt = now
+		}
+		if m.outbox {
+			if err := m.insertEvent(ctx, importEvent(mode), post); err != nil {
+				return nil, err
+			}
+		}
+		if audit != nil {
+			entry, err := audit(before, &post)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := m.appendAudit(ctx, entry); err != nil {
+				return nil, err
+			}
+		}
+		stored = append(stored, post)
+	}
+
+	if mode == database.ImportUpsert {
+		if err := syncCounter(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
+
+func orNow(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+// now returns the current time at the millisecond precision BSON dates
+// are stored with, so returned posts equal what a later read yields.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// PoolStats reports the connection pool as seen through pool events.
+func (m *MongoDB) PoolStats() database.PoolStats {
+	return m.pool.stats()
 }
 
 // Close closes the MongoDB connection
@@ -0,0 +1,101 @@
+package mongodb_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"olbcloud.com/webapi/internal/audit"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/dbtest"
+	"olbcloud.com/webapi/internal/database/mongodb"
+	"olbcloud.com/webapi/internal/models"
+)
+
+const testDatabase = "blog_test"
+
+// TestMongoDB runs against a real server and is skipped unless
+// MONGODB_TEST_URL is set. It drops the blog_test database first. The
+// server must be a replica set, for the outbox and audited writes.
+func TestMongoDB(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URL")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	require.NoError(t, client.Database(testDatabase).Drop(context.Background()))
+	client.Disconnect(context.Background())
+
+	db, err := mongodb.NewMongoDB(uri, testDatabase, database.Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	dbtest.Run(t, db)
+	dbtest.RunWebhooks(t, db.(database.WebhookStore))
+	dbtest.RunAPIKeys(t, db, db.(database.APIKeyStore))
+	dbtest.RunAudit(t, db, db.(database.AuditLog))
+	dbtest.RunTenants(t, db)
+	dbtest.RunAuditedWrites(t, db)
+
+	withOutbox, err := mongodb.NewMongoDB(uri, testDatabase, database.Options{Outbox: true})
+	require.NoError(t, err)
+	defer withOutbox.Close()
+
+	dbtest.RunOutbox(t, withOutbox)
+}
+
+// TestMongoDBStandalone runs against a server without transactions and is
+// skipped unless MONGODB_STANDALONE_TEST_URL is set. It drops the
+// blog_test database first.
+func TestMongoDBStandalone(t *testing.T) {
+	uri := os.Getenv("MONGODB_STANDALONE_TEST_URL")
+	if uri == "" {
+		t.Skip("MONGODB_STANDALONE_TEST_URL not set")
+	}
+	ctx := context.Background()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	require.NoError(t, client.Database(testDatabase).Drop(ctx))
+	client.Disconnect(ctx)
+
+	_, err = mongodb.NewMongoDB(uri, testDatabase, database.Options{Outbox: true})
+	assert.ErrorIs(t, err, database.ErrFailedConnection, "the outbox needs transactions")
+
+	db, err := mongodb.NewMongoDB(uri, testDatabase, database.Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	dbtest.Run(t, db)
+	dbtest.RunWebhooks(t, db.(database.WebhookStore))
+	dbtest.RunAPIKeys(t, db, db.(database.APIKeyStore))
+	dbtest.RunAudit(t, db, db.(database.AuditLog))
+	dbtest.RunTenants(t, db)
+
+	view := db.ForTenant("tenant-standalone")
+	w := view.(database.AuditedWriter)
+	posts := audit.Func(ctx, "post", func(p models.Post) int { return p.ID })
+	created, err := w.CreatePostAudited(ctx, models.Post{Title: "Hello", Body: "World"}, posts)
+	require.NoError(t, err)
+	_, err = w.UpdatePostAudited(ctx, models.Post{ID: created.ID, Title: "Hi", Body: "World"}, posts)
+	require.NoError(t, err)
+	_, err = w.ImportPostsAudited(ctx, []models.Post{{ID: created.ID, Title: "Imported", Body: "b"}}, database.ImportUpsert, posts)
+	require.NoError(t, err, "single-post imports need no transaction")
+	_, err = w.CreateWebhookAudited(ctx, models.Webhook{URL: "https://example.com/standalone", Events: []string{"post.created"}, Secret: "s"},
+		audit.Func(ctx, "webhook", func(h models.Webhook) int { return h.ID }))
+	require.NoError(t, err)
+
+	entries, err := view.(database.AuditLog).ListAudit(ctx, database.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4, "writes are audited without transactions")
+	assert.Equal(t, "webhook", entries[0].Entity)
+	assert.Contains(t, string(entries[1].After), `"title":"Imported"`)
+	assert.Contains(t, string(entries[2].After), `"title":"Hi"`)
+	assert.Equal(t, models.AuditCreate, entries[3].Action)
+}
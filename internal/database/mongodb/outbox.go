@@ -0,0 +1,143 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func (m *MongoDB) outboxEvents() *mongo.Collection { return m.db.Collection("outbox") }
+
+// write runs fn directly or, with the outbox enabled or audit set, in a
+// session transaction that also records an event of type typ for the post
+// fn stored and appends the entry audit describes of the change. fn may
+// run more than once when the transaction is retried.
+func (m *MongoDB) write(ctx context.Context, typ string, audit database.AuditFunc[models.Post], fn func(ctx context.Context) (before *models.Post, after models.Post, err error)) (models.Post, error) {
+	var post models.Post
+	err := audited(ctx, m, m.outbox, audit, func(ctx context.Context) (*models.Post, *models.Post, error) {
+		before, after, err := fn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if m.outbox {
+			if err := m.insertEvent(ctx, typ, after); err != nil {
+				return nil, nil, err
+			}
+		}
+		post = after
+		return before, &post, nil
+	})
+	if err != nil {
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+func (m *MongoDB) insertEvent(ctx context.Context, typ string, post models.Post) error {
+	event, err := models.NewPostEvent(typ, post)
+	if err != nil {
+		return err
+	}
+	// The counter stays written by this transaction until it commits, so a
+	// concurrent one conflicts and retries rather than commit a later ID
+	// first.
+	if event.ID, err = m.nextSeq(ctx, "outbox"); err != nil {
+		return err
+	}
+	event.TenantID = m.tenant
+	event.CreatedAt = now()
+	_, err = m.outboxEvents().InsertOne(ctx, event)
+	return err
+}
+
+// importEvent matches how the service reports imports: inserted posts are
+// created, upserted ones may overwrite and count as updated.
+func importEvent(mode database.ImportMode) string {
+	if mode == database.ImportInsert {
+		return models.PostCreated
+	}
+	return models.PostUpdated
+}
+
+func (m *MongoDB) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	return m.findEvents(ctx, m.scoped(bson.M{"dispatched_at": nil}), limit)
+}
+
+func (m *MongoDB) EventsSince(ctx context.Context, afterID, limit int) ([]models.OutboxEvent, error) {
+	return m.findEvents(ctx, m.scoped(bson.M{"id": bson.M{"$gt": afterID}}), limit)
+}
+
+func (m *MongoDB) LastEventID(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var last models.OutboxEvent
+	err := m.retry.Do(ctx, isTransient, func() error {
+		return m.outboxEvents().FindOne(ctx, m.scoped(bson.M{}), options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}})).Decode(&last)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return last.ID, err
+}
+
+func (m *MongoDB) findEvents(ctx context.Context, filter bson.M, limit int) ([]models.OutboxEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	events := []models.OutboxEvent{}
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.outboxEvents().Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetLimit(int64(limit)))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		events = []models.OutboxEvent{}
+		return cursor.All(ctx, &events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkDispatched keeps the first dispatch time if the event was already
+// marked.
+func (m *MongoDB) MarkDispatched(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var res *mongo.UpdateResult
+	err := m.retry.Do(ctx, isTransient, func() (err error) {
+		res, err = m.outboxEvents().UpdateOne(ctx, m.scoped(bson.M{"id": id}), mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"dispatched_at": bson.M{"$ifNull": bson.A{"$dispatched_at", now()}}}}},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoDB) PurgeDispatched(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := m.outboxEvents().DeleteMany(ctx, m.scoped(bson.M{"dispatched_at": bson.M{"$lt": before.UTC()}}))
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
@@ -0,0 +1,51 @@
+package mongodb
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"olbcloud.com/webapi/internal/database"
+)
+
+// poolMonitor derives pool statistics from the driver's CMAP events, since
+// the driver exposes no stats API of its own.
+type poolMonitor struct {
+	open      atomic.Int64
+	inUse     atomic.Int64
+	checkouts atomic.Int64
+	waitNanos atomic.Int64
+}
+
+func (p *poolMonitor) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: p.handle}
+}
+
+func (p *poolMonitor) handle(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		p.open.Add(1)
+	case event.ConnectionClosed:
+		p.open.Add(-1)
+	case event.GetSucceeded:
+		p.inUse.Add(1)
+		p.checkouts.Add(1)
+		p.waitNanos.Add(int64(e.Duration))
+	case event.ConnectionReturned:
+		p.inUse.Add(-1)
+	}
+}
+
+// stats reports every checkout as a wait, with the time spent checking
+// the connection out as wait duration.
+func (p *poolMonitor) stats() database.PoolStats {
+	open := int(p.open.Load())
+	inUse := int(p.inUse.Load())
+	return database.PoolStats{
+		OpenConnections: open,
+		InUse:           inUse,
+		Idle:            max(open-inUse, 0),
+		WaitCount:       p.checkouts.Load(),
+		WaitDuration:    time.Duration(p.waitNanos.Load()),
+	}
+}
@@ -0,0 +1,198 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func (m *MongoDB) webhooks() *mongo.Collection   { return m.db.Collection("webhooks") }
+func (m *MongoDB) deliveries() *mongo.Collection { return m.db.Collection("webhook_deliveries") }
+
+func (m *MongoDB) CreateWebhook(ctx context.Context, hook models.Webhook) (models.Webhook, error) {
+	return m.CreateWebhookAudited(ctx, hook, nil)
+}
+
+func (m *MongoDB) CreateWebhookAudited(ctx context.Context, hook models.Webhook, audit database.AuditFunc[models.Webhook]) (models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var created models.Webhook
+	err := audited(ctx, m, false, audit, func(ctx context.Context) (*models.Webhook, *models.Webhook, error) {
+		id, err := m.nextSeq(ctx, "webhooks")
+		if err != nil {
+			return nil, nil, err
+		}
+		created = hook
+		created.ID = id
+		created.TenantID = m.tenant
+		created.CreatedAt = now()
+
+		if _, err := m.webhooks().InsertOne(ctx, created); err != nil {
+			return nil, nil, err
+		}
+		return nil, &created, nil
+	})
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	return created, nil
+}
+
+func (m *MongoDB) GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var hook models.Webhook
+	err := m.retry.Do(ctx, isTransient, func() error {
+		return m.webhooks().FindOne(ctx, m.scoped(bson.M{"id": id})).Decode(&hook)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Webhook{}, database.ErrNotFound
+	}
+	return hook, err
+}
+
+func (m *MongoDB) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hooks := []models.Webhook{}
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.webhooks().Find(ctx, m.scoped(bson.M{}), options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		hooks = []models.Webhook{}
+		return cursor.All(ctx, &hooks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (m *MongoDB) DeleteWebhook(ctx context.Context, id int) error {
+	return m.DeleteWebhookAudited(ctx, id, nil)
+}
+
+// DeleteWebhookAudited removes the webhook first so no new deliveries are
+// queued for it, then its deliveries.
+func (m *MongoDB) DeleteWebhookAudited(ctx context.Context, id int, audit database.AuditFunc[models.Webhook]) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return audited(ctx, m, false, audit, func(ctx context.Context) (*models.Webhook, *models.Webhook, error) {
+		var hook models.Webhook
+		err := m.webhooks().FindOneAndDelete(ctx, m.scoped(bson.M{"id": id})).Decode(&hook)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, database.ErrNotFound
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := m.deliveries().DeleteMany(ctx, m.scoped(bson.M{"webhook_id": id})); err != nil {
+			return nil, nil, err
+		}
+		return &hook, nil, nil
+	})
+}
+
+func (m *MongoDB) CreateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := m.nextSeq(ctx, "webhook_deliveries")
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	now := now()
+	d.ID = id
+	d.TenantID = m.tenant
+	d.CreatedAt = now
+	d.UpdatedAt = now
+	d.NextAttemptAt = orNow(d.NextAttemptAt, now)
+
+	if _, err := m.deliveries().InsertOne(ctx, d); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return d, nil
+}
+
+func (m *MongoDB) GetDelivery(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var d models.WebhookDelivery
+	err := m.retry.Do(ctx, isTransient, func() error {
+		return m.deliveries().FindOne(ctx, m.scoped(bson.M{"id": id})).Decode(&d)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+	return d, err
+}
+
+func (m *MongoDB) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := now()
+	update := bson.M{"$set": bson.M{
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"response_status": d.ResponseStatus,
+		"last_error":      d.LastError,
+		"next_attempt_at": orNow(d.NextAttemptAt, now),
+		"updated_at":      now,
+	}}
+
+	var stored models.WebhookDelivery
+	err := m.retry.Do(ctx, isTransient, func() error {
+		return m.deliveries().FindOneAndUpdate(ctx, m.scoped(bson.M{"id": d.ID}), update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&stored)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+	return stored, err
+}
+
+func (m *MongoDB) ListDeliveries(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error) {
+	return m.findDeliveries(ctx, m.scoped(bson.M{"webhook_id": webhookID}), bson.D{{Key: "id", Value: -1}})
+}
+
+func (m *MongoDB) PendingDeliveries(ctx context.Context) ([]models.WebhookDelivery, error) {
+	return m.findDeliveries(ctx, m.scoped(bson.M{"status": models.DeliveryPending}),
+		bson.D{{Key: "next_attempt_at", Value: 1}, {Key: "id", Value: 1}})
+}
+
+func (m *MongoDB) findDeliveries(ctx context.Context, filter bson.M, sort bson.D) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	deliveries := []models.WebhookDelivery{}
+	err := m.retry.Do(ctx, isTransient, func() error {
+		cursor, err := m.deliveries().Find(ctx, filter, options.Find().SetSort(sort))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		deliveries = []models.WebhookDelivery{}
+		return cursor.All(ctx, &deliveries)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
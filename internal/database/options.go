@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"math/rand/v2"
+	"time"
+)
+
+// Options configures connection pooling and retries for the networked
+// backends. Zero values leave the driver defaults in place. ConnectTimeout
+// bounds each connection attempt.
+//
+// PostgreSQL uses every field but MinOpenConns. MongoDB maps MaxOpenConns and
+// MinOpenConns to its pool size bounds and ConnMaxIdleTime to
+// MaxConnIdleTime; it has no equivalent of MaxIdleConns or ConnMaxLifetime.
+type Options struct {
+	ConnectTimeout  time.Duration
+	MaxOpenConns    int
+	MinOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	Retry           RetryPolicy
+	// Outbox makes the backends that implement Outbox record an event with
+	// every post they create or update, in the same transaction. MongoDB
+	// then needs a replica set or sharded cluster and refuses to open on a
+	// standalone server.
+	Outbox bool
+}
+
+// Timeout returns ConnectTimeout, defaulting to 10 seconds.
+func (o Options) Timeout() time.Duration {
+	if o.ConnectTimeout > 0 {
+		return o.ConnectTimeout
+	}
+	return 10 * time.Second
+}
+
+// RetryPolicy retries an operation with exponential backoff and full
+// jitter: attempt n sleeps a random duration in [0, min(MaxBackoff,
+// InitialBackoff*2^n)).
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Do calls fn until it succeeds, returns an error retryable rejects, the
+// attempts run out or ctx is done. It returns fn's last error.
+func (p RetryPolicy) Do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	attempts := max(p.MaxAttempts, 1)
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = fn(); err == nil || !retryable(err) {
+			return err
+		}
+		if attempt == attempts-1 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.Backoff(attempt)):
+		}
+	}
+	return err
+}
+
+// Backoff is the random delay before retrying after the given 0-based
+// attempt.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	ceiling := p.InitialBackoff << attempt
+	if ceiling <= 0 || (p.MaxBackoff > 0 && ceiling > p.MaxBackoff) {
+		ceiling = p.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// Always is a retryable func that retries every error, used while
+// establishing the initial connection.
+func Always(error) bool { return true }
+
+// PoolStats is a backend independent snapshot of the connection pool.
+type PoolStats struct {
+	OpenConnections int           `json:"open_connections"`
+	InUse           int           `json:"in_use"`
+	Idle            int           `json:"idle"`
+	WaitCount       int64         `json:"wait_count"`
+	WaitDuration    time.Duration `json:"wait_duration_ns"`
+}
+
+// StatsProvider is implemented by backends that can report pool usage.
+type StatsProvider interface {
+	PoolStats() PoolStats
+}
+
+// SQLPoolStats converts database/sql pool statistics.
+func SQLPoolStats(s sql.DBStats) PoolStats {
+	return PoolStats{
+		OpenConnections: s.OpenConnections,
+		InUse:           s.InUse,
+		Idle:            s.Idle,
+		WaitCount:       s.WaitCount,
+		WaitDuration:    s.WaitDuration,
+	}
+}
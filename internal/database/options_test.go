@@ -0,0 +1,74 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"olbcloud.com/webapi/internal/database"
+)
+
+var errTransient = errors.New("transient")
+
+func retryTransient(err error) bool { return errors.Is(err, errTransient) }
+
+func TestRetryPolicy(t *testing.T) {
+	policy := database.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	t.Run("retries transient errors until success", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), retryTransient, func() error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), retryTransient, func() error {
+			calls++
+			return errTransient
+		})
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), retryTransient, func() error {
+			calls++
+			return database.ErrNotFound
+		})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		calls := 0
+		slow := database.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+		err := slow.Do(ctx, retryTransient, func() error {
+			calls++
+			return errTransient
+		})
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("zero policy runs once", func(t *testing.T) {
+		calls := 0
+		database.RetryPolicy{}.Do(context.Background(), retryTransient, func() error {
+			calls++
+			return errTransient
+		})
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,156 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+const apiKeyColumns = `id, tenant_id, "user", name, prefix, hash, scopes, created_at, expires_at, last_used_at, revoked_at`
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.TenantID, &key.User, &key.Name, &key.Prefix, &key.Hash, pq.Array(&key.Scopes),
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt)
+	return key, err
+}
+
+func (p *PostgreSQL) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	return p.CreateAPIKeyAudited(ctx, key, nil)
+}
+
+// CreateAPIKeyAudited is not retried, like CreatePost.
+func (p *PostgreSQL) CreateAPIKeyAudited(ctx context.Context, key models.APIKey, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	var expires sql.NullTime
+	if key.ExpiresAt != nil {
+		expires = nullTime(*key.ExpiresAt)
+	}
+	err := audited(ctx, p, false, audit, func(q queryer) (*models.APIKey, *models.APIKey, error) {
+		var err error
+		key, err = scanAPIKey(q.QueryRowContext(ctx,
+			`INSERT INTO api_keys (tenant_id, "user", name, prefix, hash, scopes, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
+			 RETURNING `+apiKeyColumns,
+			p.tenant, key.User, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), expires,
+		))
+		return nil, &key, err
+	})
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (p *PostgreSQL) GetAPIKey(ctx context.Context, id int) (models.APIKey, error) {
+	return p.getAPIKey(ctx, "id = $1", id)
+}
+
+func (p *PostgreSQL) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	return p.getAPIKey(ctx, "prefix = $1", prefix)
+}
+
+func (p *PostgreSQL) getAPIKey(ctx context.Context, cond string, arg any) (models.APIKey, error) {
+	var key models.APIKey
+	err := p.retry.Do(ctx, isTransient, func() (err error) {
+		key, err = scanAPIKey(p.conn.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE "+cond+" AND tenant_id = $2", arg, p.tenant))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	return key, err
+}
+
+func (p *PostgreSQL) ListAPIKeys(ctx context.Context, user string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := p.retry.Do(ctx, isTransient, func() error {
+		rows, err := p.conn.QueryContext(ctx,
+			`SELECT `+apiKeyColumns+` FROM api_keys WHERE tenant_id = $1 AND ($2 = '' OR "user" = $2) ORDER BY id`, p.tenant, user)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		keys = []models.APIKey{}
+		for rows.Next() {
+			key, err := scanAPIKey(rows)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (p *PostgreSQL) RevokeAPIKey(ctx context.Context, id int, at time.Time) (models.APIKey, error) {
+	return p.RevokeAPIKeyAudited(ctx, id, at, nil)
+}
+
+// RevokeAPIKeyAudited keeps the first revocation time, so it is safe to
+// retry.
+func (p *PostgreSQL) RevokeAPIKeyAudited(ctx context.Context, id int, at time.Time, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	var key models.APIKey
+	err := p.retry.Do(ctx, isTransient, func() error {
+		return audited(ctx, p, false, audit, func(q queryer) (*models.APIKey, *models.APIKey, error) {
+			var before *models.APIKey
+			if audit != nil {
+				existing, err := scanAPIKey(q.QueryRowContext(ctx,
+					"SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, p.tenant))
+				if err != nil {
+					return nil, nil, err
+				}
+				if existing.RevokedAt != nil {
+					key = existing
+					return nil, nil, nil
+				}
+				before = &existing
+			}
+
+			var err error
+			key, err = scanAPIKey(q.QueryRowContext(ctx,
+				`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $1, NOW())
+				 WHERE id = $2 AND tenant_id = $3
+				 RETURNING `+apiKeyColumns,
+				nullTime(at), id, p.tenant,
+			))
+			return before, &key, err
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (p *PostgreSQL) TouchAPIKey(ctx context.Context, id int, at time.Time) error {
+	var n int64
+	err := p.retry.Do(ctx, isTransient, func() error {
+		res, err := p.conn.ExecContext(ctx, "UPDATE api_keys SET last_used_at = COALESCE($1, NOW()) WHERE id = $2 AND tenant_id = $3",
+			nullTime(at), id, p.tenant)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+const auditColumns = "id, tenant_id, at, actor, action, entity, entity_id, before, after, request_id, client_ip"
+
+func scanAudit(row scanner) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var before, after sql.NullString
+	err := row.Scan(&e.ID, &e.TenantID, &e.Time, &e.Actor, &e.Action, &e.Entity, &e.EntityID, &before, &after, &e.RequestID, &e.ClientIP)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	if before.Valid {
+		e.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		e.After = json.RawMessage(after.String)
+	}
+	return e, nil
+}
+
+// nullJSON stores an empty snapshot as NULL.
+func nullJSON(raw json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(raw), Valid: len(raw) > 0}
+}
+
+// AppendAudit is not retried, like CreatePost, so an entry is not written
+// twice.
+func (p *PostgreSQL) AppendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	return p.appendAudit(ctx, p.conn, entry)
+}
+
+func (p *PostgreSQL) appendAudit(ctx context.Context, q queryer, entry models.AuditEntry) (models.AuditEntry, error) {
+	return scanAudit(q.QueryRowContext(ctx,
+		`INSERT INTO audit_log (tenant_id, at, actor, action, entity, entity_id, before, after, request_id, client_ip)
+		 VALUES ($1, COALESCE($2, NOW()), $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING `+auditColumns,
+		p.tenant, nullTime(entry.Time), entry.Actor, entry.Action, entry.Entity, entry.EntityID,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.RequestID, entry.ClientIP,
+	))
+}
+
+// audited runs write on the pool or, when audit is set or inTx asks for
+// it, in a transaction that also appends the entry audit describes from
+// the entity before and after write. A write that changed nothing returns
+// neither and appends no entry.
+func audited[T any](ctx context.Context, p *PostgreSQL, inTx bool, audit database.AuditFunc[T], write func(q queryer) (before, after *T, err error)) error {
+	if audit == nil && !inTx {
+		_, _, err := write(p.conn)
+		return err
+	}
+
+	tx, err := p.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, after, err := write(tx)
+	if err != nil {
+		return err
+	}
+	if audit != nil && (before != nil || after != nil) {
+		entry, err := audit(before, after)
+		if err != nil {
+			return err
+		}
+		if _, err := p.appendAudit(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *PostgreSQL) ListAudit(ctx context.Context, filter database.AuditFilter) ([]models.AuditEntry, error) {
+	conds := []string{"tenant_id = $1"}
+	args := []any{p.tenant}
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.Actor != "" {
+		add("actor = ?", filter.Actor)
+	}
+	if filter.Entity != "" {
+		add("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		add("entity_id = ?", filter.EntityID)
+	}
+	if !filter.Since.IsZero() {
+		add("at >= ?", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		add("at < ?", filter.Until.UTC())
+	}
+	query := "SELECT " + auditColumns + " FROM audit_log WHERE " + strings.Join(conds, " AND ") + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	var entries []models.AuditEntry
+	err := p.retry.Do(ctx, isTransient, func() error {
+		rows, err := p.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		entries = []models.AuditEntry{}
+		for rows.Next() {
+			e, err := scanAudit(rows)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
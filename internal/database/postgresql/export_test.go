@@ -0,0 +1,4 @@
+package postgresql
+
+// InsertEvent lets tests record an event in a transaction they hold open.
+var InsertEvent = insertEvent
@@ -0,0 +1,121 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+
+	"github.com/lib/pq"
+
+	"olbcloud.com/webapi/internal/migrate"
+	"olbcloud.com/webapi/migrations"
+)
+
+// advisoryLockKey identifies the migration lock among other advisory locks
+// taken on the same server.
+var advisoryLockKey = func() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("olbcloud.com/webapi/migrations"))
+	return int64(h.Sum64())
+}()
+
+// migrationDriver keeps its version in the same schema_migrations table
+// golang-migrate uses, so databases migrated by the CLI carry over. All
+// statements run on one connection because the advisory lock is bound to
+// the session that took it.
+type migrationDriver struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// Migrator returns a runner for the SQL files embedded from migrations/.
+func (p *PostgreSQL) Migrator() (*migrate.Migrator, error) {
+	d := &migrationDriver{db: p.conn}
+	ms, err := migrate.LoadSQL(migrations.FS, d.exec)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(d, ms), nil
+}
+
+func (d *migrationDriver) Lock(ctx context.Context) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey); err != nil {
+		conn.Close()
+		return err
+	}
+	if _, err := conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`,
+	); err != nil {
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockKey)
+		conn.Close()
+		return err
+	}
+	d.conn = conn
+	return nil
+}
+
+func (d *migrationDriver) Unlock(ctx context.Context) error {
+	if d.conn == nil {
+		return nil
+	}
+	defer func() {
+		d.conn.Close()
+		d.conn = nil
+	}()
+	_, err := d.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockKey)
+	return err
+}
+
+func (d *migrationDriver) Version(ctx context.Context) (uint, bool, error) {
+	var q interface {
+		QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	} = d.db
+	if d.conn != nil {
+		q = d.conn
+	}
+
+	var version int64
+	var dirty bool
+	err := q.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isUndefinedTable(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+func (d *migrationDriver) SetVersion(ctx context.Context, version uint, dirty bool) error {
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "TRUNCATE schema_migrations"); err != nil {
+		return err
+	}
+	if version > 0 || dirty {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)", int64(version), dirty); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *migrationDriver) exec(ctx context.Context, query string) error {
+	_, err := d.conn.ExecContext(ctx, query)
+	return err
+}
+
+// isUndefinedTable reports whether err is PostgreSQL's undefined_table.
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
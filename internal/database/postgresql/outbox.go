@@ -0,0 +1,148 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// queryer is what a write needs from either the pool or a transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// write runs fn on the pool or, with the outbox enabled or audit set, in a
+// transaction that also records an event of type typ for the post fn
+// stored and appends the entry audit describes of the change.
+func (p *PostgreSQL) write(ctx context.Context, typ string, audit database.AuditFunc[models.Post], fn func(q queryer) (before *models.Post, after models.Post, err error)) (models.Post, error) {
+	var post models.Post
+	err := audited(ctx, p, p.outbox, audit, func(q queryer) (*models.Post, *models.Post, error) {
+		before, after, err := fn(q)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.outbox {
+			if err := insertEvent(ctx, q, p.tenant, typ, after); err != nil {
+				return nil, nil, err
+			}
+		}
+		post = after
+		return before, &post, nil
+	})
+	if err != nil {
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+// outboxLock is the advisory lock insertEvent holds until its transaction
+// ends. Without it a transaction could take an outbox ID and commit after
+// one that took a later ID, and readers that follow the outbox by ID would
+// have moved past it for good.
+const outboxLock = 0x6f7574626f78 // "outbox"
+
+// insertEvent records an event in q, which must be a transaction: the lock
+// it takes is only released when that transaction ends.
+func insertEvent(ctx context.Context, q queryer, tenant, typ string, post models.Post) error {
+	event, err := models.NewPostEvent(typ, post)
+	if err != nil {
+		return err
+	}
+	if _, err := q.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", outboxLock); err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, "INSERT INTO outbox (tenant_id, type, payload) VALUES ($1, $2, $3)",
+		tenant, event.Type, string(event.Payload))
+	return err
+}
+
+// importEvent matches how the service reports imports: inserted posts are
+// created, upserted ones may overwrite and count as updated.
+func importEvent(mode database.ImportMode) string {
+	if mode == database.ImportInsert {
+		return models.PostCreated
+	}
+	return models.PostUpdated
+}
+
+func (p *PostgreSQL) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	return p.events(ctx, `SELECT id, tenant_id, type, payload, created_at, dispatched_at FROM outbox
+		WHERE tenant_id = $1 AND dispatched_at IS NULL ORDER BY id LIMIT $2`, p.tenant, limit)
+}
+
+func (p *PostgreSQL) EventsSince(ctx context.Context, afterID, limit int) ([]models.OutboxEvent, error) {
+	return p.events(ctx, `SELECT id, tenant_id, type, payload, created_at, dispatched_at FROM outbox
+		WHERE tenant_id = $1 AND id > $2 ORDER BY id LIMIT $3`, p.tenant, afterID, limit)
+}
+
+func (p *PostgreSQL) LastEventID(ctx context.Context) (int, error) {
+	var id int
+	err := p.retry.Do(ctx, isTransient, func() error {
+		return p.conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM outbox WHERE tenant_id = $1", p.tenant).Scan(&id)
+	})
+	return id, err
+}
+
+func (p *PostgreSQL) events(ctx context.Context, query string, args ...any) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := p.retry.Do(ctx, isTransient, func() error {
+		rows, err := p.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		events = []models.OutboxEvent{}
+		for rows.Next() {
+			var e models.OutboxEvent
+			var payload string
+			if err := rows.Scan(&e.ID, &e.TenantID, &e.Type, &payload, &e.CreatedAt, &e.DispatchedAt); err != nil {
+				return err
+			}
+			e.Payload = json.RawMessage(payload)
+			events = append(events, e)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (p *PostgreSQL) MarkDispatched(ctx context.Context, id int) error {
+	var n int64
+	err := p.retry.Do(ctx, isTransient, func() error {
+		res, err := p.conn.ExecContext(ctx, "UPDATE outbox SET dispatched_at = COALESCE(dispatched_at, NOW()) WHERE id = $1 AND tenant_id = $2", id, p.tenant)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgreSQL) PurgeDispatched(ctx context.Context, before time.Time) (int, error) {
+	var n int64
+	err := p.retry.Do(ctx, isTransient, func() error {
+		res, err := p.conn.ExecContext(ctx, "DELETE FROM outbox WHERE tenant_id = $1 AND dispatched_at < $2", p.tenant, before.UTC())
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
+}
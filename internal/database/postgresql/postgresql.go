@@ -1,50 +1,95 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"io"
 	"log"
+	"net"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"olbcloud.com/webapi/internal/database"
 	"olbcloud.com/webapi/internal/models"
 )
 
 // PostgreSQL struct
 type PostgreSQL struct {
-	conn *sql.DB
+	conn   *sql.DB
+	retry  database.RetryPolicy
+	outbox bool
+	tenant string
 }
 
-func NewPostgreSQL(dsn string) (database.DB, error) {
+func NewPostgreSQL(dsn string, opts database.Options) (database.DB, error) {
 	conn, err := sql.Open("postgres", dsn)
 	if err != nil {
 		log.Println("PostgreSQL connection failed:", err)
 		return nil, database.ErrFailedConnection
 	}
 
-	if err := conn.Ping(); err != nil {
-		log.Println("PostgreSQL ping failed:", err)
+	if opts.MaxOpenConns > 0 {
+		conn.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		conn.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		conn.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		conn.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
+
+	err = opts.Retry.Do(context.Background(), database.Always, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout())
+		defer cancel()
+
+		err := conn.PingContext(ctx)
+		if err != nil {
+			log.Println("PostgreSQL ping failed:", err)
+		}
+		return err
+	})
+	if err != nil {
+		conn.Close()
 		return nil, database.ErrFailedConnection
 	}
 
 	log.Println("Connected to PostgreSQL")
-	return &PostgreSQL{conn: conn}, nil
+	return &PostgreSQL{conn: conn, retry: opts.Retry, outbox: opts.Outbox, tenant: database.DefaultTenant}, nil
 }
 
-func (p *PostgreSQL) GetPosts() ([]models.Post, error) {
-	rows, err := p.conn.Query("SELECT id, title, body, created_at, updated_at FROM posts")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+func (p *PostgreSQL) ForTenant(tenantID string) database.DB {
+	view := *p
+	view.tenant = tenantID
+	return &view
+}
 
+func (p *PostgreSQL) GetPosts() ([]models.Post, error) {
 	var posts []models.Post
-	for rows.Next() {
-		var p models.Post
-		if err := rows.Scan(&p.ID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			return nil, err
+	err := p.retry.Do(context.Background(), isTransient, func() error {
+		rows, err := p.conn.Query("SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE tenant_id = $1 ORDER BY id", p.tenant)
+		if err != nil {
+			return err
 		}
-		posts = append(posts, p)
+		defer rows.Close()
+
+		posts = nil
+		for rows.Next() {
+			var p models.Post
+			if err := rows.Scan(&p.ID, &p.TenantID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt); err != nil {
+				return err
+			}
+			posts = append(posts, p)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if len(posts) == 0 {
@@ -56,8 +101,10 @@ func (p *PostgreSQL) GetPosts() ([]models.Post, error) {
 
 func (p *PostgreSQL) GetPostByID(id string) (models.Post, error) {
 	var post models.Post
-	err := p.conn.QueryRow("SELECT id, title, body, created_at, updated_at FROM posts WHERE id = $1", id).
-		Scan(&post.ID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+	err := p.retry.Do(context.Background(), isTransient, func() error {
+		return p.conn.QueryRow("SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE id = $1 AND tenant_id = $2", id, p.tenant).
+			Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Post{}, database.ErrNotFound
@@ -67,37 +114,276 @@ func (p *PostgreSQL) GetPostByID(id string) (models.Post, error) {
 	return post, nil
 }
 
+// CreatePost is not retried: a failure after the INSERT reached the server
+// could otherwise create the post twice. database/sql already retries
+// connections that fail before the statement is sent.
 func (p *PostgreSQL) CreatePost(post models.Post) (models.Post, error) {
-	err := p.conn.QueryRow(
-		`INSERT INTO posts (title, body) 
-		 VALUES ($1, $2) 
-		 RETURNING id, title, body, created_at, updated_at`,
-		post.Title, post.Body,
-	).Scan(&post.ID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+	return p.CreatePostAudited(context.Background(), post, nil)
+}
 
-	if err != nil {
-		return models.Post{}, err
-	}
-	return post, nil
+func (p *PostgreSQL) CreatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	return p.write(ctx, models.PostCreated, audit, func(q queryer) (*models.Post, models.Post, error) {
+		err := q.QueryRowContext(ctx,
+			`INSERT INTO posts (title, body, tenant_id)
+			 VALUES ($1, $2, $3)
+			 RETURNING id, tenant_id, title, body, created_at, updated_at`,
+			post.Title, post.Body, p.tenant,
+		).Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+		return nil, post, err
+	})
 }
 
 func (p *PostgreSQL) UpdatePost(post models.Post) (models.Post, error) {
-	err := p.conn.QueryRow(
-		`UPDATE posts SET title = $1, body = $2, updated_at = NOW() 
-		 WHERE id = $3 
-		 RETURNING id, title, body, created_at, updated_at`,
-		post.Title, post.Body, post.ID,
-	).Scan(&post.ID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
-	
+	return p.UpdatePostAudited(context.Background(), post, nil)
+}
+
+// UpdatePostAudited sets absolute values, so repeating it is safe to
+// retry. With the outbox enabled or an audit entry a retry after a lost
+// commit acknowledgement records the event or entry twice, which
+// at-least-once delivery allows and the audit log tolerates.
+func (p *PostgreSQL) UpdatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	var stored models.Post
+	err := p.retry.Do(ctx, isTransient, func() (err error) {
+		stored, err = p.write(ctx, models.PostUpdated, audit, func(q queryer) (*models.Post, models.Post, error) {
+			var before *models.Post
+			if audit != nil {
+				existing, err := getPostForUpdate(ctx, q, p.tenant, post.ID)
+				if err != nil {
+					return nil, models.Post{}, err
+				}
+				before = &existing
+			}
+			var s models.Post
+			err := q.QueryRowContext(ctx,
+				`UPDATE posts SET title = $1, body = $2, updated_at = NOW()
+				 WHERE id = $3 AND tenant_id = $4
+				 RETURNING id, tenant_id, title, body, created_at, updated_at`,
+				post.Title, post.Body, post.ID, p.tenant,
+			).Scan(&s.ID, &s.TenantID, &s.Title, &s.Body, &s.CreatedAt, &s.UpdatedAt)
+			return before, s, err
+		})
+		return err
+	})
+
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Post{}, database.ErrNotFound
 		}
 		return models.Post{}, err
 	}
-	return post, nil
+	return stored, nil
+}
+
+// getPostForUpdate reads a post of tenant and locks it until the write's
+// transaction ends; a missing one is sql.ErrNoRows.
+func getPostForUpdate(ctx context.Context, q queryer, tenant string, id int) (models.Post, error) {
+	var post models.Post
+	err := q.QueryRowContext(ctx,
+		"SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE id = $1 AND tenant_id = $2 FOR UPDATE", id, tenant,
+	).Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+	return post, err
+}
+
+func (p *PostgreSQL) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	ids64 := make([]int64, len(ids))
+	for i, id := range ids {
+		ids64[i] = int64(id)
+	}
+
+	var posts []models.Post
+	err := p.retry.Do(ctx, isTransient, func() error {
+		rows, err := p.conn.QueryContext(ctx,
+			"SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE id = ANY($1) AND tenant_id = $2 ORDER BY id", pq.Array(ids64), p.tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		posts = []models.Post{}
+		for rows.Next() {
+			var post models.Post
+			if err := rows.Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt); err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (p *PostgreSQL) ListPosts(ctx context.Context, after, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	err := p.retry.Do(ctx, isTransient, func() error {
+		rows, err := p.conn.QueryContext(ctx,
+			"SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE tenant_id = $1 AND id > $2 ORDER BY id LIMIT $3", p.tenant, after, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		posts = []models.Post{}
+		for rows.Next() {
+			var post models.Post
+			if err := rows.Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt); err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (p *PostgreSQL) CountPosts(ctx context.Context) (int, error) {
+	var n int
+	err := p.retry.Do(ctx, isTransient, func() error {
+		return p.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM posts WHERE tenant_id = $1", p.tenant).Scan(&n)
+	})
+	return n, err
+}
+
+func (p *PostgreSQL) EachPost(ctx context.Context, fn func(models.Post) error) error {
+	rows, err := p.conn.QueryContext(ctx, "SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE tenant_id = $1 ORDER BY id", p.tenant)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post models.Post
+		if err := rows.Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt); err != nil {
+			return err
+		}
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (p *PostgreSQL) ImportPosts(ctx context.Context, posts []models.Post, mode database.ImportMode) ([]models.Post, error) {
+	return p.ImportPostsAudited(ctx, posts, mode, nil)
+}
+
+func (p *PostgreSQL) ImportPostsAudited(ctx context.Context, posts []models.Post, mode database.ImportMode, audit database.AuditFunc[models.Post]) ([]models.Post, error) {
+	tx, err := p.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO posts (title, body, created_at, updated_at, tenant_id)
+		 VALUES ($1, $2, COALESCE($3, NOW()), COALESCE($4, NOW()), $5)
+		 RETURNING id, tenant_id, title, body, created_at, updated_at`
+	if mode == database.ImportUpsert {
+		// A post of another tenant is left alone and returns no row.
+		query = `INSERT INTO posts (title, body, created_at, updated_at, tenant_id, id)
+		 VALUES ($1, $2, COALESCE($3, NOW()), COALESCE($4, NOW()), $5, $6)
+		 ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title, body = EXCLUDED.body,
+		     created_at = COALESCE($3, posts.created_at), updated_at = EXCLUDED.updated_at
+		 WHERE posts.tenant_id = EXCLUDED.tenant_id
+		 RETURNING id, tenant_id, title, body, created_at, updated_at`
+	}
+
+	stored := make([]models.Post, 0, len(posts))
+	for _, post := range posts {
+		args := []any{post.Title, post.Body, nullTime(post.CreatedAt), nullTime(post.UpdatedAt), p.tenant}
+		var before *models.Post
+		if mode == database.ImportUpsert {
+			args = append(args, post.ID)
+			if audit != nil {
+				existing, err := getPostForUpdate(ctx, tx, p.tenant, post.ID)
+				if err == nil {
+					before = &existing
+				} else if !errors.Is(err, sql.ErrNoRows) {
+					return nil, err
+				}
+			}
+		}
+		err := tx.QueryRowContext(ctx, query, args...).
+			Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, database.ErrTenantMismatch
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.outbox {
+			if err := insertEvent(ctx, tx, p.tenant, importEvent(mode), post); err != nil {
+				return nil, err
+			}
+		}
+		if audit != nil {
+			entry, err := audit(before, &post)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.appendAudit(ctx, tx, entry); err != nil {
+				return nil, err
+			}
+		}
+		stored = append(stored, post)
+	}
+
+	if mode == database.ImportUpsert {
+		// Explicit IDs bypass the SERIAL sequence; move it past them.
+		if _, err := tx.ExecContext(ctx,
+			`SELECT setval(pg_get_serial_sequence('posts', 'id'), GREATEST((SELECT MAX(id) FROM posts), 1))`,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// nullTime maps the zero time to NULL so the column default applies.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// PoolStats reports the database/sql connection pool.
+func (p *PostgreSQL) PoolStats() database.PoolStats {
+	return database.SQLPoolStats(p.conn.Stats())
 }
 
 func (p *PostgreSQL) Close() error {
 	return p.conn.Close()
 }
+
+// isTransient reports whether err is worth retrying: lost connections,
+// server restarts and serialization failures.
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code := string(pqErr.Code)
+		switch {
+		case strings.HasPrefix(code, "08"): // connection_exception
+			return true
+		case code == "40001", code == "40P01": // serialization_failure, deadlock_detected
+			return true
+		case code == "57P01", code == "57P02", code == "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		case code == "53300": // too_many_connections
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,149 @@
+package postgresql_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/dbtest"
+	"olbcloud.com/webapi/internal/database/postgresql"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/outbox"
+	"olbcloud.com/webapi/internal/stream"
+)
+
+// testDSN returns POSTGRESQL_TEST_URL scoped to a fresh, migrated schema
+// that is dropped after the test, so tests can run again on the same
+// database even though audit entries are never deleted. Tests are skipped
+// unless it is set.
+func testDSN(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv("POSTGRESQL_TEST_URL")
+	if dsn == "" {
+		t.Skip("POSTGRESQL_TEST_URL not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = conn.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	// lib/pq passes unknown settings on to the server.
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	db, err := postgresql.NewPostgreSQL(dsn, database.Options{})
+	require.NoError(t, err)
+	defer db.Close()
+	m, err := db.(database.Migrater).Migrator()
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	// The tests expect no posts, so the seeded ones go.
+	_, err = conn.Exec("TRUNCATE " + schema + ".posts RESTART IDENTITY")
+	require.NoError(t, err)
+	return dsn
+}
+
+// TestPostgreSQL runs against a real server and is skipped unless
+// POSTGRESQL_TEST_URL points at a database it may create schemas in.
+func TestPostgreSQL(t *testing.T) {
+	dsn := testDSN(t)
+
+	db, err := postgresql.NewPostgreSQL(dsn, database.Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	dbtest.Run(t, db)
+	dbtest.RunWebhooks(t, db.(database.WebhookStore))
+	dbtest.RunAPIKeys(t, db, db.(database.APIKeyStore))
+	dbtest.RunAudit(t, db, db.(database.AuditLog))
+	dbtest.RunTenants(t, db)
+	dbtest.RunAuditedWrites(t, db)
+
+	withOutbox, err := postgresql.NewPostgreSQL(dsn, database.Options{Outbox: true})
+	require.NoError(t, err)
+	defer withOutbox.Close()
+
+	dbtest.RunOutbox(t, withOutbox)
+}
+
+// TestOutboxCommitOrder interleaves two transactions recording events: the
+// one that takes the later ID must not commit first, or a follower or a
+// resuming stream client that saw it would skip the other for good.
+func TestOutboxCommitOrder(t *testing.T) {
+	dsn := testDSN(t)
+	ctx := context.Background()
+	const tenant = "tenant-commit-order"
+
+	db, err := postgresql.NewPostgreSQL(dsn, database.Options{Outbox: true})
+	require.NoError(t, err)
+	defer db.Close()
+	conn, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var got []int
+	follower := outbox.NewFollower(db.ForTenant(tenant).(database.Outbox), outbox.PublisherFunc(func(ctx context.Context, e models.OutboxEvent) error {
+		got = append(got, e.ID)
+		return nil
+	}), outbox.Options{})
+	_, err = follower.Flush(ctx)
+	require.NoError(t, err)
+
+	first, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer first.Rollback()
+	require.NoError(t, postgresql.InsertEvent(ctx, first, tenant, models.PostCreated, models.Post{ID: 1}))
+
+	second := make(chan error, 1)
+	go func() {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err == nil {
+			if err = postgresql.InsertEvent(ctx, tx, tenant, models.PostCreated, models.Post{ID: 2}); err == nil {
+				err = tx.Commit()
+			} else {
+				tx.Rollback()
+			}
+		}
+		second <- err
+	}()
+
+	select {
+	case err := <-second:
+		t.Fatalf("the later event committed first: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	n, err := follower.Flush(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "nothing is visible while the earlier event is uncommitted")
+
+	require.NoError(t, first.Commit())
+	require.NoError(t, <-second)
+	n, err = follower.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.Len(t, got, 2)
+	assert.Less(t, got[0], got[1])
+
+	since, err := stream.NewOutboxHistory(db.ForTenant(tenant).(database.Outbox), 10).Since(ctx, got[0])
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, got[1], since[0].ID, "a stream client resumes from the earlier event")
+}
@@ -0,0 +1,192 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+const webhookColumns = "id, tenant_id, url, events, secret, created_at"
+
+const deliveryColumns = `id, tenant_id, webhook_id, event, payload, status, attempts, response_status, last_error,
+	next_attempt_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row scanner) (models.Webhook, error) {
+	var hook models.Webhook
+	err := row.Scan(&hook.ID, &hook.TenantID, &hook.URL, pq.Array(&hook.Events), &hook.Secret, &hook.CreatedAt)
+	return hook, err
+}
+
+func scanDelivery(row scanner) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	err := row.Scan(&d.ID, &d.TenantID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError,
+		&d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+	d.Payload = json.RawMessage(payload)
+	return d, err
+}
+
+func (p *PostgreSQL) CreateWebhook(ctx context.Context, hook models.Webhook) (models.Webhook, error) {
+	return p.CreateWebhookAudited(ctx, hook, nil)
+}
+
+// CreateWebhookAudited is not retried, like CreatePost.
+func (p *PostgreSQL) CreateWebhookAudited(ctx context.Context, hook models.Webhook, audit database.AuditFunc[models.Webhook]) (models.Webhook, error) {
+	err := audited(ctx, p, false, audit, func(q queryer) (*models.Webhook, *models.Webhook, error) {
+		var err error
+		hook, err = scanWebhook(q.QueryRowContext(ctx,
+			`INSERT INTO webhooks (url, events, secret, tenant_id) VALUES ($1, $2, $3, $4)
+			 RETURNING `+webhookColumns,
+			hook.URL, pq.Array(hook.Events), hook.Secret, p.tenant,
+		))
+		return nil, &hook, err
+	})
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	return hook, nil
+}
+
+func (p *PostgreSQL) GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	var hook models.Webhook
+	err := p.retry.Do(ctx, isTransient, func() (err error) {
+		hook, err = scanWebhook(p.conn.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1 AND tenant_id = $2", id, p.tenant))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Webhook{}, database.ErrNotFound
+	}
+	return hook, err
+}
+
+func (p *PostgreSQL) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	err := p.retry.Do(ctx, isTransient, func() error {
+		rows, err := p.conn.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE tenant_id = $1 ORDER BY id", p.tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		hooks = []models.Webhook{}
+		for rows.Next() {
+			hook, err := scanWebhook(rows)
+			if err != nil {
+				return err
+			}
+			hooks = append(hooks, hook)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (p *PostgreSQL) DeleteWebhook(ctx context.Context, id int) error {
+	return p.DeleteWebhookAudited(ctx, id, nil)
+}
+
+// DeleteWebhookAudited removes the deliveries through ON DELETE CASCADE.
+func (p *PostgreSQL) DeleteWebhookAudited(ctx context.Context, id int, audit database.AuditFunc[models.Webhook]) error {
+	return p.retry.Do(ctx, isTransient, func() error {
+		return audited(ctx, p, false, audit, func(q queryer) (*models.Webhook, *models.Webhook, error) {
+			hook, err := scanWebhook(q.QueryRowContext(ctx,
+				"DELETE FROM webhooks WHERE id = $1 AND tenant_id = $2 RETURNING "+webhookColumns, id, p.tenant))
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil, database.ErrNotFound
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			return &hook, nil, nil
+		})
+	})
+}
+
+// CreateDelivery is not retried, like CreatePost.
+func (p *PostgreSQL) CreateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	return scanDelivery(p.conn.QueryRowContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, response_status, last_error, next_attempt_at, tenant_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW()), $9)
+		 RETURNING `+deliveryColumns,
+		d.WebhookID, d.Event, string(d.Payload), d.Status, d.Attempts, d.ResponseStatus, d.LastError, nullTime(d.NextAttemptAt), p.tenant,
+	))
+}
+
+func (p *PostgreSQL) GetDelivery(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := p.retry.Do(ctx, isTransient, func() (err error) {
+		d, err = scanDelivery(p.conn.QueryRowContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1 AND tenant_id = $2", id, p.tenant))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+	return d, err
+}
+
+// UpdateDelivery sets absolute values, so repeating it is safe to retry.
+func (p *PostgreSQL) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	var stored models.WebhookDelivery
+	err := p.retry.Do(ctx, isTransient, func() (err error) {
+		stored, err = scanDelivery(p.conn.QueryRowContext(ctx,
+			`UPDATE webhook_deliveries
+			 SET status = $1, attempts = $2, response_status = $3, last_error = $4,
+			     next_attempt_at = COALESCE($5, NOW()), updated_at = NOW()
+			 WHERE id = $6 AND tenant_id = $7
+			 RETURNING `+deliveryColumns,
+			d.Status, d.Attempts, d.ResponseStatus, d.LastError, nullTime(d.NextAttemptAt), d.ID, p.tenant,
+		))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+	return stored, err
+}
+
+func (p *PostgreSQL) ListDeliveries(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error) {
+	return p.queryDeliveries(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = $1 AND tenant_id = $2 ORDER BY id DESC",
+		webhookID, p.tenant)
+}
+
+func (p *PostgreSQL) PendingDeliveries(ctx context.Context) ([]models.WebhookDelivery, error) {
+	return p.queryDeliveries(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE status = $1 AND tenant_id = $2 ORDER BY next_attempt_at, id",
+		models.DeliveryPending, p.tenant)
+}
+
+func (p *PostgreSQL) queryDeliveries(ctx context.Context, query string, args ...any) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := p.retry.Do(ctx, isTransient, func() error {
+		rows, err := p.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		deliveries = []models.WebhookDelivery{}
+		for rows.Next() {
+			d, err := scanDelivery(rows)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
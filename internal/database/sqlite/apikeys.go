@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// apiKeySchema mirrors migrations/000006_create_api_keys.up.sql. Scopes are
+// kept as a JSON array.
+const apiKeySchema = `CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    user TEXT NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    hash TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS api_keys_tenant_user ON api_keys (tenant_id, user, id)`
+
+const apiKeyColumns = "id, tenant_id, user, name, prefix, hash, scopes, created_at, expires_at, last_used_at, revoked_at"
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	err := row.Scan(&key.ID, &key.TenantID, &key.User, &key.Name, &key.Prefix, &key.Hash, &scopes,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// nullableTime stores nil as NULL and anything else in UTC.
+func nullableTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func (s *SQLite) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	return s.CreateAPIKeyAudited(ctx, key, nil)
+}
+
+func (s *SQLite) CreateAPIKeyAudited(ctx context.Context, key models.APIKey, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	err = audited(ctx, s, audit, func(ctx context.Context, q queryer) (*models.APIKey, *models.APIKey, error) {
+		key, err = scanAPIKey(q.QueryRowContext(ctx,
+			`INSERT INTO api_keys (tenant_id, user, name, prefix, hash, scopes, created_at, expires_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			 RETURNING `+apiKeyColumns,
+			s.tenant, key.User, key.Name, key.Prefix, key.Hash, string(scopes), now(), nullableTime(key.ExpiresAt),
+		))
+		return nil, &key, err
+	})
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (s *SQLite) GetAPIKey(ctx context.Context, id int) (models.APIKey, error) {
+	return s.getAPIKey(ctx, "id = ?", id)
+}
+
+func (s *SQLite) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	return s.getAPIKey(ctx, "prefix = ?", prefix)
+}
+
+func (s *SQLite) getAPIKey(ctx context.Context, cond string, arg any) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	key, err := scanAPIKey(s.conn.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE "+cond+" AND tenant_id = ?", arg, s.tenant))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	return key, err
+}
+
+func (s *SQLite) ListAPIKeys(ctx context.Context, user string) ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := s.conn.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE tenant_id = ? AND (? = '' OR user = ?) ORDER BY id", s.tenant, user, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLite) RevokeAPIKey(ctx context.Context, id int, at time.Time) (models.APIKey, error) {
+	return s.RevokeAPIKeyAudited(ctx, id, at, nil)
+}
+
+func (s *SQLite) RevokeAPIKeyAudited(ctx context.Context, id int, at time.Time, audit database.AuditFunc[models.APIKey]) (models.APIKey, error) {
+	var key models.APIKey
+	err := audited(ctx, s, audit, func(ctx context.Context, q queryer) (*models.APIKey, *models.APIKey, error) {
+		var before *models.APIKey
+		if audit != nil {
+			existing, err := scanAPIKey(q.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ? AND tenant_id = ?", id, s.tenant))
+			if err != nil {
+				return nil, nil, err
+			}
+			if existing.RevokedAt != nil {
+				key = existing
+				return nil, nil, nil
+			}
+			before = &existing
+		}
+
+		var err error
+		key, err = scanAPIKey(q.QueryRowContext(ctx,
+			`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?)
+			 WHERE id = ? AND tenant_id = ?
+			 RETURNING `+apiKeyColumns,
+			orNow(at, now()), id, s.tenant,
+		))
+		return before, &key, err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, database.ErrNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (s *SQLite) TouchAPIKey(ctx context.Context, id int, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	res, err := s.conn.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ? AND tenant_id = ?", orNow(at, now()), id, s.tenant)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// auditSchema mirrors migrations/000007_create_audit_log.up.sql. Times are
+// kept as fixed-width UTC text so they compare in order, and triggers
+// refuse changes to entries as the PostgreSQL rules do.
+const auditSchema = `CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    at TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    before TEXT,
+    after TEXT,
+    request_id TEXT NOT NULL,
+    client_ip TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_tenant_at ON audit_log (tenant_id, at);
+CREATE INDEX IF NOT EXISTS audit_log_tenant_actor ON audit_log (tenant_id, actor, at);
+CREATE INDEX IF NOT EXISTS audit_log_tenant_entity ON audit_log (tenant_id, entity, entity_id, at);
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`
+
+const auditColumns = "id, tenant_id, at, actor, action, entity, entity_id, before, after, request_id, client_ip"
+
+// auditTime formats times with a fixed number of digits.
+const auditTime = "2006-01-02T15:04:05.000000Z"
+
+func scanAudit(row scanner) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var at string
+	var before, after sql.NullString
+	err := row.Scan(&e.ID, &e.TenantID, &at, &e.Actor, &e.Action, &e.Entity, &e.EntityID, &before, &after, &e.RequestID, &e.ClientIP)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	if e.Time, err = time.Parse(auditTime, at); err != nil {
+		return models.AuditEntry{}, err
+	}
+	if before.Valid {
+		e.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		e.After = json.RawMessage(after.String)
+	}
+	return e, nil
+}
+
+// nullableJSON stores an empty snapshot as NULL.
+func nullableJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+func (s *SQLite) AppendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return s.appendAudit(ctx, s.conn, entry)
+}
+
+func (s *SQLite) appendAudit(ctx context.Context, q queryer, entry models.AuditEntry) (models.AuditEntry, error) {
+	return scanAudit(q.QueryRowContext(ctx,
+		`INSERT INTO audit_log (tenant_id, at, actor, action, entity, entity_id, before, after, request_id, client_ip)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 RETURNING `+auditColumns,
+		s.tenant, orNow(entry.Time, now()).Format(auditTime), entry.Actor, entry.Action, entry.Entity, entry.EntityID,
+		nullableJSON(entry.Before), nullableJSON(entry.After), entry.RequestID, entry.ClientIP,
+	))
+}
+
+// queryer is what a write needs from either the connection or a
+// transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// audited runs write on the connection or, when audit is set, in a
+// transaction that also appends the entry audit describes from the entity
+// before and after write. A write that changed nothing returns neither and
+// appends no entry.
+func audited[T any](ctx context.Context, s *SQLite, audit database.AuditFunc[T], write func(ctx context.Context, q queryer) (before, after *T, err error)) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if audit == nil {
+		_, _, err := write(ctx, s.conn)
+		return err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, after, err := write(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if before != nil || after != nil {
+		entry, err := audit(before, after)
+		if err != nil {
+			return err
+		}
+		if _, err := s.appendAudit(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ListAudit(ctx context.Context, filter database.AuditFilter) ([]models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	conds := []string{"tenant_id = ?"}
+	args := []any{s.tenant}
+	for _, c := range []struct {
+		cond  string
+		value string
+	}{
+		{"actor = ?", filter.Actor},
+		{"entity = ?", filter.Entity},
+		{"entity_id = ?", filter.EntityID},
+	} {
+		if c.value != "" {
+			conds = append(conds, c.cond)
+			args = append(args, c.value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conds = append(conds, "at >= ?")
+		args = append(args, filter.Since.UTC().Format(auditTime))
+	}
+	if !filter.Until.IsZero() {
+		conds = append(conds, "at < ?")
+		args = append(args, filter.Until.UTC().Format(auditTime))
+	}
+	query := "SELECT " + auditColumns + " FROM audit_log WHERE " + strings.Join(conds, " AND ") + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		e, err := scanAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
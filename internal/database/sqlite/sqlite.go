@@ -0,0 +1,399 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// queryTimeout bounds every statement, matching the MongoDB backend.
+const queryTimeout = 5 * time.Second
+
+// busyTimeout is how long a writer waits on a locked database before
+// SQLITE_BUSY is returned.
+const busyTimeout = 5 * time.Second
+
+// schema mirrors migrations/000001_create_posts_table.up.sql in SQLite's
+// dialect.
+const schema = `CREATE TABLE IF NOT EXISTS posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+// SQLite struct
+type SQLite struct {
+	conn   *sql.DB
+	tenant string
+}
+
+// NewSQLite opens (or creates) the database file at path in WAL mode and
+// makes sure the posts, webhook, API key and audit tables exist. An empty
+// path uses blog.db in the working directory.
+func NewSQLite(path string) (database.DB, error) {
+	if path == "" {
+		path = "blog.db"
+	}
+
+	conn, err := sql.Open("sqlite", dsn(path))
+	if err != nil {
+		log.Println("SQLite open failed:", err)
+		return nil, database.ErrFailedConnection
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	if err := conn.PingContext(ctx); err != nil {
+		log.Println("SQLite ping failed:", err)
+		conn.Close()
+		return nil, database.ErrFailedConnection
+	}
+
+	for _, stmt := range []string{schema, webhookSchema, apiKeySchema, auditSchema} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			log.Println("SQLite schema creation failed:", err)
+			conn.Close()
+			return nil, database.ErrFailedConnection
+		}
+	}
+	if err := addTenants(ctx, conn); err != nil {
+		log.Println("SQLite schema creation failed:", err)
+		conn.Close()
+		return nil, database.ErrFailedConnection
+	}
+
+	log.Println("Connected to SQLite")
+	return &SQLite{conn: conn, tenant: database.DefaultTenant}, nil
+}
+
+// addTenants mirrors migrations/000005_add_tenants.up.sql. SQLite has no
+// ADD COLUMN IF NOT EXISTS, so existing columns are looked up first.
+func addTenants(ctx context.Context, conn *sql.DB) error {
+	for _, table := range []string{"posts", "webhooks", "webhook_deliveries"} {
+		var n int
+		if err := conn.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'tenant_id'", table).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx,
+			"ALTER TABLE "+table+" ADD COLUMN tenant_id TEXT NOT NULL DEFAULT '"+database.DefaultTenant+"'"); err != nil {
+			return err
+		}
+	}
+	_, err := conn.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS posts_tenant ON posts (tenant_id, id);
+CREATE INDEX IF NOT EXISTS webhooks_tenant ON webhooks (tenant_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_tenant_pending ON webhook_deliveries (tenant_id, status, next_attempt_at)`)
+	return err
+}
+
+func (s *SQLite) ForTenant(tenantID string) database.DB {
+	return &SQLite{conn: s.conn, tenant: tenantID}
+}
+
+// dsn builds a modernc.org/sqlite connection string. The pragmas are applied
+// to every connection in the pool.
+func dsn(path string) string {
+	q := url.Values{}
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	q.Add("_pragma", "foreign_keys(ON)")
+	q.Set("_txlock", "immediate")
+	return "file:" + path + "?" + q.Encode()
+}
+
+func (s *SQLite) GetPosts() ([]models.Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE tenant_id = ? ORDER BY id", s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var p models.Post
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(posts) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	return posts, nil
+}
+
+func (s *SQLite) GetPostByID(id string) (models.Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var post models.Post
+	err := s.conn.QueryRowContext(ctx, "SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE id = ? AND tenant_id = ?", id, s.tenant).
+		Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Post{}, database.ErrNotFound
+		}
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+func (s *SQLite) CreatePost(post models.Post) (models.Post, error) {
+	return s.CreatePostAudited(context.Background(), post, nil)
+}
+
+func (s *SQLite) CreatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	err := audited(ctx, s, audit, func(ctx context.Context, q queryer) (*models.Post, *models.Post, error) {
+		now := now()
+		err := q.QueryRowContext(ctx,
+			`INSERT INTO posts (title, body, created_at, updated_at, tenant_id)
+			 VALUES (?, ?, ?, ?, ?)
+			 RETURNING id, tenant_id, title, body, created_at, updated_at`,
+			post.Title, post.Body, now, now, s.tenant,
+		).Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+		return nil, &post, err
+	})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+func (s *SQLite) UpdatePost(post models.Post) (models.Post, error) {
+	return s.UpdatePostAudited(context.Background(), post, nil)
+}
+
+func (s *SQLite) UpdatePostAudited(ctx context.Context, post models.Post, audit database.AuditFunc[models.Post]) (models.Post, error) {
+	err := audited(ctx, s, audit, func(ctx context.Context, q queryer) (*models.Post, *models.Post, error) {
+		var before *models.Post
+		if audit != nil {
+			existing, err := getPost(ctx, q, s.tenant, post.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			before = &existing
+		}
+		err := q.QueryRowContext(ctx,
+			`UPDATE posts SET title = ?, body = ?, updated_at = ?
+			 WHERE id = ? AND tenant_id = ?
+			 RETURNING id, tenant_id, title, body, created_at, updated_at`,
+			post.Title, post.Body, now(), post.ID, s.tenant,
+		).Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+		return before, &post, err
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Post{}, database.ErrNotFound
+		}
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+// getPost reads a post of tenant for a write; a missing one is
+// sql.ErrNoRows.
+func getPost(ctx context.Context, q queryer, tenant string, id int) (models.Post, error) {
+	var post models.Post
+	err := q.QueryRowContext(ctx, "SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE id = ? AND tenant_id = ?", id, tenant).
+		Scan(&post.ID, &post.TenantID, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt)
+	return post, err
+}
+
+func (s *SQLite) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	posts := []models.Post{}
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	args := []any{s.tenant}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+	rows, err := s.conn.QueryContext(ctx,
+		"SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE tenant_id = ? AND id IN ("+placeholders+") ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Post
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+func (s *SQLite) ListPosts(ctx context.Context, after, limit int) ([]models.Post, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := s.conn.QueryContext(ctx,
+		"SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE tenant_id = ? AND id > ? ORDER BY id LIMIT ?", s.tenant, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	for rows.Next() {
+		var p models.Post
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+func (s *SQLite) CountPosts(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var n int
+	err := s.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM posts WHERE tenant_id = ?", s.tenant).Scan(&n)
+	return n, err
+}
+
+func (s *SQLite) EachPost(ctx context.Context, fn func(models.Post) error) error {
+	rows, err := s.conn.QueryContext(ctx, "SELECT id, tenant_id, title, body, created_at, updated_at FROM posts WHERE tenant_id = ? ORDER BY id", s.tenant)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Post
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLite) ImportPosts(ctx context.Context, posts []models.Post, mode database.ImportMode) ([]models.Post, error) {
+	return s.ImportPostsAudited(ctx, posts, mode, nil)
+}
+
+func (s *SQLite) ImportPostsAudited(ctx context.Context, posts []models.Post, mode database.ImportMode, audit database.AuditFunc[models.Post]) ([]models.Post, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO posts (title, body, created_at, updated_at, tenant_id)
+		 VALUES (?, ?, ?, ?, ?)
+		 RETURNING id, tenant_id, title, body, created_at, updated_at`
+	if mode == database.ImportUpsert {
+		// AUTOINCREMENT keeps sqlite_sequence at the highest ID inserted, so
+		// new posts continue after the imported ones. A post of another
+		// tenant is left alone and returns no row.
+		query = `INSERT INTO posts (title, body, created_at, updated_at, tenant_id, id)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (id) DO UPDATE SET title = excluded.title, body = excluded.body,
+		     created_at = CASE WHEN ? THEN posts.created_at ELSE excluded.created_at END,
+		     updated_at = excluded.updated_at
+		 WHERE posts.tenant_id = excluded.tenant_id
+		 RETURNING id, tenant_id, title, body, created_at, updated_at`
+	}
+
+	now := now()
+	stored := make([]models.Post, 0, len(posts))
+	for _, p := range posts {
+		args := []any{p.Title, p.Body, orNow(p.CreatedAt, now), orNow(p.UpdatedAt, now), s.tenant}
+		var before *models.Post
+		if mode == database.ImportUpsert {
+			// A replaced post without a created_at keeps its own.
+			args = append(args, p.ID, p.CreatedAt.IsZero())
+			if audit != nil {
+				existing, err := getPost(ctx, tx, s.tenant, p.ID)
+				if err == nil {
+					before = &existing
+				} else if !errors.Is(err, sql.ErrNoRows) {
+					return nil, err
+				}
+			}
+		}
+		err := tx.QueryRowContext(ctx, query, args...).
+			Scan(&p.ID, &p.TenantID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, database.ErrTenantMismatch
+		}
+		if err != nil {
+			return nil, err
+		}
+		if audit != nil {
+			entry, err := audit(before, &p)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := s.appendAudit(ctx, tx, entry); err != nil {
+				return nil, err
+			}
+		}
+		stored = append(stored, p)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// PoolStats reports the database/sql connection pool.
+func (s *SQLite) PoolStats() database.PoolStats {
+	return database.SQLPoolStats(s.conn.Stats())
+}
+
+func (s *SQLite) Close() error {
+	return s.conn.Close()
+}
+
+func orNow(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t.UTC()
+}
+
+// now matches PostgreSQL's NOW() on a TIMESTAMP column: UTC with
+// microsecond precision.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,70 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/dbtest"
+	"olbcloud.com/webapi/internal/database/sqlite"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func TestSQLite(t *testing.T) {
+	db, err := sqlite.NewSQLite(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	dbtest.Run(t, db)
+	dbtest.RunWebhooks(t, db.(database.WebhookStore))
+	dbtest.RunAPIKeys(t, db, db.(database.APIKeyStore))
+	dbtest.RunAudit(t, db, db.(database.AuditLog))
+	dbtest.RunTenants(t, db)
+	dbtest.RunAuditedWrites(t, db)
+}
+
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blog.db")
+	db, err := sqlite.NewSQLite(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := db.CreatePost(models.Post{Title: "t", Body: "b"})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	posts, err := db.GetPosts()
+	require.NoError(t, err)
+	assert.Len(t, posts, 20)
+}
+
+func TestSQLiteAuditIsAppendOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blog.db")
+	db, err := sqlite.NewSQLite(path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.(database.AuditLog).AppendAudit(context.Background(), models.AuditEntry{
+		Actor: "alice", Action: models.AuditCreate, Entity: "post", EntityID: "1",
+	})
+	require.NoError(t, err)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Exec("UPDATE audit_log SET actor = 'mallory'")
+	assert.ErrorContains(t, err, "append-only")
+	_, err = conn.Exec("DELETE FROM audit_log")
+	assert.ErrorContains(t, err, "append-only")
+}
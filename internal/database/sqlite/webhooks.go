@@ -0,0 +1,232 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// webhookSchema mirrors migrations/000003_create_webhooks.up.sql. Events are
+// kept as a JSON array.
+const webhookSchema = `CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    events TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending ON webhook_deliveries (status, next_attempt_at)`
+
+const webhookColumns = "id, tenant_id, url, events, secret, created_at"
+
+const deliveryColumns = `id, tenant_id, webhook_id, event, payload, status, attempts, response_status, last_error,
+	next_attempt_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row scanner) (models.Webhook, error) {
+	var hook models.Webhook
+	var events string
+	if err := row.Scan(&hook.ID, &hook.TenantID, &hook.URL, &events, &hook.Secret, &hook.CreatedAt); err != nil {
+		return models.Webhook{}, err
+	}
+	if err := json.Unmarshal([]byte(events), &hook.Events); err != nil {
+		return models.Webhook{}, err
+	}
+	return hook, nil
+}
+
+func scanDelivery(row scanner) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	err := row.Scan(&d.ID, &d.TenantID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError,
+		&d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+func (s *SQLite) CreateWebhook(ctx context.Context, hook models.Webhook) (models.Webhook, error) {
+	return s.CreateWebhookAudited(ctx, hook, nil)
+}
+
+func (s *SQLite) CreateWebhookAudited(ctx context.Context, hook models.Webhook, audit database.AuditFunc[models.Webhook]) (models.Webhook, error) {
+	events, err := json.Marshal(hook.Events)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	err = audited(ctx, s, audit, func(ctx context.Context, q queryer) (*models.Webhook, *models.Webhook, error) {
+		hook, err = scanWebhook(q.QueryRowContext(ctx,
+			`INSERT INTO webhooks (url, events, secret, created_at, tenant_id) VALUES (?, ?, ?, ?, ?)
+			 RETURNING `+webhookColumns,
+			hook.URL, string(events), hook.Secret, now(), s.tenant,
+		))
+		return nil, &hook, err
+	})
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	return hook, nil
+}
+
+func (s *SQLite) GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	hook, err := scanWebhook(s.conn.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ? AND tenant_id = ?", id, s.tenant))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Webhook{}, database.ErrNotFound
+	}
+	return hook, err
+}
+
+func (s *SQLite) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE tenant_id = ? ORDER BY id", s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook relies on ON DELETE CASCADE, which the foreign_keys pragma
+// enables, to remove the deliveries.
+func (s *SQLite) DeleteWebhook(ctx context.Context, id int) error {
+	return s.DeleteWebhookAudited(ctx, id, nil)
+}
+
+func (s *SQLite) DeleteWebhookAudited(ctx context.Context, id int, audit database.AuditFunc[models.Webhook]) error {
+	return audited(ctx, s, audit, func(ctx context.Context, q queryer) (*models.Webhook, *models.Webhook, error) {
+		var before *models.Webhook
+		if audit != nil {
+			hook, err := scanWebhook(q.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ? AND tenant_id = ?", id, s.tenant))
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil, database.ErrNotFound
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			before = &hook
+		}
+
+		res, err := q.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ? AND tenant_id = ?", id, s.tenant)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, nil, err
+		} else if n == 0 {
+			return nil, nil, database.ErrNotFound
+		}
+		return before, nil, nil
+	})
+}
+
+func (s *SQLite) CreateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	now := now()
+	return scanDelivery(s.conn.QueryRowContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, response_status, last_error,
+		     next_attempt_at, created_at, updated_at, tenant_id)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 RETURNING `+deliveryColumns,
+		d.WebhookID, d.Event, string(d.Payload), d.Status, d.Attempts, d.ResponseStatus, d.LastError,
+		orNow(d.NextAttemptAt, now), now, now, s.tenant,
+	))
+}
+
+func (s *SQLite) GetDelivery(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	d, err := scanDelivery(s.conn.QueryRowContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ? AND tenant_id = ?", id, s.tenant))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+	return d, err
+}
+
+func (s *SQLite) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	now := now()
+	d, err := scanDelivery(s.conn.QueryRowContext(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		 WHERE id = ? AND tenant_id = ?
+		 RETURNING `+deliveryColumns,
+		d.Status, d.Attempts, d.ResponseStatus, d.LastError, orNow(d.NextAttemptAt, now), now, d.ID, s.tenant,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WebhookDelivery{}, database.ErrNotFound
+	}
+	return d, err
+}
+
+func (s *SQLite) ListDeliveries(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? AND tenant_id = ? ORDER BY id DESC",
+		webhookID, s.tenant)
+}
+
+func (s *SQLite) PendingDeliveries(ctx context.Context) ([]models.WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE status = ? AND tenant_id = ? ORDER BY next_attempt_at, id",
+		models.DeliveryPending, s.tenant)
+}
+
+func (s *SQLite) queryDeliveries(ctx context.Context, query string, args ...any) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,233 @@
+// Package datamigrate copies data between two database.DB backends, for
+// example when switching DB_TYPE from postgresql to mongodb.
+package datamigrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+var ErrVerificationFailed = errors.New("source and target differ after copy")
+
+// Options controls a Run.
+type Options struct {
+	// BatchSize is the number of records written per ImportPosts call.
+	BatchSize int
+	// CheckpointPath, when set, records progress after every batch so an
+	// interrupted run resumes where it stopped. It is removed once the copy
+	// has been verified.
+	CheckpointPath string
+}
+
+// Checkpoint is the progress saved between batches, per entity.
+type Checkpoint map[string]EntityCheckpoint
+
+type EntityCheckpoint struct {
+	LastID int  `json:"last_id"`
+	Copied int  `json:"copied"`
+	Done   bool `json:"done"`
+}
+
+// Summary fingerprints the records of one entity in one database.
+type Summary struct {
+	Count    int    `json:"count"`
+	Checksum string `json:"checksum"`
+}
+
+// EntityReport compares source and target after the copy.
+type EntityReport struct {
+	Entity string  `json:"entity"`
+	Copied int     `json:"copied"`
+	Source Summary `json:"source"`
+	Target Summary `json:"target"`
+}
+
+func (r EntityReport) Matches() bool {
+	return r.Source == r.Target
+}
+
+// entity knows how to copy and fingerprint one kind of record. Posts are
+// the only entity today; comments, tags and users get an entry here once
+// database.DB can read and write them.
+type entity struct {
+	name      string
+	copy      func(ctx context.Context, from, to database.DB, batchSize int, cp *EntityCheckpoint, save func() error) error
+	summarize func(ctx context.Context, db database.DB) (Summary, error)
+}
+
+var entities = []entity{
+	{name: "posts", copy: copyPosts, summarize: summarizePosts},
+}
+
+// Run copies every entity from one database to the other, preserving IDs
+// and timestamps, then verifies counts and checksums on both sides. Copies
+// are upserts, so re-running after a failure is safe even without a
+// checkpoint.
+func Run(ctx context.Context, from, to database.DB, opts Options) ([]EntityReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	cp, err := loadCheckpoint(opts.CheckpointPath)
+	if err != nil {
+		return nil, fmt.Errorf("load checkpoint: %w", err)
+	}
+	save := func() error { return saveCheckpoint(opts.CheckpointPath, cp) }
+
+	var reports []EntityReport
+	for _, e := range entities {
+		state := cp[e.name]
+		if state.Done {
+			log.Printf("%s: already copied according to checkpoint", e.name)
+		} else {
+			if state.LastID > 0 {
+				log.Printf("%s: resuming after ID %d (%d copied)", e.name, state.LastID, state.Copied)
+			}
+			err := e.copy(ctx, from, to, opts.BatchSize, &state, func() error {
+				cp[e.name] = state
+				return save()
+			})
+			if err != nil {
+				return reports, fmt.Errorf("copy %s: %w", e.name, err)
+			}
+			state.Done = true
+			cp[e.name] = state
+			if err := save(); err != nil {
+				return reports, err
+			}
+		}
+
+		report := EntityReport{Entity: e.name, Copied: state.Copied}
+		if report.Source, err = e.summarize(ctx, from); err != nil {
+			return reports, fmt.Errorf("verify %s: %w", e.name, err)
+		}
+		if report.Target, err = e.summarize(ctx, to); err != nil {
+			return reports, fmt.Errorf("verify %s: %w", e.name, err)
+		}
+		reports = append(reports, report)
+	}
+
+	for _, r := range reports {
+		if !r.Matches() {
+			return reports, ErrVerificationFailed
+		}
+	}
+
+	if opts.CheckpointPath != "" {
+		if err := os.Remove(opts.CheckpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return reports, err
+		}
+	}
+	return reports, nil
+}
+
+// copyPosts streams posts from the source in ID order and writes them in
+// batches, skipping those at or below the checkpoint.
+func copyPosts(ctx context.Context, from, to database.DB, batchSize int, cp *EntityCheckpoint, save func() error) error {
+	batch := make([]models.Post, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := to.ImportPosts(ctx, batch, database.ImportUpsert); err != nil {
+			return err
+		}
+		cp.LastID = batch[len(batch)-1].ID
+		cp.Copied += len(batch)
+		batch = batch[:0]
+		return save()
+	}
+
+	err := from.EachPost(ctx, func(p models.Post) error {
+		if p.ID <= cp.LastID {
+			return nil
+		}
+		batch = append(batch, p)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// summarizePosts hashes every post in ID order. Timestamps are hashed at
+// millisecond precision, the finest MongoDB stores.
+func summarizePosts(ctx context.Context, db database.DB) (Summary, error) {
+	h := sha256.New()
+	count := 0
+	err := db.EachPost(ctx, func(p models.Post) error {
+		count++
+		writeFields(h,
+			strconv.Itoa(p.ID),
+			p.Title,
+			p.Body,
+			strconv.FormatInt(p.CreatedAt.UnixMilli(), 10),
+			strconv.FormatInt(p.UpdatedAt.UnixMilli(), 10),
+		)
+		return nil
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+	return Summary{Count: count, Checksum: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// writeFields length-prefixes each field so no two records hash alike by
+// shifting bytes between fields.
+func writeFields(h hash.Hash, fields ...string) {
+	for _, f := range fields {
+		fmt.Fprintf(h, "%d:%s", len(f), f)
+	}
+}
+
+func loadCheckpoint(path string) (Checkpoint, error) {
+	cp := Checkpoint{}
+	if path == "" {
+		return cp, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cp, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func saveCheckpoint(path string, cp Checkpoint) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
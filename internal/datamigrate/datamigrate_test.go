@@ -0,0 +1,108 @@
+package datamigrate_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/datamigrate"
+	"olbcloud.com/webapi/internal/models"
+)
+
+var errInjected = errors.New("injected failure")
+
+// flakyDB fails ImportPosts after a number of successful calls.
+type flakyDB struct {
+	database.DB
+	remaining int
+}
+
+func (f *flakyDB) ImportPosts(ctx context.Context, posts []models.Post, mode database.ImportMode) ([]models.Post, error) {
+	if f.remaining == 0 {
+		return nil, errInjected
+	}
+	f.remaining--
+	return f.DB.ImportPosts(ctx, posts, mode)
+}
+
+func seed(t *testing.T, n int) database.DB {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	posts := make([]models.Post, 0, n)
+	for i := 1; i <= n; i++ {
+		// Leave gaps in the IDs so they cannot be reassigned by accident.
+		posts = append(posts, models.Post{
+			ID: i * 3, Title: "title", Body: "body",
+			CreatedAt: created, UpdatedAt: created.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	_, err = db.ImportPosts(context.Background(), posts, database.ImportUpsert)
+	require.NoError(t, err)
+	return db
+}
+
+func TestRun(t *testing.T) {
+	from := seed(t, 7)
+	to, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	reports, err := datamigrate.Run(context.Background(), from, to, datamigrate.Options{BatchSize: 3})
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "posts", reports[0].Entity)
+	assert.Equal(t, 7, reports[0].Copied)
+	assert.Equal(t, 7, reports[0].Target.Count)
+	assert.True(t, reports[0].Matches())
+
+	want, _ := from.GetPosts()
+	got, _ := to.GetPosts()
+	assert.Equal(t, want, got)
+
+	// The target continues numbering after the copied IDs.
+	created, err := to.CreatePost(models.Post{Title: "t", Body: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, 22, created.ID)
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	from := seed(t, 7)
+	target, err := memory.NewMemory("")
+	require.NoError(t, err)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	opts := datamigrate.Options{BatchSize: 3, CheckpointPath: checkpoint}
+
+	_, err = datamigrate.Run(context.Background(), from, &flakyDB{DB: target, remaining: 1}, opts)
+	require.ErrorIs(t, err, errInjected)
+	assert.FileExists(t, checkpoint)
+
+	// Only the batches after the checkpoint are written on the second run.
+	resumed := &flakyDB{DB: target, remaining: 2}
+	reports, err := datamigrate.Run(context.Background(), from, resumed, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 0, resumed.remaining)
+	assert.Equal(t, 7, reports[0].Copied)
+	assert.True(t, reports[0].Matches())
+	assert.NoFileExists(t, checkpoint)
+}
+
+func TestRunDetectsMismatch(t *testing.T) {
+	from := seed(t, 2)
+	to, err := memory.NewMemory("")
+	require.NoError(t, err)
+	_, err = to.CreatePost(models.Post{Title: "already here", Body: "b"})
+	require.NoError(t, err)
+
+	reports, err := datamigrate.Run(context.Background(), from, to, datamigrate.Options{})
+	require.ErrorIs(t, err, datamigrate.ErrVerificationFailed)
+	assert.Equal(t, 2, reports[0].Source.Count)
+	assert.Equal(t, 3, reports[0].Target.Count)
+}
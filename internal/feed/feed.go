@@ -0,0 +1,232 @@
+// Package feed renders posts as RSS 2.0 and Atom 1.0 documents.
+package feed
+
+import (
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"olbcloud.com/webapi/internal/models"
+)
+
+// Options describes the site a feed belongs to.
+type Options struct {
+	Title       string
+	Description string
+	// BaseURL is the public address of the site, without a trailing slash.
+	// Item links are BaseURL + "/posts/{id}".
+	BaseURL string
+	// Items caps the number of posts, newest first.
+	Items int
+	// FullContent includes whole post bodies; otherwise items carry only a
+	// summary of SummaryLength characters.
+	FullContent   bool
+	SummaryLength int
+}
+
+// Feed is a rendered feed ready to be written.
+type Feed struct {
+	Body    []byte
+	Updated time.Time
+}
+
+// RSS renders posts as an RSS 2.0 channel. selfURL is the feed's own address.
+func RSS(opts Options, posts []models.Post, selfURL string) (Feed, error) {
+	posts, updated := latest(posts, opts.Items)
+
+	ch := rssChannel{
+		Title:         opts.Title,
+		Link:          opts.BaseURL + "/",
+		Description:   opts.Description,
+		LastBuildDate: rssTime(updated),
+		Self:          atomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+	}
+	for _, p := range posts {
+		link := postURL(opts, p)
+		item := rssItem{
+			Title:       p.Title,
+			Link:        link,
+			GUID:        rssGUID{Value: link, IsPermaLink: true},
+			PubDate:     rssTime(p.CreatedAt),
+			Description: summarize(p.Body, opts.SummaryLength),
+		}
+		if opts.FullContent {
+			item.Content = p.Body
+		}
+		ch.Items = append(ch.Items, item)
+	}
+
+	return render(rssDocument{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		Channel:   ch,
+	}, updated)
+}
+
+// Atom renders posts as an Atom 1.0 feed. selfURL is the feed's own address
+// and also serves as its ID.
+func Atom(opts Options, posts []models.Post, selfURL string) (Feed, error) {
+	posts, updated := latest(posts, opts.Items)
+
+	doc := atomFeed{
+		NS:       "http://www.w3.org/2005/Atom",
+		Title:    opts.Title,
+		Subtitle: opts.Description,
+		ID:       selfURL,
+		Updated:  atomTime(updated),
+		Links: []atomLink{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: opts.BaseURL + "/", Rel: "alternate"},
+		},
+	}
+	for _, p := range posts {
+		link := postURL(opts, p)
+		entry := atomEntry{
+			Title:     p.Title,
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate"},
+			Published: atomTime(p.CreatedAt),
+			Updated:   atomTime(p.UpdatedAt),
+		}
+		if opts.FullContent {
+			entry.Content = &atomText{Type: "text", Value: p.Body}
+		} else {
+			entry.Summary = &atomText{Type: "text", Value: summarize(p.Body, opts.SummaryLength)}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return render(doc, updated)
+}
+
+func render(doc any, updated time.Time) (Feed, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return Feed{}, err
+	}
+	return Feed{Body: append([]byte(xml.Header), body...), Updated: updated}, nil
+}
+
+// latest returns up to n posts, newest first, and the most recent update
+// time among them.
+func latest(posts []models.Post, n int) ([]models.Post, time.Time) {
+	sorted := make([]models.Post, len(posts))
+	copy(sorted, posts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+		}
+		return sorted[i].ID > sorted[j].ID
+	})
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	var updated time.Time
+	for _, p := range sorted {
+		if p.UpdatedAt.After(updated) {
+			updated = p.UpdatedAt
+		}
+	}
+	return sorted, updated
+}
+
+func postURL(opts Options, p models.Post) string {
+	return opts.BaseURL + "/posts/" + strconv.Itoa(p.ID)
+}
+
+// summarize cuts body to at most n characters at a word boundary.
+func summarize(body string, n int) string {
+	body = strings.TrimSpace(body)
+	if n <= 0 || utf8.RuneCountInString(body) <= n {
+		return body
+	}
+
+	runes := []rune(body)[:n]
+	cut := string(runes)
+	if i := strings.LastIndexAny(cut, " \n\t"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n\t.,;:") + "…"
+}
+
+func rssTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	Description string  `xml:"description"`
+	Content     string  `xml:"content:encoded,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	NS       string      `xml:"xmlns,attr"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string    `xml:"title"`
+	ID        string    `xml:"id"`
+	Link      atomLink  `xml:"link"`
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+	Summary   *atomText `xml:"summary,omitempty"`
+	Content   *atomText `xml:"content,omitempty"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
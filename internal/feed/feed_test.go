@@ -0,0 +1,74 @@
+package feed_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/feed"
+	"olbcloud.com/webapi/internal/models"
+)
+
+var (
+	opts = feed.Options{Title: "Blog", BaseURL: "https://blog.example", Items: 2, SummaryLength: 12}
+	base = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	posts = []models.Post{
+		{ID: 1, Title: "Oldest", Body: "first post body", CreatedAt: base, UpdatedAt: base.Add(72 * time.Hour)},
+		{ID: 2, Title: "Middle", Body: "second post body", CreatedAt: base.Add(time.Hour), UpdatedAt: base.Add(time.Hour)},
+		{ID: 3, Title: "Newest", Body: "third post body", CreatedAt: base.Add(2 * time.Hour), UpdatedAt: base.Add(2 * time.Hour)},
+	}
+)
+
+func TestRSS(t *testing.T) {
+	f, err := feed.RSS(opts, posts, "https://blog.example/feed.rss")
+	require.NoError(t, err)
+	assert.Equal(t, base.Add(2*time.Hour), f.Updated)
+
+	var doc struct {
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		} `xml:"channel>item"`
+	}
+	require.NoError(t, xml.Unmarshal(f.Body, &doc))
+	require.Len(t, doc.Items, 2)
+	assert.Equal(t, "Newest", doc.Items[0].Title)
+	assert.Equal(t, "Middle", doc.Items[1].Title)
+	assert.Equal(t, "https://blog.example/posts/3", doc.Items[0].Link)
+	assert.Equal(t, "third post…", doc.Items[0].Description)
+	assert.Empty(t, doc.Items[0].Content)
+}
+
+func TestAtomFullContent(t *testing.T) {
+	full := opts
+	full.FullContent = true
+	f, err := feed.Atom(full, posts, "https://blog.example/feed.atom")
+	require.NoError(t, err)
+
+	var doc struct {
+		ID      string `xml:"id"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Summary string `xml:"summary"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(f.Body, &doc))
+	assert.Equal(t, "https://blog.example/feed.atom", doc.ID)
+	require.Len(t, doc.Entries, 2)
+	assert.Equal(t, "third post body", doc.Entries[0].Content)
+	assert.Empty(t, doc.Entries[0].Summary)
+}
+
+func TestEmptyFeed(t *testing.T) {
+	f, err := feed.Atom(opts, nil, "https://blog.example/feed.atom")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(f.Body), "<?xml"))
+	assert.True(t, f.Updated.IsZero())
+}
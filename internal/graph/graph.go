@@ -0,0 +1,48 @@
+// Package graph serves posts over GraphQL, backed by services.PostService.
+package graph
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// Request is the JSON body of a GraphQL POST. Extensions are accepted so
+// clients that send them are not rejected, but are not used.
+type Request struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// Execute parses, validates and checks req against limits before running
+// it. Problems with the query are reported in the result's errors.
+func Execute(ctx context.Context, ps services.PostService, limits Limits, req Request) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if v := graphql.ValidateDocument(&schema, doc, nil); !v.IsValid {
+		return &graphql.Result{Errors: v.Errors}
+	}
+	if err := limits.check(doc, req.OperationName, req.Variables); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		Root:          &root{posts: ps, loader: newPostLoader(ctx, ps)},
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+}
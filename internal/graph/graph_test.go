@@ -0,0 +1,190 @@
+package graph_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/database/mocks"
+	"olbcloud.com/webapi/internal/graph"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+func newService(t *testing.T, n int) services.PostService {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	for i := 0; i < n; i++ {
+		_, err := db.CreatePost(models.Post{Title: "title", Body: "body"})
+		require.NoError(t, err)
+	}
+	return services.NewPostService(db)
+}
+
+// run executes query and decodes its data into dst.
+func run(t *testing.T, ps services.PostService, limits graph.Limits, query string, variables map[string]interface{}, dst interface{}) *graphql.Result {
+	t.Helper()
+	result := graph.Execute(context.Background(), ps, limits, graph.Request{Query: query, Variables: variables})
+	if dst != nil {
+		require.Empty(t, result.Errors)
+		data, err := json.Marshal(result.Data)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, dst))
+	}
+	return result
+}
+
+func TestPostsConnection(t *testing.T) {
+	ps := newService(t, 5)
+	query := `query($after: String) {
+		posts(first: 2, after: $after) {
+			totalCount
+			edges { cursor node { id title } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`
+
+	type page struct {
+		Posts struct {
+			TotalCount int
+			Edges      []struct {
+				Cursor string
+				Node   struct{ ID string }
+			}
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   *string
+			}
+		}
+	}
+
+	var ids []string
+	var after interface{}
+	for {
+		var p page
+		run(t, ps, graph.Limits{}, query, map[string]interface{}{"after": after}, &p)
+		assert.Equal(t, 5, p.Posts.TotalCount)
+		for _, e := range p.Posts.Edges {
+			ids = append(ids, e.Node.ID)
+		}
+		if !p.Posts.PageInfo.HasNextPage {
+			break
+		}
+		after = *p.Posts.PageInfo.EndCursor
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, ids)
+
+	result := run(t, ps, graph.Limits{}, `{ posts(after: "nope") { totalCount } }`, nil, nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "invalid cursor", result.Errors[0].Message)
+}
+
+func TestPostsPageWithAKeysetQuery(t *testing.T) {
+	db := mocks.NewDB(t)
+	db.On("ListPosts", mock.Anything, 1, 3).
+		Return([]models.Post{{ID: 2}, {ID: 3}, {ID: 4}}, nil).Once()
+
+	var data struct {
+		Posts struct {
+			Edges []struct {
+				Node struct{ ID string }
+			}
+			PageInfo struct{ HasNextPage bool }
+		}
+	}
+	run(t, services.NewPostService(db), graph.Limits{}, `query($after: String) {
+		posts(first: 2, after: $after) { edges { node { id } } pageInfo { hasNextPage } }
+	}`, map[string]interface{}{"after": base64.StdEncoding.EncodeToString([]byte("post:1"))}, &data)
+
+	require.Len(t, data.Posts.Edges, 2)
+	assert.Equal(t, "3", data.Posts.Edges[1].Node.ID)
+	assert.True(t, data.Posts.PageInfo.HasNextPage)
+}
+
+func TestPostLookupsAreBatched(t *testing.T) {
+	db := mocks.NewDB(t)
+	db.On("GetPostsByIDs", mock.Anything, mock.MatchedBy(func(ids []int) bool {
+		return assert.ElementsMatch(t, []int{1, 2, 3}, ids)
+	})).Return([]models.Post{{ID: 1, Title: "one"}, {ID: 3, Title: "three"}}, nil).Once()
+
+	var data struct {
+		A *struct{ Title string }
+		B *struct{ Title string }
+		C *struct{ Title string }
+	}
+	run(t, services.NewPostService(db), graph.Limits{},
+		`{ a: post(id: "1") { title } b: post(id: "2") { title } c: post(id: "3") { title } }`, nil, &data)
+
+	require.NotNil(t, data.A)
+	assert.Equal(t, "one", data.A.Title)
+	assert.Nil(t, data.B)
+	require.NotNil(t, data.C)
+	assert.Equal(t, "three", data.C.Title)
+}
+
+func TestMutations(t *testing.T) {
+	ps := newService(t, 0)
+
+	var created struct {
+		CreatePost struct{ ID, Title string }
+	}
+	run(t, ps, graph.Limits{}, `mutation { createPost(input: {title: "Hello", body: "World"}) { id title } }`, nil, &created)
+	assert.Equal(t, "1", created.CreatePost.ID)
+
+	var updated struct {
+		UpdatePost struct{ Title string }
+	}
+	run(t, ps, graph.Limits{}, `mutation { updatePost(id: "1", input: {title: "Hi", body: "World"}) { title } }`, nil, &updated)
+	assert.Equal(t, "Hi", updated.UpdatePost.Title)
+
+	result := run(t, ps, graph.Limits{}, `mutation { createPost(input: {title: "", body: "x"}) { id } }`, nil, nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "missing required fields", result.Errors[0].Message)
+
+	result = run(t, ps, graph.Limits{}, `mutation { updatePost(id: "9", input: {title: "x", body: "y"}) { id } }`, nil, nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "post not found", result.Errors[0].Message)
+}
+
+func TestMutationsNeedWriteScope(t *testing.T) {
+	ps := newService(t, 1)
+	reader := auth.NewContext(context.Background(), auth.Principal{User: "bot", Scopes: []string{auth.ScopeRead}})
+
+	result := graph.Execute(reader, ps, graph.Limits{}, graph.Request{Query: `mutation { createPost(input: {title: "x", body: "y"}) { id } }`})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "missing scope posts:write", result.Errors[0].Message)
+
+	result = graph.Execute(reader, ps, graph.Limits{}, graph.Request{Query: `{ post(id: "1") { id } }`})
+	assert.Empty(t, result.Errors, "queries only need posts:read")
+}
+
+func TestLimits(t *testing.T) {
+	ps := newService(t, 1)
+
+	result := run(t, ps, graph.Limits{MaxDepth: 3}, `{ posts { edges { node { id } } } }`, nil, nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "query depth 4 exceeds the limit of 3", result.Errors[0].Message)
+
+	// Fragments count towards depth as if inlined.
+	result = run(t, ps, graph.Limits{MaxDepth: 3},
+		`{ posts { ...E } } fragment E on PostConnection { edges { node { id } } }`, nil, nil)
+	require.Len(t, result.Errors, 1)
+
+	// posts(first: 50) { edges { node { id title } } } costs 1 + 50*(1 + 1 + 2).
+	result = run(t, ps, graph.Limits{MaxComplexity: 200},
+		`query($n: Int) { posts(first: $n) { edges { node { id title } } } }`,
+		map[string]interface{}{"n": float64(50)}, nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "query complexity 201 exceeds the limit of 200", result.Errors[0].Message)
+
+	result = run(t, ps, graph.Limits{MaxDepth: 3}, `{ __schema { types { fields { type { name } } } } }`, nil, nil)
+	assert.Empty(t, result.Errors, "introspection is not limited")
+}
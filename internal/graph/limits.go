@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bounds what a single query may ask for. Zero values use the
+// defaults.
+type Limits struct {
+	// MaxDepth is the deepest nesting of fields allowed.
+	MaxDepth int
+	// MaxComplexity caps the estimated number of fields resolved: every
+	// field costs 1 and a field taking first multiplies the cost of its
+	// selections by that page size.
+	MaxComplexity int
+}
+
+const (
+	DefaultMaxDepth      = 10
+	DefaultMaxComplexity = 1000
+)
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultMaxDepth
+	}
+	if l.MaxComplexity <= 0 {
+		l.MaxComplexity = DefaultMaxComplexity
+	}
+	return l
+}
+
+// check measures the operation that will run. Introspection fields are not
+// counted so tooling keeps working.
+func (l Limits) check(doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	l = l.withDefaults()
+
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	m := measurer{fragments: fragments, variables: variables}
+	depth, cost := m.selectionSet(operation.SelectionSet)
+	if depth > l.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", depth, l.MaxDepth)
+	}
+	if cost > l.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, l.MaxComplexity)
+	}
+	return nil
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// selectionSet returns the depth and cost of a selection set. Validation has
+// already rejected fragment cycles.
+func (m measurer) selectionSet(set *ast.SelectionSet) (depth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set.Selections {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			d, c = m.selectionSet(sel.SelectionSet)
+			d++
+			c = 1 + m.multiplier(sel)*c
+		case *ast.InlineFragment:
+			d, c = m.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			if frag, ok := m.fragments[sel.Name.Value]; ok {
+				d, c = m.selectionSet(frag.SelectionSet)
+			}
+		}
+		depth = max(depth, d)
+		cost += c
+	}
+	return depth, cost
+}
+
+// multiplier is the page size a connection field will return.
+func (m measurer) multiplier(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return max(n, 1)
+			}
+		case *ast.Variable:
+			switch n := m.variables[v.Name.Value].(type) {
+			case int:
+				return max(n, 1)
+			case float64:
+				return max(int(n), 1)
+			}
+		}
+		return DefaultPageSize
+	}
+	if field.Name.Value == "posts" {
+		return DefaultPageSize
+	}
+	return 1
+}
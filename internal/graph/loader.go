@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// postLoader batches post lookups within one request. Resolvers queue IDs
+// and return thunks; the executor resolves every field at a level before
+// calling any thunk, so the first thunk fetches all queued IDs at once.
+type postLoader struct {
+	ctx   context.Context
+	posts services.PostService
+
+	mu      sync.Mutex
+	pending []int
+	loaded  map[int]loadResult
+}
+
+type loadResult struct {
+	post  models.Post
+	found bool
+	err   error
+}
+
+func newPostLoader(ctx context.Context, ps services.PostService) *postLoader {
+	return &postLoader{ctx: ctx, posts: ps, loaded: map[int]loadResult{}}
+}
+
+// load queues id and returns a thunk resolving to the post, or nil when it
+// does not exist.
+func (l *postLoader) load(id int) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.loaded[id]; !ok {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, ok := l.loaded[id]; !ok {
+			l.flush()
+		}
+		r := l.loaded[id]
+		if r.err != nil {
+			return nil, r.err
+		}
+		if !r.found {
+			return nil, nil
+		}
+		return r.post, nil
+	}
+}
+
+// flush fetches every pending ID. Callers must hold the lock.
+func (l *postLoader) flush() {
+	ids := l.pending
+	l.pending = nil
+
+	posts, err := l.posts.GetPostsByIDs(l.ctx, ids)
+	for _, id := range ids {
+		l.loaded[id] = loadResult{err: err}
+	}
+	if err != nil {
+		return
+	}
+	for _, p := range posts {
+		l.loaded[p.ID] = loadResult{post: p, found: true}
+	}
+}
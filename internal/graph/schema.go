@@ -0,0 +1,295 @@
+package graph
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+const (
+	// DefaultPageSize is used when a connection is queried without first.
+	DefaultPageSize = 20
+	// MaxPageSize bounds first on every connection.
+	MaxPageSize = 100
+)
+
+var (
+	errMissingFields = errors.New("missing required fields")
+	errCannotWrite   = errors.New("missing scope " + auth.ScopeWrite)
+)
+
+// root is the per-request value resolvers reach through p.Info.RootValue.
+type root struct {
+	posts  services.PostService
+	loader *postLoader
+}
+
+func rootOf(p graphql.ResolveParams) *root {
+	return p.Info.RootValue.(*root)
+}
+
+var postType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Post",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return strconv.Itoa(p.Source.(models.Post).ID), nil
+			},
+		},
+		"title": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Post).Title, nil
+			},
+		},
+		"body": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Post).Body, nil
+			},
+		},
+		"createdAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.DateTime),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Post).CreatedAt, nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.DateTime),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Post).UpdatedAt, nil
+			},
+		},
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"endCursor":   &graphql.Field{Type: graphql.String},
+	},
+})
+
+var postEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PostEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"node":   &graphql.Field{Type: graphql.NewNonNull(postType)},
+	},
+})
+
+var postConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PostConnection",
+	Fields: graphql.Fields{
+		"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postEdgeType)))},
+		"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		"totalCount": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			// Counted only when asked for, as it costs a query of its own.
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				n, err := rootOf(p).posts.CountPosts(p.Context)
+				if err != nil {
+					return nil, errors.New("failed to count posts")
+				}
+				return n, nil
+			},
+		},
+	},
+})
+
+var postInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "PostInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"title": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"body":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"post": &graphql.Field{
+			Type: postType,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolvePost,
+		},
+		"posts": &graphql.Field{
+			Type:        graphql.NewNonNull(postConnectionType),
+			Description: "Posts in ID order.",
+			Args: graphql.FieldConfigArgument{
+				"first": &graphql.ArgumentConfig{Type: graphql.Int},
+				"after": &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: resolvePosts,
+		},
+	},
+})
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createPost": &graphql.Field{
+			Type: graphql.NewNonNull(postType),
+			Args: graphql.FieldConfigArgument{
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(postInputType)},
+			},
+			Resolve: resolveCreatePost,
+		},
+		"updatePost": &graphql.Field{
+			Type: graphql.NewNonNull(postType),
+			Args: graphql.FieldConfigArgument{
+				"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(postInputType)},
+			},
+			Resolve: resolveUpdatePost,
+		},
+	},
+})
+
+var schema = mustSchema()
+
+func mustSchema() graphql.Schema {
+	s, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+	if err != nil {
+		panic(fmt.Sprintf("graph: invalid schema: %v", err))
+	}
+	return s
+}
+
+// resolvePost goes through the request's loader so that several post
+// fields in one query cost a single database round-trip.
+func resolvePost(p graphql.ResolveParams) (interface{}, error) {
+	id, err := strconv.Atoi(p.Args["id"].(string))
+	if err != nil {
+		return nil, nil
+	}
+	return rootOf(p).loader.load(id), nil
+}
+
+func resolvePosts(p graphql.ResolveParams) (interface{}, error) {
+	first := DefaultPageSize
+	if v, ok := p.Args["first"].(int); ok {
+		first = v
+	}
+	if first < 0 || first > MaxPageSize {
+		return nil, fmt.Errorf("first must be between 0 and %d", MaxPageSize)
+	}
+
+	after := 0
+	if cursor, ok := p.Args["after"].(string); ok {
+		var err error
+		if after, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra row tells whether another page follows.
+	posts, err := rootOf(p).posts.ListPosts(p.Context, after, first+1)
+	if err != nil {
+		return nil, errors.New("failed to get posts")
+	}
+	hasNextPage := len(posts) > first
+	posts = posts[:min(first, len(posts))]
+
+	edges := make([]map[string]interface{}, 0, len(posts))
+	for _, post := range posts {
+		edges = append(edges, map[string]interface{}{"cursor": encodeCursor(post.ID), "node": post})
+	}
+	pageInfo := map[string]interface{}{"hasNextPage": hasNextPage, "endCursor": nil}
+	if len(edges) > 0 {
+		pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+	}
+
+	return map[string]interface{}{
+		"edges":    edges,
+		"pageInfo": pageInfo,
+	}, nil
+}
+
+func resolveCreatePost(p graphql.ResolveParams) (interface{}, error) {
+	if err := canWrite(p); err != nil {
+		return nil, err
+	}
+	post, err := postInput(p)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := rootOf(p).posts.CreatePost(p.Context, post)
+	if err != nil {
+		return nil, errors.New("failed to create post")
+	}
+	return created, nil
+}
+
+func resolveUpdatePost(p graphql.ResolveParams) (interface{}, error) {
+	if err := canWrite(p); err != nil {
+		return nil, err
+	}
+	post, err := postInput(p)
+	if err != nil {
+		return nil, err
+	}
+	if post.ID, err = strconv.Atoi(p.Args["id"].(string)); err != nil {
+		return nil, errors.New("invalid post ID")
+	}
+
+	updated, err := rootOf(p).posts.UpdatePost(p.Context, post)
+	if err != nil {
+		if errors.Is(err, services.ErrPostNotFound) {
+			return nil, errors.New("post not found")
+		}
+		return nil, errors.New("failed to update post")
+	}
+	return updated, nil
+}
+
+// canWrite refuses mutations to callers without posts:write. The /graphql
+// route itself only requires posts:read, since most requests are queries.
+func canWrite(p graphql.ResolveParams) error {
+	if principal, ok := auth.FromContext(p.Context); ok && !principal.Can(auth.ScopeWrite) {
+		return errCannotWrite
+	}
+	return nil
+}
+
+// postInput applies the same validation as the REST handlers.
+func postInput(p graphql.ResolveParams) (models.Post, error) {
+	input := p.Args["input"].(map[string]interface{})
+	post := models.Post{}
+	post.Title, _ = input["title"].(string)
+	post.Body, _ = input["body"].(string)
+	if err := post.Validate(); err != nil {
+		return models.Post{}, errMissingFields
+	}
+	return post, nil
+}
+
+const cursorPrefix = "post:"
+
+func encodeCursor(id int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil {
+		if id, ok := strings.CutPrefix(string(data), cursorPrefix); ok {
+			if n, err := strconv.Atoi(id); err == nil {
+				return n, nil
+			}
+		}
+	}
+	return 0, errors.New("invalid cursor")
+}
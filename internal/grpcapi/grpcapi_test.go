@@ -0,0 +1,243 @@
+package grpcapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/grpcapi"
+	"olbcloud.com/webapi/internal/grpcapi/blogv1"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+const token = "secret"
+
+// setup serves one PostService over both REST and gRPC.
+func setup(t *testing.T) (http.Handler, blogv1.PostServiceClient) {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	return serve(t, db, grpcapi.Options{AuthToken: token})
+}
+
+// serve is setup over db, with the gRPC server configured by opts.
+func serve(t *testing.T, db database.DB, opts grpcapi.Options) (http.Handler, blogv1.PostServiceClient) {
+	t.Helper()
+	ps := services.NewPostService(db)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpcapi.NewServer(ps, opts)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return apiserver.NewServer(handlers.NewHandlers(ps), apiserver.Options{}), blogv1.NewPostServiceClient(conn)
+}
+
+func authed() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func rest(t *testing.T, mux http.Handler, method, path, body string) (int, map[string]json.RawMessage) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(w, req)
+
+	var resp map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func restPost(t *testing.T, raw json.RawMessage) models.Post {
+	t.Helper()
+	var p models.Post
+	require.NoError(t, json.Unmarshal(raw, &p))
+	return p
+}
+
+func assertSame(t *testing.T, want models.Post, got *blogv1.Post) {
+	t.Helper()
+	assert.Equal(t, int64(want.ID), got.GetId())
+	assert.Equal(t, want.Title, got.GetTitle())
+	assert.Equal(t, want.Body, got.GetBody())
+	assert.True(t, want.CreatedAt.Equal(got.GetCreatedAt().AsTime()), "created_at")
+	assert.True(t, want.UpdatedAt.Equal(got.GetUpdatedAt().AsTime()), "updated_at")
+}
+
+func TestParityWithREST(t *testing.T) {
+	mux, client := setup(t)
+	ctx := authed()
+
+	created, err := client.CreatePost(ctx, &blogv1.CreatePostRequest{Title: "From gRPC", Body: "Body 1"})
+	require.NoError(t, err)
+	code, resp := rest(t, mux, http.MethodPost, "/posts", `{"title": "From REST", "body": "Body 2"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, int64(restPost(t, resp["post"]).ID), created.GetId()+1, "both APIs share one ID sequence")
+
+	_, err = client.UpdatePost(ctx, &blogv1.UpdatePostRequest{Id: created.GetId(), Title: "Updated", Body: "Body 1"})
+	require.NoError(t, err)
+
+	// Get returns the same post over both APIs.
+	for _, id := range []int64{1, 2} {
+		code, resp := rest(t, mux, http.MethodGet, "/posts/"+strconv.FormatInt(id, 10), "")
+		require.Equal(t, http.StatusOK, code)
+		got, err := client.GetPost(ctx, &blogv1.GetPostRequest{Id: id})
+		require.NoError(t, err)
+		assertSame(t, restPost(t, resp["post"]), got)
+	}
+
+	// List streams what GET /posts returns, in the same order.
+	code, resp = rest(t, mux, http.MethodGet, "/posts", "")
+	require.Equal(t, http.StatusOK, code)
+	var want []models.Post
+	require.NoError(t, json.Unmarshal(resp["posts"], &want))
+
+	stream, err := client.ListPosts(ctx, &blogv1.ListPostsRequest{})
+	require.NoError(t, err)
+	var got []*blogv1.Post
+	for {
+		p, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, p)
+	}
+	require.Len(t, got, len(want))
+	for i := range want {
+		assertSame(t, want[i], got[i])
+	}
+	assert.Equal(t, "Updated", got[0].GetTitle())
+}
+
+func TestErrorParityWithREST(t *testing.T) {
+	mux, client := setup(t)
+	ctx := authed()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		httpCode int
+		call     func() error
+		grpcCode codes.Code
+	}{
+		{
+			name: "get missing", method: http.MethodGet, path: "/posts/9", httpCode: http.StatusNotFound,
+			call: func() error {
+				_, err := client.GetPost(ctx, &blogv1.GetPostRequest{Id: 9})
+				return err
+			},
+			grpcCode: codes.NotFound,
+		},
+		{
+			name: "update missing", method: http.MethodPut, path: "/posts/9", body: `{"title": "t", "body": "b"}`, httpCode: http.StatusNotFound,
+			call: func() error {
+				_, err := client.UpdatePost(ctx, &blogv1.UpdatePostRequest{Id: 9, Title: "t", Body: "b"})
+				return err
+			},
+			grpcCode: codes.NotFound,
+		},
+		{
+			name: "create invalid", method: http.MethodPost, path: "/posts", body: `{"title": "t"}`, httpCode: http.StatusBadRequest,
+			call: func() error {
+				_, err := client.CreatePost(ctx, &blogv1.CreatePostRequest{Title: "t"})
+				return err
+			},
+			grpcCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := rest(t, mux, tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.httpCode, code)
+
+			var message string
+			require.NoError(t, json.Unmarshal(resp["error"], &message))
+			st, _ := status.FromError(tt.call())
+			assert.Equal(t, tt.grpcCode, st.Code())
+			assert.Equal(t, message, st.Message())
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	_, client := setup(t)
+
+	_, err := client.GetPost(context.Background(), &blogv1.GetPostRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer nope")
+	stream, err := client.ListPosts(wrong, &blogv1.ListPostsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var metrics map[string]map[string]float64
+	require.Eventually(t, func() bool {
+		metrics = nil
+		return json.Unmarshal([]byte(expvar.Get("grpc").String()), &metrics) == nil &&
+			metrics["/blog.v1.PostService/ListPosts"]["Unauthenticated"] >= 1
+	}, time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, metrics["/blog.v1.PostService/GetPost"]["Unauthenticated"], float64(1))
+}
+
+func TestAuditNamesCaller(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	_, client := serve(t, db, grpcapi.Options{AuthToken: token})
+
+	ctx := metadata.AppendToOutgoingContext(authed(), "x-request-id", "req-7")
+	created, err := client.CreatePost(ctx, &blogv1.CreatePostRequest{Title: "t", Body: "b"})
+	require.NoError(t, err)
+
+	entries, err := db.(database.AuditLog).ListAudit(context.Background(), database.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, grpcapi.GRPCUser, entries[0].Actor)
+	assert.Equal(t, strconv.FormatInt(created.GetId(), 10), entries[0].EntityID)
+	assert.Equal(t, "req-7", entries[0].RequestID)
+}
+
+func TestNoTokenRefusesCalls(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	_, client := serve(t, db, grpcapi.Options{})
+
+	_, err = client.CreatePost(context.Background(), &blogv1.CreatePostRequest{Title: "t", Body: "b"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.CreatePost(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "), &blogv1.CreatePostRequest{Title: "t", Body: "b"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "an empty token matches nothing")
+}
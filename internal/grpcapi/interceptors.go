@@ -0,0 +1,128 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"expvar"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"olbcloud.com/webapi/internal/audit"
+	"olbcloud.com/webapi/internal/auth"
+)
+
+// grpcMetrics is published on /debug/vars as grpc: calls per method and
+// status code, plus the total time spent in each method.
+var grpcMetrics = expvar.NewMap("grpc")
+
+var metricsMu sync.Mutex
+
+func logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	log.Printf("[gRPC] %s %s", info.FullMethod, peerAddr(ctx))
+	resp, err := handler(ctx, req)
+	log.Printf("[gRPC] %s %s completed in %v", info.FullMethod, status.Code(err), time.Since(start))
+	return resp, err
+}
+
+func logStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	log.Printf("[gRPC] %s %s", info.FullMethod, peerAddr(ss.Context()))
+	err := handler(srv, ss)
+	log.Printf("[gRPC] %s %s completed in %v", info.FullMethod, status.Code(err), time.Since(start))
+	return err
+}
+
+func metricsUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	record(info.FullMethod, status.Code(err), time.Since(start))
+	return resp, err
+}
+
+func metricsStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	record(info.FullMethod, status.Code(err), time.Since(start))
+	return err
+}
+
+func record(method string, code codes.Code, elapsed time.Duration) {
+	metricsMu.Lock()
+	m, ok := grpcMetrics.Get(method).(*expvar.Map)
+	if !ok {
+		m = new(expvar.Map).Init()
+		grpcMetrics.Set(method, m)
+	}
+	metricsMu.Unlock()
+
+	m.Add(code.String(), 1)
+	m.AddFloat("total_ms", float64(elapsed)/float64(time.Millisecond))
+}
+
+func authUnary(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := authorize(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(withCaller(ctx), req)
+	}
+}
+
+func authStream(token string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), token); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorize checks the "authorization: Bearer <token>" metadata. An empty
+// token matches nothing, so a server without one fails closed.
+func authorize(ctx context.Context, token string) error {
+	if token == "" {
+		return status.Error(codes.Unauthenticated, "authentication is not configured")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+// GRPCUser is who the audit log names for calls made with the token.
+const GRPCUser = "grpc"
+
+// withCaller tells the audit log who made the call and from where.
+func withCaller(ctx context.Context) context.Context {
+	ctx = auth.NewContext(ctx, auth.Principal{User: GRPCUser})
+	req := audit.Request{ClientIP: peerAddr(ctx)}
+	if host, _, err := net.SplitHostPort(req.ClientIP); err == nil {
+		req.ClientIP = host
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get("x-request-id"); len(ids) > 0 && audit.ValidRequestID(ids[0]) {
+		req.ID = ids[0]
+	}
+	return audit.WithRequest(ctx, req)
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+// Package grpcapi serves services.PostService over gRPC for internal
+// callers. The protobuf definition lives in proto/blog/v1; regenerate the
+// code in blogv1 with `make proto`.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"olbcloud.com/webapi/internal/grpcapi/blogv1"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// Options configures NewServer.
+type Options struct {
+	// AuthToken is the bearer token callers must send in the authorization
+	// metadata. Without one every call is refused.
+	AuthToken string
+}
+
+// NewServer returns a gRPC server exposing ps, with logging, metrics and
+// auth interceptors in that order.
+func NewServer(ps services.PostService, opts Options) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logUnary, metricsUnary, authUnary(opts.AuthToken)),
+		grpc.ChainStreamInterceptor(logStream, metricsStream, authStream(opts.AuthToken)),
+	)
+	blogv1.RegisterPostServiceServer(srv, &postServer{posts: ps})
+	return srv
+}
+
+// StartServer serves srv on port until it is stopped.
+func StartServer(srv *grpc.Server, port string) {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Starting gRPC server on :%s\n", port)
+	if err := srv.Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type postServer struct {
+	blogv1.UnimplementedPostServiceServer
+	posts services.PostService
+}
+
+func (s *postServer) GetPost(ctx context.Context, req *blogv1.GetPostRequest) (*blogv1.Post, error) {
+	post, err := s.posts.GetPostByID(strconv.FormatInt(req.GetId(), 10))
+	if err != nil {
+		if errors.Is(err, services.ErrPostNotFound) {
+			return nil, status.Error(codes.NotFound, "post not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProto(post), nil
+}
+
+func (s *postServer) ListPosts(req *blogv1.ListPostsRequest, stream grpc.ServerStreamingServer[blogv1.Post]) error {
+	err := s.posts.EachPost(stream.Context(), func(p models.Post) error {
+		return stream.Send(toProto(p))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, "failed to get posts")
+	}
+	return nil
+}
+
+func (s *postServer) CreatePost(ctx context.Context, req *blogv1.CreatePostRequest) (*blogv1.Post, error) {
+	post := models.Post{Title: req.GetTitle(), Body: req.GetBody()}
+	if err := post.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "missing required fields")
+	}
+
+	post, err := s.posts.CreatePost(ctx, post)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to create post")
+	}
+	return toProto(post), nil
+}
+
+func (s *postServer) UpdatePost(ctx context.Context, req *blogv1.UpdatePostRequest) (*blogv1.Post, error) {
+	post := models.Post{ID: int(req.GetId()), Title: req.GetTitle(), Body: req.GetBody()}
+	if err := post.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "missing required fields")
+	}
+
+	post, err := s.posts.UpdatePost(ctx, post)
+	if err != nil {
+		if errors.Is(err, services.ErrPostNotFound) {
+			return nil, status.Error(codes.NotFound, "post not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to update post")
+	}
+	return toProto(post), nil
+}
+
+func toProto(p models.Post) *blogv1.Post {
+	return &blogv1.Post{
+		Id:        int64(p.ID),
+		Title:     p.Title,
+		Body:      p.Body,
+		CreatedAt: timestamppb.New(p.CreatedAt),
+		UpdatedAt: timestamppb.New(p.UpdatedAt),
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"olbcloud.com/webapi/internal/auth"
+)
+
+// apiKeyInput is what a client may set when issuing a key.
+type apiKeyInput struct {
+	Name      string     `json:"name"`
+	User      string     `json:"user"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (h *Handlers) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.keyManager(w, r)
+	if !ok {
+		return
+	}
+
+	var in apiKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+	user, ok := managedUser(w, caller, in.User)
+	if !ok {
+		return
+	}
+	// A key may only do what its issuer may; unknown scopes are left for
+	// Issue to reject.
+	for _, scope := range in.Scopes {
+		if slices.Contains(auth.Scopes, scope) && !caller.Can(scope) {
+			writeResponse(w, http.StatusForbidden, map[string]string{"error": "cannot grant scope " + scope + ", which the caller lacks"})
+			return
+		}
+	}
+
+	key, plain, err := h.Auth.Keys.Issue(r.Context(), user, in.Name, in.Scopes, in.ExpiresAt)
+	if errors.Is(err, auth.ErrInvalidKeyRequest) {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to create API key"})
+		return
+	}
+	w.Header().Set("Location", "/api-keys/"+strconv.Itoa(key.ID))
+	writeResponse(w, http.StatusCreated, map[string]interface{}{"api_key": key, "key": plain})
+}
+
+func (h *Handlers) GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.keyManager(w, r)
+	if !ok {
+		return
+	}
+
+	user := r.URL.Query().Get("user")
+	if user != "" || !caller.IsAdmin() {
+		if user, ok = managedUser(w, caller, user); !ok {
+			return
+		}
+	}
+
+	keys, err := h.Auth.Keys.List(r.Context(), user)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to get API keys"})
+		return
+	}
+	writeResponse(w, http.StatusOK, map[string]interface{}{"api_keys": keys})
+}
+
+func (h *Handlers) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.keyManager(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid API key ID"})
+		return
+	}
+
+	// Other users' keys are reported missing rather than forbidden, so
+	// their IDs cannot be probed.
+	key, err := h.Auth.Keys.Get(r.Context(), id)
+	if err == nil && !caller.IsAdmin() && key.User != caller.User {
+		err = auth.ErrKeyNotFound
+	}
+	if err == nil {
+		key, err = h.Auth.Keys.Revoke(r.Context(), id)
+	}
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "API key not found"})
+	case err != nil:
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to revoke API key"})
+	default:
+		writeResponse(w, http.StatusOK, map[string]interface{}{"api_key": key})
+	}
+}
+
+// keyManager returns the caller if it may manage API keys: a signed-in
+// user, not a machine holding a key. It answers 404 when the server runs
+// without API keys.
+func (h *Handlers) keyManager(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	if h.Auth == nil || h.Auth.Keys == nil {
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "API keys are not enabled"})
+		return auth.Principal{}, false
+	}
+	caller, ok := auth.FromContext(r.Context())
+	if !ok || caller.Anonymous() {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeResponse(w, http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+		return auth.Principal{}, false
+	}
+	if caller.APIKeyID != 0 {
+		writeResponse(w, http.StatusForbidden, map[string]string{"error": "API keys cannot manage API keys"})
+		return auth.Principal{}, false
+	}
+	return caller, true
+}
+
+// managedUser resolves whose keys a request is about: the caller's by
+// default, another user's only for admins.
+func managedUser(w http.ResponseWriter, caller auth.Principal, user string) (string, bool) {
+	if user == "" {
+		return caller.User, true
+	}
+	if user != caller.User && !caller.IsAdmin() {
+		writeResponse(w, http.StatusForbidden, map[string]string{"error": "only admins may manage other users' API keys"})
+		return "", false
+	}
+	return user, true
+}
@@ -0,0 +1,184 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// newAuthServer serves a blog that lets anonymous callers read but not
+// write.
+func newAuthServer(t *testing.T) http.Handler {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	hd := handlers.NewHandlers(services.NewPostService(db))
+	hd.Auth = &auth.Authenticator{
+		Keys:       auth.NewKeys(db.(database.APIKeyStore)),
+		AdminToken: "admin-token",
+		Anonymous:  []string{auth.ScopeRead},
+		Sessions:   testSessions,
+	}
+	return apiserver.NewServer(hd, apiserver.Options{})
+}
+
+var testSessions = auth.NewSessions(auth.SessionOptions{Secret: []byte("0123456789abcdef0123456789abcdef"), Tenant: "default"})
+
+// signIn returns the session cookie of user with roles.
+func signIn(t *testing.T, user string, roles ...string) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	require.NoError(t, testSessions.Start(w, auth.Principal{User: user, Roles: roles}))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+func serveAs(mux http.Handler, authorization, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+type issuedKey struct {
+	APIKey models.APIKey `json:"api_key"`
+	Key    string        `json:"key"`
+}
+
+func issueKey(t *testing.T, mux http.Handler, body string) issuedKey {
+	t.Helper()
+	w := serveAs(mux, "Bearer admin-token", http.MethodPost, "/api-keys", body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var issued issuedKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.Equal(t, "/api-keys/"+strconv.Itoa(issued.APIKey.ID), w.Header().Get("Location"))
+	return issued
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	mux := newAuthServer(t)
+	post := `{"title":"Hello","body":"World"}`
+
+	w := serveAs(mux, "", http.MethodPost, "/posts", post)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "anonymous callers may not write")
+	assert.Equal(t, "ApiKey", w.Header().Get("WWW-Authenticate"))
+
+	reader := issueKey(t, mux, `{"name":"reader","user":"bot","scopes":["posts:read"]}`)
+	writer := issueKey(t, mux, `{"name":"writer","user":"bot","scopes":["posts:read","posts:write"]}`)
+
+	w = serveAs(mux, "ApiKey "+reader.Key, http.MethodPost, "/posts", post)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"missing scope posts:write"}`, w.Body.String())
+
+	w = serveAs(mux, "ApiKey "+writer.Key, http.MethodPost, "/v2/posts", post)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = serveAs(mux, "", http.MethodGet, "/posts", "")
+	assert.Equal(t, http.StatusOK, w.Code, "anonymous callers may read")
+
+	w = serveAs(mux, "ApiKey "+reader.Key, http.MethodPost, "/graphql", `{"query":"mutation { createPost(input: {title: \"x\", body: \"y\"}) { id } }"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "missing scope posts:write", "mutations need posts:write too")
+
+	w = serveAs(mux, "ApiKey "+writer.Key+"x", http.MethodGet, "/posts", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "a wrong key is refused even where anonymous callers are allowed")
+	assert.JSONEq(t, `{"error":"invalid credentials"}`, w.Body.String())
+
+	w = serveAs(mux, "Bearer admin-token", http.MethodDelete, "/api-keys/"+strconv.Itoa(writer.APIKey.ID), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serveAs(mux, "ApiKey "+writer.Key, http.MethodPost, "/posts", post)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "revoked keys are refused")
+}
+
+func TestAPIKeyScopesAreCapped(t *testing.T) {
+	mux := newAuthServer(t)
+	issue := func(cookie *http.Cookie, scopes string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(`{"name":"ci","scopes":`+scopes+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	reader := signIn(t, "carol")
+	w := issue(reader, `["posts:read","posts:write"]`)
+	assert.Equal(t, http.StatusForbidden, w.Code, "a reader cannot issue a write key")
+	assert.Contains(t, w.Body.String(), "posts:write")
+	assert.Equal(t, http.StatusCreated, issue(reader, `["posts:read"]`).Code)
+
+	editor := signIn(t, "dave", auth.RoleEditor)
+	assert.Equal(t, http.StatusCreated, issue(editor, `["posts:write"]`).Code)
+}
+
+func TestAPIKeyEndpoints(t *testing.T) {
+	mux := newAuthServer(t)
+
+	issued := issueKey(t, mux, `{"name":"ci","user":"alice","scopes":["posts:write"],"expires_at":"2999-01-01T00:00:00Z"}`)
+	assert.True(t, strings.HasPrefix(issued.Key, issued.APIKey.Prefix+"_"))
+	assert.Equal(t, "alice", issued.APIKey.User)
+	require.NotNil(t, issued.APIKey.ExpiresAt)
+	issueKey(t, mux, `{"name":"other","user":"bob","scopes":["posts:read"]}`)
+
+	w := serveAs(mux, "Bearer admin-token", http.MethodGet, "/api-keys?user=alice", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), issued.Key[len(issued.APIKey.Prefix):], "the secret is never shown again")
+	assert.NotContains(t, w.Body.String(), "hash")
+	var list struct {
+		APIKeys []models.APIKey `json:"api_keys"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.APIKeys, 1)
+	assert.Equal(t, issued.APIKey.Prefix, list.APIKeys[0].Prefix)
+
+	w = serveAs(mux, "Bearer admin-token", http.MethodGet, "/api-keys", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.APIKeys, 2, "admins list every user's keys")
+
+	w = serveAs(mux, "", http.MethodGet, "/api-keys", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serveAs(mux, "ApiKey "+issued.Key, http.MethodGet, "/api-keys", "")
+	assert.Equal(t, http.StatusForbidden, w.Code, "keys cannot manage keys")
+
+	w = serveAs(mux, "Bearer admin-token", http.MethodPost, "/api-keys", `{"name":"bad","scopes":["posts:delete"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serveAs(mux, "Bearer admin-token", http.MethodPost, "/api-keys", `{"name":"old","scopes":["posts:read"],"expires_at":"2000-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serveAs(mux, "Bearer admin-token", http.MethodDelete, "/api-keys/99", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIKeysDisabled(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	mux := apiserver.NewServer(handlers.NewHandlers(services.NewPostService(db)), apiserver.Options{})
+
+	w := serveAs(mux, "", http.MethodGet, "/api-keys", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"API keys are not enabled"}`, w.Body.String())
+
+	w = serveAs(mux, "", http.MethodPost, "/posts", `{"title":"Hello","body":"World"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, "without an authenticator the API stays open")
+}
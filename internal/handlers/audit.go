@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+)
+
+// Audit log pages hold defaultAuditLimit entries unless the caller asks
+// for more, up to maxAuditLimit.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+func (h *Handlers) GetAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Audit == nil {
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "audit log is not enabled"})
+		return
+	}
+	caller, ok := auth.FromContext(r.Context())
+	if !ok || caller.Anonymous() {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeResponse(w, http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+		return
+	}
+	if !caller.IsAdmin() {
+		writeResponse(w, http.StatusForbidden, map[string]string{"error": "only admins may read the audit log"})
+		return
+	}
+
+	q := r.URL.Query()
+	filter := database.AuditFilter{
+		Actor:    q.Get("actor"),
+		Entity:   q.Get("entity"),
+		EntityID: q.Get("entity_id"),
+		Limit:    defaultAuditLimit,
+	}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeResponse(w, http.StatusBadRequest, map[string]string{"error": name + " must be an RFC 3339 time"})
+				return
+			}
+			*t = parsed
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			writeResponse(w, http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and " + strconv.Itoa(maxAuditLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := h.Audit.ListAudit(r.Context(), filter)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to get audit log"})
+		return
+	}
+	writeResponse(w, http.StatusOK, map[string]interface{}{"entries": entries})
+}
@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/audit"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/webhooks"
+)
+
+func newAuditServer(t *testing.T) http.Handler {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	hd := handlers.NewHandlers(services.NewPostService(db))
+	hd.Auth = &auth.Authenticator{
+		Keys:       auth.NewKeys(db.(database.APIKeyStore)),
+		AdminToken: "admin-token",
+		Anonymous:  []string{auth.ScopeRead},
+	}
+	hd.Auth.Keys.Describe = audit.APIKeys
+	hd.Webhooks = webhooks.New(db.(database.WebhookStore), webhooks.Options{})
+	hd.Audit = db.(database.AuditLog)
+	return apiserver.NewServer(hd, apiserver.Options{})
+}
+
+func listAudit(t *testing.T, mux http.Handler, query string) []models.AuditEntry {
+	t.Helper()
+	w := serveAs(mux, "Bearer admin-token", http.MethodGet, "/audit"+query, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Entries
+}
+
+func TestAuditLog(t *testing.T) {
+	mux := newAuditServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"title":"Hello","body":"World"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer admin-token")
+	req.Header.Set(apiserver.RequestIDHeader, "req-42")
+	req.RemoteAddr = "192.0.2.7:4321"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "req-42", w.Header().Get(apiserver.RequestIDHeader), "the caller's request ID is echoed")
+
+	w = serveAs(mux, "Bearer admin-token", http.MethodPut, "/posts/1", `{"title":"Hi","body":"World"}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	generated := w.Header().Get(apiserver.RequestIDHeader)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{32}$`), generated, "a request ID is made up when none is sent")
+
+	entries := listAudit(t, mux, "")
+	require.Len(t, entries, 2)
+	assert.Equal(t, models.AuditUpdate, entries[0].Action)
+	assert.Equal(t, generated, entries[0].RequestID)
+	assert.Equal(t, models.AuditCreate, entries[1].Action)
+	assert.Equal(t, "admin", entries[1].Actor)
+	assert.Equal(t, "post", entries[1].Entity)
+	assert.Equal(t, "1", entries[1].EntityID)
+	assert.Equal(t, "req-42", entries[1].RequestID)
+	assert.Equal(t, "192.0.2.7", entries[1].ClientIP)
+
+	assert.Len(t, listAudit(t, mux, "?limit=1"), 1)
+	assert.Len(t, listAudit(t, mux, "?entity=post&entity_id=1&actor=admin"), 2)
+	assert.Empty(t, listAudit(t, mux, "?actor=bob"))
+	assert.Empty(t, listAudit(t, mux, "?since=2999-01-01T00:00:00Z"))
+	assert.Len(t, listAudit(t, mux, "?until=2999-01-01T00:00:00Z"), 2)
+}
+
+func TestAuditLogCoversWebhooksAndAPIKeys(t *testing.T) {
+	mux := newAuditServer(t)
+
+	w := serveAs(mux, "Bearer admin-token", http.MethodPost, "/webhooks", `{"url":"https://example.com/hook","events":["post.created"],"secret":"whsec_s3cret"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = serveAs(mux, "Bearer admin-token", http.MethodDelete, "/webhooks/1", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	key := issueKey(t, mux, `{"name":"ci","user":"bob","scopes":["posts:read"]}`)
+	w = serveAs(mux, "Bearer admin-token", http.MethodDelete, "/api-keys/"+strconv.Itoa(key.APIKey.ID), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	hooks := listAudit(t, mux, "?entity=webhook")
+	require.Len(t, hooks, 2)
+	assert.Equal(t, models.AuditDelete, hooks[0].Action)
+	assert.Equal(t, models.AuditCreate, hooks[1].Action)
+	assert.Equal(t, "admin", hooks[1].Actor)
+	assert.Equal(t, "1", hooks[1].EntityID)
+	for _, e := range hooks {
+		assert.NotContains(t, string(e.Before)+string(e.After), "s3cret", "webhook secrets are never recorded")
+	}
+
+	keys := listAudit(t, mux, "?entity=api_key")
+	require.Len(t, keys, 2)
+	assert.Equal(t, models.AuditUpdate, keys[0].Action, "a revocation updates the key")
+	assert.Contains(t, string(keys[0].After), `"revoked_at"`)
+	assert.Equal(t, models.AuditCreate, keys[1].Action)
+	assert.Equal(t, strconv.Itoa(key.APIKey.ID), keys[1].EntityID)
+	assert.NotContains(t, string(keys[1].After), key.Key[len(key.Key)-8:], "keys are never recorded")
+}
+
+func TestAuditLogRejects(t *testing.T) {
+	mux := newAuditServer(t)
+	editor := issueKey(t, mux, `{"name":"ci","user":"bob","scopes":["posts:write"]}`)
+
+	assert.Equal(t, http.StatusUnauthorized, serveAs(mux, "", http.MethodGet, "/audit", "").Code)
+	assert.Equal(t, http.StatusForbidden, serveAs(mux, "ApiKey "+editor.Key, http.MethodGet, "/audit", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serveAs(mux, "Bearer admin-token", http.MethodGet, "/audit?since=yesterday", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serveAs(mux, "Bearer admin-token", http.MethodGet, "/audit?limit=0", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serveAs(mux, "Bearer admin-token", http.MethodGet, "/audit?limit=1001", "").Code)
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	mux := newAuthServer(t)
+	w := serveAs(mux, "Bearer admin-token", http.MethodGet, "/audit", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"audit log is not enabled"}`, w.Body.String())
+}
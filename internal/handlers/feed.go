@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/feed"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// RSSFeedHandler serves the latest posts as RSS 2.0.
+func (h *Handlers) RSSFeedHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "application/rss+xml; charset=utf-8", feed.RSS)
+}
+
+// AtomFeedHandler serves the latest posts as Atom 1.0.
+func (h *Handlers) AtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "application/atom+xml; charset=utf-8", feed.Atom)
+}
+
+type feedRenderer func(feed.Options, []models.Post, string) (feed.Feed, error)
+
+// serveFeed renders the feed and answers If-None-Match with 304 when the
+// rendered document has not changed.
+func (h *Handlers) serveFeed(w http.ResponseWriter, r *http.Request, contentType string, render feedRenderer) {
+	posts, err := h.PostService.GetPosts()
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to get posts"})
+		return
+	}
+
+	f, err := render(h.Feed, posts, h.Feed.BaseURL+r.URL.Path)
+	if err != nil {
+		log.Println("Feed render failed:", err)
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to render feed"})
+		return
+	}
+
+	sum := sha256.Sum256(f.Body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	setLastModified(w, f.Updated)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(f.Body)
+}
+
+// etagMatches implements the weak comparison If-None-Match calls for.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
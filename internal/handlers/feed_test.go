@@ -0,0 +1,61 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/feed"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+func TestFeeds(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	hd := handlers.NewHandlers(services.NewPostService(db))
+	hd.Feed = feed.Options{Title: "Blog", BaseURL: "https://blog.example", Items: 10}
+	mux := apiserver.NewServer(hd, apiserver.Options{})
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// An empty blog still has a valid feed.
+	w := get("/feed.atom", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	_, err = db.CreatePost(models.Post{Title: "Hello", Body: "World"})
+	require.NoError(t, err)
+
+	w = get("/feed.rss", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<link>https://blog.example/posts/1</link>")
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	w = get("/feed.rss", etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	_, err = db.CreatePost(models.Post{Title: "Again", Body: "More"})
+	require.NoError(t, err)
+
+	w = get("/feed.rss", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
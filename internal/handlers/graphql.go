@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"olbcloud.com/webapi/internal/graph"
+)
+
+// GraphQLHandler runs a GraphQL query. Errors in the query itself are part
+// of the result, so only an unreadable body is answered with 400.
+func (h *Handlers) GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graph.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, graph.Execute(r.Context(), h.PostService, h.GraphQL, req))
+}
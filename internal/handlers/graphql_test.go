@@ -0,0 +1,45 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func TestGraphQL(t *testing.T) {
+	db, mux := newMemoryServer(t)
+	_, err := db.CreatePost(models.Post{Title: "Hello", Body: "World"})
+	require.NoError(t, err)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"query": "query($id: ID!) { post(id: $id) { title } }", "variables": {"id": "1"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Data struct {
+			Post struct{ Title string }
+		}
+		Errors []interface{}
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, "Hello", result.Data.Post.Title)
+
+	w = post(`{"query": "{ nope }"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `Cannot query field \"nope\"`)
+
+	assert.Equal(t, http.StatusBadRequest, post(`not json`).Code)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/oidc"
+)
+
+// flowCookie keeps a sign-in in progress, and flowTTL is how long the user
+// has to sign in at the provider.
+const (
+	flowCookie = "blog_oidc"
+	flowTTL    = 10 * time.Minute
+)
+
+func (h *Handlers) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.oidcEnabled(w) {
+		return
+	}
+	returnTo := r.URL.Query().Get("return_to")
+	if returnTo == "" {
+		returnTo = "/"
+	}
+	// Only local paths are accepted, so the login cannot be used to send
+	// users to another site.
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "return_to must be a local path"})
+		return
+	}
+
+	authURL, flow, err := h.OIDC.Start(r.Context(), returnTo)
+	if err != nil {
+		writeResponse(w, http.StatusBadGateway, map[string]string{"error": "identity provider is unavailable"})
+		return
+	}
+	if err := h.Auth.Sessions.SetCookie(w, flowCookie, flow, flowTTL); err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to start sign-in"})
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *Handlers) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.oidcEnabled(w) {
+		return
+	}
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "sign-in failed: " + e})
+		return
+	}
+
+	// The flow cookie is single use: a replayed callback finds none.
+	var flow oidc.Flow
+	if !h.Auth.Sessions.Cookie(r, flowCookie, &flow) {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "sign-in expired or was not started here"})
+		return
+	}
+	h.Auth.Sessions.ClearCookie(w, flowCookie)
+	if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(flow.State)) != 1 {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "state does not match"})
+		return
+	}
+	code := q.Get("code")
+	if code == "" {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "missing code"})
+		return
+	}
+
+	p, err := h.OIDC.Finish(r.Context(), flow, code)
+	if errors.Is(err, oidc.ErrInvalidToken) {
+		writeResponse(w, http.StatusUnauthorized, map[string]string{"error": "identity could not be verified"})
+		return
+	}
+	if err != nil {
+		writeResponse(w, http.StatusBadGateway, map[string]string{"error": "identity provider refused the sign-in"})
+		return
+	}
+	if err := h.Auth.Sessions.Start(w, p); err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to start session"})
+		return
+	}
+	http.Redirect(w, r, h.OIDC.ReturnURL(flow), http.StatusFound)
+}
+
+func (h *Handlers) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Auth == nil || h.Auth.Sessions == nil {
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "sessions are not enabled"})
+		return
+	}
+	h.Auth.Sessions.End(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) GetMeHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := auth.FromContext(r.Context())
+	if !ok || p.Anonymous() {
+		writeResponse(w, http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+		return
+	}
+	roles, scopes := p.Roles, p.Scopes
+	if roles == nil {
+		roles = []string{}
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	writeResponse(w, http.StatusOK, map[string]interface{}{"user": p.User, "roles": roles, "scopes": scopes})
+}
+
+// oidcEnabled answers 404 when the server runs without OIDC login.
+func (h *Handlers) oidcEnabled(w http.ResponseWriter) bool {
+	if h.OIDC == nil || h.Auth == nil || h.Auth.Sessions == nil {
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "OIDC login is not enabled"})
+		return false
+	}
+	return true
+}
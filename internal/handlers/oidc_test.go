@@ -0,0 +1,153 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/oidc"
+	"olbcloud.com/webapi/internal/oidc/oidctest"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// newOIDCServer serves a read-only blog whose staff sign in at a fake
+// identity provider, and returns a browser for it.
+func newOIDCServer(t *testing.T) (*httptest.Server, *oidctest.Provider, *http.Client) {
+	t.Helper()
+	idp := oidctest.NewProvider(t, "blog", "secret")
+	idp.SetClaims(map[string]any{"email": "alice@example.com", "email_verified": true, "groups": []string{"staff"}})
+
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	hd := handlers.NewHandlers(services.NewPostService(db))
+	hd.Auth = &auth.Authenticator{
+		Sessions:  auth.NewSessions(auth.SessionOptions{Secret: []byte("0123456789abcdef0123456789abcdef"), Tenant: "default"}),
+		Anonymous: []string{auth.ScopeRead},
+	}
+
+	var mux http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { mux.ServeHTTP(w, r) }))
+	t.Cleanup(srv.Close)
+	hd.OIDC = oidc.New(oidc.Options{
+		Issuer: idp.Issuer(), ClientID: "blog", ClientSecret: "secret", BaseURL: srv.URL,
+		RoleMap: map[string]string{"staff": auth.RoleEditor},
+	})
+	mux = apiserver.NewServer(hd, apiserver.Options{})
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return srv, idp, &http.Client{Jar: jar}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	srv, _, browser := newOIDCServer(t)
+
+	resp, err := browser.Get(srv.URL + "/auth/me")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// The browser follows the redirects to the provider, back to the
+	// callback and on to return_to.
+	resp, err = browser.Get(srv.URL + "/auth/oidc/login?return_to=" + url.QueryEscape("/auth/me"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, srv.URL+"/auth/me", resp.Request.URL.String())
+	var me struct {
+		User   string   `json:"user"`
+		Roles  []string `json:"roles"`
+		Scopes []string `json:"scopes"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&me))
+	assert.Equal(t, "alice@example.com", me.User)
+	assert.Equal(t, []string{auth.RoleEditor}, me.Roles)
+	assert.Equal(t, auth.Scopes, me.Scopes)
+
+	resp, err = browser.Post(srv.URL+"/posts", "application/json", strings.NewReader(`{"title":"Hello","body":"World"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "editors may write")
+
+	resp, err = browser.Post(srv.URL+"/auth/logout", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = browser.Get(srv.URL + "/auth/me")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	srv, idp, browser := newOIDCServer(t)
+	browser.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// start signs in at the provider and returns the callback URL it sent
+	// the browser back to, without following it.
+	start := func() *url.URL {
+		t.Helper()
+		resp, err := browser.Get(srv.URL + "/auth/oidc/login")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		resp, err = browser.Get(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		return callback
+	}
+	get := func(u string) int {
+		t.Helper()
+		resp, err := browser.Get(u)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	callback := start()
+	q := callback.Query()
+	q.Set("state", "forged")
+	callback.RawQuery = q.Encode()
+	assert.Equal(t, http.StatusBadRequest, get(callback.String()), "state must match")
+
+	callback = start()
+	assert.Equal(t, http.StatusFound, get(callback.String()))
+	assert.Equal(t, http.StatusBadRequest, get(callback.String()), "a callback is not replayed")
+
+	callback = start()
+	browser.Jar, _ = cookiejar.New(nil)
+	assert.Equal(t, http.StatusBadRequest, get(callback.String()), "the flow must have started in this browser")
+
+	idp.SetClaims(map[string]any{"email": "alice@example.com", "email_verified": false})
+	callback = start()
+	assert.Equal(t, http.StatusUnauthorized, get(callback.String()))
+
+	assert.Equal(t, http.StatusBadRequest, get(srv.URL+"/auth/oidc/callback?error=access_denied"))
+	assert.Equal(t, http.StatusBadRequest, get(srv.URL+"/auth/oidc/login?return_to="+url.QueryEscape("//evil.example")))
+	assert.Equal(t, http.StatusBadRequest, get(srv.URL+"/auth/oidc/login?return_to="+url.QueryEscape("https://evil.example")))
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	mux := newAuthServer(t)
+	w := serveAs(mux, "", http.MethodGet, "/auth/oidc/login", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"OIDC login is not enabled"}`, w.Body.String())
+
+	w = serveAs(mux, "Bearer admin-token", http.MethodGet, "/auth/me", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user":"admin","roles":["admin"],"scopes":["posts:read","posts:write"]}`, w.Body.String())
+}
@@ -1,20 +1,39 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/go-playground/validator/v10"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/feed"
+	"olbcloud.com/webapi/internal/graph"
 	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/oidc"
+	"olbcloud.com/webapi/internal/presence"
 	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/sitemap"
+	"olbcloud.com/webapi/internal/stream"
+	"olbcloud.com/webapi/internal/webhooks"
 )
 
-var validate = validator.New()
-
 type Handlers struct {
 	PostService services.PostService
+	Feed        feed.Options
+	Sitemap     *sitemap.Sitemap
+	Robots      []byte
+	GraphQL     graph.Limits
+	Webhooks    *webhooks.Dispatcher
+	Stream      *stream.Broker
+	Presence    *presence.Hub
+	Auth        *auth.Authenticator
+	OIDC        *oidc.Provider
+	Audit       database.AuditLog
 }
 
 func NewHandlers(ps services.PostService) Handlers {
@@ -28,6 +47,14 @@ func (h *Handlers) GetPostsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var lastModified time.Time
+	for _, p := range posts {
+		if p.UpdatedAt.After(lastModified) {
+			lastModified = p.UpdatedAt
+		}
+	}
+	setLastModified(w, lastModified)
+
 	writeResponse(w, http.StatusOK, map[string]interface{}{"posts": posts})
 }
 
@@ -44,22 +71,18 @@ func (h *Handlers) GetPostByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setLastModified(w, post.UpdatedAt)
 	writeResponse(w, http.StatusOK, map[string]interface{}{"post": post})
 }
 
 func (h *Handlers) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
-	var post models.Post
-	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	post, problem := decodePost(r)
+	if problem != "" {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": problem})
 		return
 	}
 
-	if err := validate.Struct(post); err != nil {
-		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
-		return
-	}
-
-	post, err := h.PostService.CreatePost(post)
+	post, err := h.PostService.CreatePost(r.Context(), post)
 	if err != nil {
 		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to create post"})
 		return
@@ -70,14 +93,9 @@ func (h *Handlers) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	var post models.Post
-	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
-		return
-	}
-
-	if err := validate.Struct(post); err != nil {
-		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "missing required fields"})
+	post, problem := decodePost(r)
+	if problem != "" {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": problem})
 		return
 	}
 
@@ -88,7 +106,7 @@ func (h *Handlers) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.PostService.UpdatePost(post); err != nil {
+	if _, err := h.PostService.UpdatePost(r.Context(), post); err != nil {
 		if errors.Is(err, services.ErrPostNotFound) {
 			writeResponse(w, http.StatusNotFound, map[string]string{"error": "post not found"})
 			return
@@ -100,6 +118,42 @@ func (h *Handlers) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, http.StatusAccepted, map[string]interface{}{"message": "post updated", "status": "success", "post": post})
 }
 
+// decodePost reads a v1 post body and says what is wrong with it, in the
+// words v1 has always used. Fields other than the post's own are refused,
+// but only once title and body are there, so a body missing them keeps
+// getting the same error. The read-only id, created_at and updated_at,
+// which clients send back with the post they read, are accepted and left
+// to the database to ignore.
+func decodePost(r *http.Request) (models.Post, string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return models.Post{}, "invalid request payload"
+	}
+
+	var post models.Post
+	if err := json.Unmarshal(data, &post); err != nil {
+		return models.Post{}, "invalid request payload"
+	}
+	if err := post.Validate(); err != nil {
+		return models.Post{}, "missing required fields"
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&models.Post{}); err != nil {
+		return models.Post{}, "invalid request payload"
+	}
+	return post, ""
+}
+
+// setLastModified lets the apiserver cache middleware answer conditional
+// requests.
+func setLastModified(w http.ResponseWriter, t time.Time) {
+	if !t.IsZero() {
+		w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
 func writeResponse(w http.ResponseWriter, code int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -250,7 +250,7 @@ func TestHandlers(t *testing.T) {
 func setupTest(mockDB database.DB) (*httptest.ResponseRecorder, http.Handler) {
 	postService := services.NewPostService(mockDB)
 	h := handlers.NewHandlers(postService)
-	mux := apiserver.NewServer(h)
+	mux := apiserver.NewServer(h, apiserver.Options{})
 	return httptest.NewRecorder(), mux
 }
 
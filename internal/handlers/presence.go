@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/presence"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// PresenceHandler joins the caller to the presence room of a post over a
+// WebSocket, in mode, until it disconnects.
+func (h *Handlers) PresenceHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Presence == nil {
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "presence is not enabled"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid post ID"})
+		return
+	}
+	mode := presence.Mode(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = presence.Viewing
+	}
+	if !mode.Valid() {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "mode must be viewing or editing"})
+		return
+	}
+	// Peers are named after who they are signed in as, so nobody can pose
+	// as another editor. Anonymous callers go unnamed.
+	caller, _ := auth.FromContext(r.Context())
+
+	if _, err := h.PostService.GetPostByID(r.PathValue("id")); err != nil {
+		if errors.Is(err, services.ErrPostNotFound) {
+			writeResponse(w, http.StatusNotFound, map[string]string{"error": "post not found"})
+			return
+		}
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to get post"})
+		return
+	}
+
+	if !presence.IsUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		writeResponse(w, http.StatusUpgradeRequired, map[string]string{"error": "expected a WebSocket upgrade"})
+		return
+	}
+	h.Presence.Serve(w, r, id, presence.Peer{Name: caller.User, Mode: mode})
+}
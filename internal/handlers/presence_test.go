@@ -0,0 +1,84 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/presence"
+	"olbcloud.com/webapi/internal/services"
+)
+
+func newPresenceServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	hub := presence.New(presence.Options{})
+	hd := handlers.NewHandlers(services.NewPostService(db, hub.Observe))
+	hd.Presence = hub
+	hd.Auth = &auth.Authenticator{Anonymous: auth.Scopes, Sessions: testSessions}
+	server := httptest.NewServer(apiserver.NewServer(hd, apiserver.Options{}))
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+	return server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestPresence(t *testing.T) {
+	server, url := newPresenceServer(t)
+	mux := server.Config.Handler
+	require.Equal(t, http.StatusCreated, serve(mux, http.MethodPost, "/posts", "", `{"title":"Hello","body":"World"}`).Code)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/posts/1/presence?name=someone-else&mode=editing",
+		http.Header{"Cookie": {signIn(t, "ada", auth.RoleEditor).String()}})
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var m presence.Message
+	require.NoError(t, conn.ReadJSON(&m))
+	assert.Equal(t, presence.TypeWelcome, m.Type)
+	assert.Equal(t, presence.Peer{ID: m.Peer.ID, Name: "ada", Mode: presence.Editing}, *m.Peer)
+
+	require.Equal(t, http.StatusAccepted, serve(mux, http.MethodPut, "/posts/1", "", `{"title":"Saved","body":"World"}`).Code)
+	require.NoError(t, conn.ReadJSON(&m))
+	assert.Equal(t, presence.TypeUpdate, m.Type)
+	assert.Equal(t, "Saved", m.Post.Title)
+}
+
+func TestPresenceErrors(t *testing.T) {
+	server, url := newPresenceServer(t)
+	mux := server.Config.Handler
+	require.Equal(t, http.StatusCreated, serve(mux, http.MethodPost, "/posts", "", `{"title":"Hello","body":"World"}`).Code)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url+"/posts/2/presence", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	w := serve(mux, http.MethodGet, "/posts/1/presence", "", "")
+	assert.Equal(t, http.StatusUpgradeRequired, w.Code)
+	assert.Equal(t, "websocket", w.Header().Get("Upgrade"))
+	assert.Equal(t, http.StatusBadRequest, serve(mux, http.MethodGet, "/posts/1/presence?mode=typing", "", "").Code)
+
+	// A page elsewhere cannot join as the signed-in user.
+	header := http.Header{"Cookie": {signIn(t, "ada").String()}, "Origin": {"https://evil.example"}}
+	_, resp, err = websocket.DefaultDialer.Dial(url+"/posts/1/presence", header)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	_, disabled := newMemoryServer(t)
+	w = serve(disabled, http.MethodGet, "/posts/1/presence", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"presence is not enabled"}`, w.Body.String())
+}
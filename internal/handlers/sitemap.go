@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"olbcloud.com/webapi/internal/sitemap"
+)
+
+// SitemapHandler serves /sitemap.xml, which is an index of
+// /sitemaps/sitemap-N.xml once there are more posts than one file may list.
+func (h *Handlers) SitemapHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Sitemap == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := h.Sitemap.Index(r.Context())
+	if err != nil {
+		log.Println("Sitemap load failed:", err)
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to build sitemap"})
+		return
+	}
+	writeXML(w, data)
+}
+
+func (h *Handlers) SitemapPageHandler(w http.ResponseWriter, r *http.Request) {
+	n, ok := sitemap.ParsePageName(r.PathValue("file"))
+	if h.Sitemap == nil || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, ok, err := h.Sitemap.Page(r.Context(), n)
+	if err != nil {
+		log.Println("Sitemap load failed:", err)
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to build sitemap"})
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeXML(w, data)
+}
+
+func (h *Handlers) RobotsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Robots == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.Robots)
+}
+
+func writeXML(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,47 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/sitemap"
+)
+
+func TestSitemapAndRobots(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	_, err = db.CreatePost(models.Post{Title: "Hello", Body: "World"})
+	require.NoError(t, err)
+
+	hd := handlers.NewHandlers(services.NewPostService(db))
+	hd.Sitemap = sitemap.New("https://blog.example", db.EachPost)
+	hd.Robots = sitemap.Robots("https://blog.example", []string{"/debug/"})
+	mux := apiserver.NewServer(hd, apiserver.Options{})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/sitemap.xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<loc>https://blog.example/posts/1</loc>")
+
+	assert.Equal(t, http.StatusOK, get("/sitemaps/sitemap-1.xml").Code)
+	assert.Equal(t, http.StatusNotFound, get("/sitemaps/sitemap-2.xml").Code)
+	assert.Equal(t, http.StatusNotFound, get("/sitemaps/other.xml").Code)
+
+	w = get("/robots.txt")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Sitemap: https://blog.example/sitemap.xml")
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"olbcloud.com/webapi/internal/stream"
+)
+
+// streamWriteTimeout bounds each write to a stream, so a client that stops
+// reading cannot hold its connection open forever.
+const streamWriteTimeout = 10 * time.Second
+
+// StreamPostsHandler sends post changes as Server-Sent Events until the
+// client disconnects, falls behind or the server shuts down. A client
+// resumes with the Last-Event-ID header, or the last_event_id parameter
+// where it cannot set headers; if the missed events are no longer kept it
+// gets a reset event and should reload the posts.
+func (h *Handlers) StreamPostsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Stream == nil {
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "streaming is not enabled"})
+		return
+	}
+
+	lastID, resume := r.Header.Get("Last-Event-ID"), true
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	id, err := strconv.Atoi(lastID)
+	if lastID == "" {
+		resume = false
+	} else if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid last event ID"})
+		return
+	}
+
+	sub, err := h.Stream.Subscribe(r.Context(), id, resume)
+	if errors.Is(err, stream.ErrClosed) {
+		writeResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "server is shutting down"})
+		return
+	}
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to resume stream"})
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	send := func(write func() error) bool {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return write() == nil && rc.Flush() == nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ok := send(func() error {
+		if sub.Reset {
+			if _, err := fmt.Fprint(w, "event: reset\ndata: {}\n\n"); err != nil {
+				return err
+			}
+		}
+		for _, e := range sub.Backlog {
+			if err := stream.WriteEvent(w, e); err != nil {
+				return err
+			}
+			id = e.ID
+		}
+		// Sent even without a backlog so clients see the stream is open.
+		_, err := fmt.Fprint(w, ": connected\n\n")
+		return err
+	})
+	if !ok {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.Stream.Heartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, open := <-sub.Events:
+			if !open {
+				return
+			}
+			// Events published while the backlog was read arrive twice.
+			// IDs only grow, so an ID up to the last one sent was sent.
+			if resume && !sub.Reset && e.ID <= id {
+				continue
+			}
+			if !send(func() error { return stream.WriteEvent(w, e) }) {
+				return
+			}
+		case <-heartbeat.C:
+			if !send(func() error { _, err := fmt.Fprint(w, ": heartbeat\n\n"); return err }) {
+				return
+			}
+		}
+	}
+}
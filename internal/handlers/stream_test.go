@@ -0,0 +1,155 @@
+package handlers_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/stream"
+)
+
+func newStreamServer(t *testing.T, opts stream.Options) (*httptest.Server, *stream.Broker) {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	broker := stream.New(nil, opts)
+	hd := handlers.NewHandlers(services.NewPostService(db, broker.Observe))
+	hd.Stream = broker
+	server := httptest.NewUnstartedServer(apiserver.NewServer(hd, apiserver.Options{}))
+	server.Config.RegisterOnShutdown(broker.Close)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, broker
+}
+
+// sseEvent is one message read off a stream; comments are skipped.
+type sseEvent struct {
+	id, event, data string
+}
+
+func openStream(t *testing.T, url, lastID string) (*http.Response, <-chan sseEvent) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url+"/posts/stream", nil)
+	require.NoError(t, err)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		var e sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				e.id = value
+			case "event":
+				e.event = value
+			case "data":
+				e.data = value
+			case "":
+				if e.event != "" {
+					events <- e
+				}
+				e = sseEvent{}
+			}
+		}
+	}()
+	return resp, events
+}
+
+func next(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		require.True(t, ok, "the stream ended")
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+		return sseEvent{}
+	}
+}
+
+func TestStreamPosts(t *testing.T) {
+	server, _ := newStreamServer(t, stream.Options{})
+	mux := server.Config.Handler
+
+	resp, events := openStream(t, server.URL, "")
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	require.Equal(t, http.StatusCreated, serve(mux, http.MethodPost, "/posts", "", `{"title":"Hello","body":"World"}`).Code)
+	created := next(t, events)
+	assert.Equal(t, "post.created", created.event)
+	assert.Contains(t, created.data, `"title":"Hello"`)
+
+	require.Equal(t, http.StatusAccepted, serve(mux, http.MethodPut, "/posts/1", "", `{"title":"Changed","body":"World"}`).Code)
+	updated := next(t, events)
+	assert.Equal(t, "post.updated", updated.event)
+
+	// A client that saw only the first event catches up on reconnecting.
+	_, resumed := openStream(t, server.URL, created.id)
+	e := next(t, resumed)
+	assert.Equal(t, updated.id, e.id)
+	assert.Contains(t, e.data, `"title":"Changed"`)
+
+	_, reset := openStream(t, server.URL, "1")
+	assert.Equal(t, "reset", next(t, reset).event)
+
+	assert.Equal(t, http.StatusBadRequest, serve(mux, http.MethodGet, "/posts/stream?last_event_id=x", "", "").Code)
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	server, _ := newStreamServer(t, stream.Options{Heartbeat: 10 * time.Millisecond})
+
+	resp, err := http.Get(server.URL + "/posts/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if scanner.Text() == ": heartbeat" {
+			return
+		}
+	}
+	t.Fatal("no heartbeat")
+}
+
+func TestStreamEndsOnShutdown(t *testing.T) {
+	server, _ := newStreamServer(t, stream.Options{})
+	_, events := openStream(t, server.URL, "")
+
+	done := make(chan error, 1)
+	go func() { done <- server.Config.Shutdown(t.Context()) }()
+
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok, "the stream ends")
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stream outlived shutdown")
+	}
+	require.NoError(t, <-done)
+}
+
+func TestStreamDisabled(t *testing.T) {
+	_, mux := newMemoryServer(t)
+
+	w := serve(mux, http.MethodGet, "/posts/stream", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"streaming is not enabled"}`, w.Body.String())
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// ExportPostsHandler streams every post as newline-delimited JSON. Errors
+// after the first byte can only be logged, as the status is already sent.
+func (h *Handlers) ExportPostsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="posts.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+
+	if err := h.PostService.ExportPosts(r.Context(), w); err != nil {
+		log.Println("Post export failed:", err)
+	}
+}
+
+// ImportPostsHandler reads newline-delimited JSON posts. The mode query
+// parameter is insert (default) or upsert; atomic=true stores all lines or
+// none.
+func (h *Handlers) ImportPostsHandler(w http.ResponseWriter, r *http.Request) {
+	opts := services.ImportOptions{
+		Mode:   database.ImportMode(r.URL.Query().Get("mode")),
+		Atomic: r.URL.Query().Get("atomic") == "true",
+	}
+	if opts.Mode == "" {
+		opts.Mode = database.ImportInsert
+	}
+
+	result, err := h.PostService.ImportPosts(r.Context(), r.Body, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImportMode) {
+			writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeResponse(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to import posts"})
+		return
+	}
+
+	status := http.StatusOK
+	if opts.Atomic && len(result.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	writeResponse(w, status, result)
+}
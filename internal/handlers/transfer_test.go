@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+func newMemoryServer(t *testing.T) (database.DB, http.Handler) {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	return db, apiserver.NewServer(handlers.NewHandlers(services.NewPostService(db)), apiserver.Options{})
+}
+
+func importPosts(t *testing.T, mux http.Handler, query, body string) (int, services.ImportResult) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/posts/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var result services.ImportResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	return w.Code, result
+}
+
+func TestExportPosts(t *testing.T) {
+	db, mux := newMemoryServer(t)
+	for _, title := range []string{"Post 1", "Post 2"} {
+		_, err := db.CreatePost(models.Post{Title: title, Body: "Content"})
+		require.NoError(t, err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/export", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	var post models.Post
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &post))
+	assert.Equal(t, 2, post.ID)
+	assert.Equal(t, "Post 2", post.Title)
+}
+
+func TestImportPosts(t *testing.T) {
+	body := `{"id":5,"title":"Five","body":"Content"}
+
+{"title":"no body"}
+not json
+{"id":7,"title":"Seven","body":"Content","created_at":"2024-01-02T03:04:05Z"}
+`
+
+	t.Run("insert", func(t *testing.T) {
+		db, mux := newMemoryServer(t)
+		code, result := importPosts(t, mux, "", body)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, result.Imported)
+		assert.Equal(t, []int{1, 2}, result.IDs)
+		require.Len(t, result.Errors, 2)
+		assert.Equal(t, 3, result.Errors[0].Line)
+		assert.Equal(t, "missing required fields", result.Errors[0].Error)
+		assert.Equal(t, 4, result.Errors[1].Line)
+
+		post, err := db.GetPostByID("2")
+		require.NoError(t, err)
+		assert.Equal(t, "Seven", post.Title)
+		assert.Equal(t, 2024, post.CreatedAt.Year())
+	})
+
+	t.Run("upsert keeps ids", func(t *testing.T) {
+		db, mux := newMemoryServer(t)
+		code, result := importPosts(t, mux, "?mode=upsert", body)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []int{5, 7}, result.IDs)
+
+		_, err := db.GetPostByID("7")
+		assert.NoError(t, err)
+	})
+
+	t.Run("atomic rejects everything on a bad line", func(t *testing.T) {
+		db, mux := newMemoryServer(t)
+		code, result := importPosts(t, mux, "?mode=upsert&atomic=true", body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.Equal(t, 0, result.Imported)
+		assert.Len(t, result.Errors, 2)
+
+		_, err := db.GetPosts()
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("atomic stores a clean file", func(t *testing.T) {
+		_, mux := newMemoryServer(t)
+		code, result := importPosts(t, mux, "?atomic=true", "{\"title\":\"a\",\"body\":\"b\"}\n{\"title\":\"c\",\"body\":\"d\"}\n")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, result.Imported)
+	})
+
+	t.Run("upsert without id", func(t *testing.T) {
+		_, mux := newMemoryServer(t)
+		_, result := importPosts(t, mux, "?mode=upsert", `{"title":"a","body":"b"}`)
+
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, "upsert requires a positive id", result.Errors[0].Error)
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, mux := newMemoryServer(t)
+		req := httptest.NewRequest(http.MethodPost, "/posts/import?mode=merge", strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// MediaTypeV2 is the Content-Type of v2 responses. Clients may also ask for
+// it in Accept on the unversioned paths.
+const MediaTypeV2 = "application/vnd.blog.v2+json"
+
+// Envelope is the body of every v2 response. Data is null when Errors is
+// set; Meta and Links are always objects.
+type Envelope struct {
+	Data   any               `json:"data"`
+	Errors []EnvelopeError   `json:"errors,omitempty"`
+	Meta   map[string]any    `json:"meta"`
+	Links  map[string]string `json:"links"`
+}
+
+// EnvelopeError is one v2 error. In and Field name the part of the request
+// that failed validation.
+type EnvelopeError struct {
+	Message string `json:"message"`
+	In      string `json:"in,omitempty"`
+	Field   string `json:"field,omitempty"`
+}
+
+const postsV2 = "/v2/posts"
+
+func postV2Link(id int) string {
+	return postsV2 + "/" + strconv.Itoa(id)
+}
+
+func (h *Handlers) GetPostsV2Handler(w http.ResponseWriter, r *http.Request) {
+	posts, err := h.PostService.GetPosts()
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		writeErrorV2(w, http.StatusInternalServerError, postsV2, "failed to get posts")
+		return
+	}
+	if posts == nil {
+		posts = []models.Post{}
+	}
+
+	var lastModified time.Time
+	for _, p := range posts {
+		if p.UpdatedAt.After(lastModified) {
+			lastModified = p.UpdatedAt
+		}
+	}
+	setLastModified(w, lastModified)
+
+	WriteEnvelope(w, http.StatusOK, Envelope{
+		Data:  posts,
+		Meta:  map[string]any{"count": len(posts)},
+		Links: map[string]string{"self": postsV2},
+	})
+}
+
+func (h *Handlers) GetPostByIDV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, postsV2, "invalid post ID")
+		return
+	}
+
+	post, err := h.PostService.GetPostByID(strconv.Itoa(id))
+	if err != nil {
+		if errors.Is(err, services.ErrPostNotFound) {
+			writeErrorV2(w, http.StatusNotFound, postV2Link(id), "post not found")
+			return
+		}
+		writeErrorV2(w, http.StatusInternalServerError, postV2Link(id), "failed to get post")
+		return
+	}
+
+	setLastModified(w, post.UpdatedAt)
+	writePostV2(w, http.StatusOK, post)
+}
+
+func (h *Handlers) CreatePostV2Handler(w http.ResponseWriter, r *http.Request) {
+	var post models.Post
+	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+		writeErrorV2(w, http.StatusBadRequest, postsV2, "invalid request payload")
+		return
+	}
+	if err := post.Validate(); err != nil {
+		writeErrorV2(w, http.StatusBadRequest, postsV2, "missing required fields")
+		return
+	}
+
+	post, err := h.PostService.CreatePost(r.Context(), post)
+	if err != nil {
+		writeErrorV2(w, http.StatusInternalServerError, postsV2, "failed to create post")
+		return
+	}
+	w.Header().Set("Location", postV2Link(post.ID))
+	writePostV2(w, http.StatusCreated, post)
+}
+
+// UpdatePostV2Handler answers with the stored post, unlike v1, which echoes
+// the request.
+func (h *Handlers) UpdatePostV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorV2(w, http.StatusBadRequest, postsV2, "invalid post ID")
+		return
+	}
+
+	var post models.Post
+	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+		writeErrorV2(w, http.StatusBadRequest, postV2Link(id), "invalid request payload")
+		return
+	}
+	if err := post.Validate(); err != nil {
+		writeErrorV2(w, http.StatusBadRequest, postV2Link(id), "missing required fields")
+		return
+	}
+	post.ID = id
+
+	post, err = h.PostService.UpdatePost(r.Context(), post)
+	if err != nil {
+		if errors.Is(err, services.ErrPostNotFound) {
+			writeErrorV2(w, http.StatusNotFound, postV2Link(id), "post not found")
+			return
+		}
+		writeErrorV2(w, http.StatusInternalServerError, postV2Link(id), "failed to update post")
+		return
+	}
+	writePostV2(w, http.StatusAccepted, post)
+}
+
+func writePostV2(w http.ResponseWriter, code int, post models.Post) {
+	WriteEnvelope(w, code, Envelope{
+		Data:  post,
+		Meta:  map[string]any{},
+		Links: map[string]string{"self": postV2Link(post.ID), "collection": postsV2},
+	})
+}
+
+func writeErrorV2(w http.ResponseWriter, code int, self, msg string) {
+	WriteEnvelope(w, code, Envelope{
+		Errors: []EnvelopeError{{Message: msg}},
+		Meta:   map[string]any{},
+		Links:  map[string]string{"self": self},
+	})
+}
+
+// WriteEnvelope writes env as a v2 response.
+func WriteEnvelope(w http.ResponseWriter, code int, env Envelope) {
+	if env.Meta == nil {
+		env.Meta = map[string]any{}
+	}
+	if env.Links == nil {
+		env.Links = map[string]string{}
+	}
+	w.Header().Set("Content-Type", MediaTypeV2)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(env)
+}
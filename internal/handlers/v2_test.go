@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+)
+
+func serve(mux http.Handler, method, path, accept, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func decodeEnvelope(t *testing.T, w *httptest.ResponseRecorder) handlers.Envelope {
+	t.Helper()
+	assert.Equal(t, handlers.MediaTypeV2, w.Header().Get("Content-Type"))
+	var env handlers.Envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+	return env
+}
+
+func TestV1IsUnchanged(t *testing.T) {
+	db, mux := newMemoryServer(t)
+	_, err := db.CreatePost(models.Post{Title: "Hello", Body: "World"})
+	require.NoError(t, err)
+
+	for _, path := range []string{"/posts", "/posts/1"} {
+		bare := serve(mux, http.MethodGet, path, "", "")
+		v1 := serve(mux, http.MethodGet, "/v1"+path, "", "")
+		require.Equal(t, http.StatusOK, v1.Code)
+		assert.Equal(t, bare.Body.String(), v1.Body.String(), path)
+		assert.Equal(t, "application/json", v1.Header().Get("Content-Type"))
+
+		for _, w := range []*httptest.ResponseRecorder{bare, v1} {
+			assert.Equal(t, "@1792368000", w.Header().Get("Deprecation"))
+			assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+			assert.Equal(t, `</v2`+path+`>; rel="successor-version"`, w.Header().Get("Link"))
+		}
+	}
+	assert.JSONEq(t, `{"error":"post not found"}`, serve(mux, http.MethodGet, "/v1/posts/9", "", "").Body.String())
+
+	w := serve(mux, http.MethodGet, "/v2/posts", "", "")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+func TestV2Envelope(t *testing.T) {
+	_, mux := newMemoryServer(t)
+
+	w := serve(mux, http.MethodGet, "/v2/posts", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	env := decodeEnvelope(t, w)
+	assert.Equal(t, []any{}, env.Data)
+	assert.Equal(t, map[string]any{"count": float64(0)}, env.Meta)
+	assert.Equal(t, map[string]string{"self": "/v2/posts"}, env.Links)
+
+	w = serve(mux, http.MethodPost, "/v2/posts", "", `{"title": "Hello", "body": "World"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/v2/posts/1", w.Header().Get("Location"))
+	env = decodeEnvelope(t, w)
+	assert.Equal(t, "Hello", env.Data.(map[string]any)["title"])
+	assert.Equal(t, map[string]string{"self": "/v2/posts/1", "collection": "/v2/posts"}, env.Links)
+
+	w = serve(mux, http.MethodPut, "/v2/posts/1", "", `{"title": "Changed", "body": "World"}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	env = decodeEnvelope(t, w)
+	assert.Equal(t, "Changed", env.Data.(map[string]any)["title"])
+	assert.NotEmpty(t, env.Data.(map[string]any)["created_at"], "v2 returns the stored post")
+
+	w = serve(mux, http.MethodGet, "/v2/posts", "", "")
+	assert.Equal(t, map[string]any{"count": float64(1)}, decodeEnvelope(t, w).Meta)
+
+	w = serve(mux, http.MethodGet, "/v2/posts/9", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	env = decodeEnvelope(t, w)
+	assert.Nil(t, env.Data)
+	assert.Equal(t, []handlers.EnvelopeError{{Message: "post not found"}}, env.Errors)
+
+	w = serve(mux, http.MethodPost, "/v2/posts", "", `{"title": ""}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []handlers.EnvelopeError{
+		{Message: "is required", In: "body", Field: "body"},
+		{Message: "must not be empty", In: "body", Field: "title"},
+	}, decodeEnvelope(t, w).Errors)
+
+	w = serve(mux, http.MethodPut, "/v2/posts/1", "", `{"id": 1, "title": "Changed", "body": "World"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []handlers.EnvelopeError{{Message: "is read-only", In: "body", Field: "id"}}, decodeEnvelope(t, w).Errors)
+}
+
+func TestAcceptNegotiation(t *testing.T) {
+	db, mux := newMemoryServer(t)
+	_, err := db.CreatePost(models.Post{Title: "Hello", Body: "World"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		accept string
+		v2     bool
+	}{
+		{accept: "", v2: false},
+		{accept: "application/json", v2: false},
+		{accept: "*/*", v2: false},
+		{accept: handlers.MediaTypeV2, v2: true},
+		{accept: "application/json;q=0.5, " + handlers.MediaTypeV2, v2: true},
+		{accept: handlers.MediaTypeV2 + ";q=0", v2: false},
+	}
+	for _, tt := range tests {
+		w := serve(mux, http.MethodGet, "/posts/1", tt.accept, "")
+		require.Equal(t, http.StatusOK, w.Code, tt.accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+		if tt.v2 {
+			assert.Equal(t, "Hello", decodeEnvelope(t, w).Data.(map[string]any)["title"], tt.accept)
+			assert.Empty(t, w.Header().Get("Deprecation"))
+		} else {
+			assert.Contains(t, w.Body.String(), `"post":`, tt.accept)
+		}
+	}
+
+	w := serve(mux, http.MethodGet, "/v1/posts/1", handlers.MediaTypeV2, "")
+	assert.Contains(t, w.Body.String(), `"post":`, "the path prefix wins over Accept")
+}
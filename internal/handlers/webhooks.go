@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/webhooks"
+)
+
+// webhookInput is what a client may set when subscribing.
+type webhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+func (h *Handlers) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+
+	var in webhookInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+	hook := models.Webhook{URL: in.URL, Events: in.Events, Secret: in.Secret}
+	if err := hook.Validate(); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "url must be an http or https URL and events must list post.created, post.updated or post.deleted",
+		})
+		return
+	}
+
+	hook, err := h.Webhooks.Subscribe(r.Context(), hook)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to create webhook"})
+		return
+	}
+	w.Header().Set("Location", "/webhooks/"+strconv.Itoa(hook.ID))
+	writeResponse(w, http.StatusCreated, map[string]interface{}{"webhook": hook})
+}
+
+func (h *Handlers) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.webhooksEnabled(w) {
+		return
+	}
+
+	hooks, err := h.Webhooks.Webhooks(r.Context())
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": "failed to get webhooks"})
+		return
+	}
+	writeResponse(w, http.StatusOK, map[string]interface{}{"webhooks": hooks})
+}
+
+func (h *Handlers) GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	hook, err := h.Webhooks.Webhook(r.Context(), id)
+	if err != nil {
+		writeWebhookError(w, err, "failed to get webhook")
+		return
+	}
+	writeResponse(w, http.StatusOK, map[string]interface{}{"webhook": hook})
+}
+
+func (h *Handlers) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.Webhooks.Unsubscribe(r.Context(), id); err != nil {
+		writeWebhookError(w, err, "failed to delete webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.Webhooks.Deliveries(r.Context(), id)
+	if err != nil {
+		writeWebhookError(w, err, "failed to get deliveries")
+		return
+	}
+	writeResponse(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+func (h *Handlers) RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.webhookID(w, r, "id")
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(r.PathValue("deliveryID"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.Webhooks.Redeliver(r.Context(), id, deliveryID)
+	if err != nil {
+		writeWebhookError(w, err, "failed to redeliver")
+		return
+	}
+	writeResponse(w, http.StatusAccepted, map[string]interface{}{"delivery": delivery})
+}
+
+// webhooksEnabled answers 404 when the server runs without a dispatcher,
+// which happens when the database cannot store webhooks.
+func (h *Handlers) webhooksEnabled(w http.ResponseWriter) bool {
+	if h.Webhooks == nil {
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "webhooks are not enabled"})
+		return false
+	}
+	return true
+}
+
+func (h *Handlers) webhookID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	if !h.webhooksEnabled(w) {
+		return 0, false
+	}
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid webhook ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeWebhookError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, webhooks.ErrWebhookNotFound):
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "webhook not found"})
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		writeResponse(w, http.StatusNotFound, map[string]string{"error": "delivery not found"})
+	default:
+		writeResponse(w, http.StatusInternalServerError, map[string]string{"error": msg})
+	}
+}
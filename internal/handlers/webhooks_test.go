@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/webhooks"
+)
+
+func newWebhookServer(t *testing.T) http.Handler {
+	t.Helper()
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	dispatcher := webhooks.New(db.(database.WebhookStore), webhooks.Options{
+		Retry:        database.RetryPolicy{MaxAttempts: 1},
+		PollInterval: 5 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	hd := handlers.NewHandlers(services.NewPostService(db, dispatcher.Observe))
+	hd.Webhooks = dispatcher
+	hd.Auth = &auth.Authenticator{AdminToken: "admin-token", Anonymous: auth.Scopes}
+	return apiserver.NewServer(hd, apiserver.Options{})
+}
+
+func deliveries(t *testing.T, mux http.Handler, id string) []models.WebhookDelivery {
+	t.Helper()
+	w := serveAs(mux, "Bearer admin-token", http.MethodGet, "/webhooks/"+id+"/deliveries", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct{ Deliveries []models.WebhookDelivery }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Deliveries
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	var signature, event string
+	var payload []byte
+	status := http.StatusInternalServerError
+	received := make(chan struct{}, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(webhooks.SignatureHeader)
+		event = r.Header.Get("X-Webhook-Event")
+		w.WriteHeader(status)
+		received <- struct{}{}
+	}))
+	defer receiver.Close()
+	mux := newWebhookServer(t)
+
+	w := serveAs(mux, "Bearer admin-token", http.MethodPost, "/webhooks", `{"url":"`+receiver.URL+`","events":["post.created"],"secret":"s3cret"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "/webhooks/1", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"secret":"s3cret"`, "the secret is shown on creation")
+
+	w = serveAs(mux, "Bearer admin-token", http.MethodGet, "/webhooks/1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+	assert.NotContains(t, serveAs(mux, "Bearer admin-token", http.MethodGet, "/webhooks", "").Body.String(), "s3cret")
+
+	require.Equal(t, http.StatusCreated, serve(mux, http.MethodPost, "/posts", "", `{"title":"Hello","body":"World"}`).Code)
+	<-received
+	assert.Equal(t, "post.created", event)
+	require.NoError(t, webhooks.Verify("s3cret", signature, payload, time.Minute))
+
+	var log []models.WebhookDelivery
+	require.Eventually(t, func() bool {
+		log = deliveries(t, mux, "1")
+		return len(log) == 1 && log[0].Status == models.DeliveryDead
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 500, log[0].ResponseStatus)
+
+	status = http.StatusOK
+	w = serveAs(mux, "Bearer admin-token", http.MethodPost, "/webhooks/1/deliveries/1/redeliver", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	<-received
+	require.Eventually(t, func() bool {
+		log = deliveries(t, mux, "1")
+		return log[0].Status == models.DeliverySucceeded
+	}, 2*time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, http.StatusNotFound, serveAs(mux, "Bearer admin-token", http.MethodPost, "/webhooks/1/deliveries/9/redeliver", "").Code)
+	assert.Equal(t, http.StatusNoContent, serveAs(mux, "Bearer admin-token", http.MethodDelete, "/webhooks/1", "").Code)
+	assert.JSONEq(t, `{"error":"webhook not found"}`, serveAs(mux, "Bearer admin-token", http.MethodDelete, "/webhooks/1", "").Body.String())
+	assert.Equal(t, http.StatusNotFound, serveAs(mux, "Bearer admin-token", http.MethodGet, "/webhooks/1/deliveries", "").Code)
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	mux := newWebhookServer(t)
+
+	for name, body := range map[string]string{
+		"unknown event": `{"url":"https://example.com","events":["post.read"]}`,
+		"no events":     `{"url":"https://example.com","events":[]}`,
+		"not http":      `{"url":"ftp://example.com","events":["post.created"]}`,
+		"missing url":   `{"events":["post.created"]}`,
+	} {
+		w := serveAs(mux, "Bearer admin-token", http.MethodPost, "/webhooks", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
+
+func TestWebhooksNeedAdmin(t *testing.T) {
+	mux := newWebhookServer(t)
+	body := `{"url":"http://169.254.169.254/latest","events":["post.created"]}`
+
+	w := serveAs(mux, "", http.MethodPost, "/webhooks", body)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "anonymous callers may not subscribe, whatever their scopes")
+	assert.Equal(t, http.StatusUnauthorized, serveAs(mux, "", http.MethodGet, "/webhooks", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveAs(mux, "", http.MethodDelete, "/webhooks/1", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveAs(mux, "", http.MethodPost, "/webhooks/1/deliveries/1/redeliver", "").Code)
+}
+
+func TestWebhooksDisabled(t *testing.T) {
+	mux := newAuthServer(t)
+
+	w := serveAs(mux, "Bearer admin-token", http.MethodGet, "/webhooks", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"webhooks are not enabled"}`, w.Body.String())
+}
@@ -0,0 +1,209 @@
+// Package migrate applies versioned schema migrations. The bookkeeping
+// mirrors golang-migrate: a single current version plus a dirty flag that is
+// set while a migration runs, so a failed migration is never retried blindly.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var ErrDirty = errors.New("database is in a dirty migration state, fix it manually and reset the version")
+
+// Migration is a single versioned change. Down may be nil when the change
+// cannot be reverted.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(ctx context.Context) error
+	Down    func(ctx context.Context) error
+}
+
+// Driver stores the migration version and serializes concurrent runners.
+// Version 0 means no migration has been applied.
+type Driver interface {
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+	Version(ctx context.Context) (version uint, dirty bool, err error)
+	SetVersion(ctx context.Context, version uint, dirty bool) error
+}
+
+// Status describes where a database stands relative to the known migrations.
+type Status struct {
+	Version    uint
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+type Migrator struct {
+	driver     Driver
+	migrations []Migration
+}
+
+func New(driver Driver, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{driver: driver, migrations: sorted}
+}
+
+// Up applies every migration newer than the current version and returns the
+// number applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.locked(ctx, func(current uint) error {
+		for _, mig := range m.migrations {
+			if mig.Version <= current {
+				continue
+			}
+			if err := m.run(ctx, mig.Version, mig.Up); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts up to steps applied migrations, newest first, and returns the
+// number reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.locked(ctx, func(current uint) error {
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			mig := m.migrations[i]
+			if mig.Version > current {
+				continue
+			}
+			if mig.Down == nil {
+				return fmt.Errorf("migration %d_%s has no down step", mig.Version, mig.Name)
+			}
+
+			var previous uint
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := m.run(ctx, previous, mig.Down); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status reports the current version and which migrations are applied.
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	version, dirty, err := m.driver.Version(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := Status{Version: version, Dirty: dirty}
+	for _, mig := range m.migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version: mig.Version,
+			Name:    mig.Name,
+			Applied: mig.Version <= version,
+		})
+	}
+	return status, nil
+}
+
+// locked runs fn while holding the driver lock, refusing to touch a dirty
+// database.
+func (m *Migrator) locked(ctx context.Context, fn func(current uint) error) (err error) {
+	if err := m.driver.Lock(ctx); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if unlockErr := m.driver.Unlock(ctx); unlockErr != nil && err == nil {
+			err = fmt.Errorf("release migration lock: %w", unlockErr)
+		}
+	}()
+
+	current, dirty, err := m.driver.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return ErrDirty
+	}
+	return fn(current)
+}
+
+// run marks the target version dirty, executes step and clears the flag
+// once it succeeded.
+func (m *Migrator) run(ctx context.Context, target uint, step func(ctx context.Context) error) error {
+	if err := m.driver.SetVersion(ctx, target, true); err != nil {
+		return err
+	}
+	if err := step(ctx); err != nil {
+		return err
+	}
+	return m.driver.SetVersion(ctx, target, false)
+}
+
+var sqlFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadSQL reads golang-migrate style NNNNNN_name.up.sql and .down.sql files
+// from the root of fsys. Each statement file is executed through exec.
+func LoadSQL(fsys fs.FS, exec func(ctx context.Context, query string) error) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := sqlFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		query := string(data)
+		step := func(ctx context.Context) error { return exec(ctx, query) }
+
+		mig, ok := byVersion[uint(version)]
+		if !ok {
+			mig = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = mig
+		}
+		if match[3] == "up" {
+			mig.Up = step
+		} else {
+			mig.Down = step
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
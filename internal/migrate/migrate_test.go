@@ -0,0 +1,136 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/migrate"
+	"olbcloud.com/webapi/migrations"
+)
+
+type fakeDriver struct {
+	version uint
+	dirty   bool
+	locked  bool
+}
+
+func (d *fakeDriver) Lock(ctx context.Context) error {
+	if d.locked {
+		return errors.New("already locked")
+	}
+	d.locked = true
+	return nil
+}
+
+func (d *fakeDriver) Unlock(ctx context.Context) error {
+	d.locked = false
+	return nil
+}
+
+func (d *fakeDriver) Version(ctx context.Context) (uint, bool, error) {
+	return d.version, d.dirty, nil
+}
+
+func (d *fakeDriver) SetVersion(ctx context.Context, version uint, dirty bool) error {
+	d.version, d.dirty = version, dirty
+	return nil
+}
+
+func recorder(log *[]string, name string) func(context.Context) error {
+	return func(context.Context) error {
+		*log = append(*log, name)
+		return nil
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	var log []string
+	driver := &fakeDriver{}
+	m := migrate.New(driver, []migrate.Migration{
+		{Version: 2, Name: "second", Up: recorder(&log, "up 2"), Down: recorder(&log, "down 2")},
+		{Version: 1, Name: "first", Up: recorder(&log, "up 1"), Down: recorder(&log, "down 1")},
+	})
+	ctx := context.Background()
+
+	n, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"up 1", "up 2"}, log)
+	assert.Equal(t, uint(2), driver.version)
+	assert.False(t, driver.locked, "lock released")
+
+	n, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, uint(1), driver.version)
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []migrate.MigrationStatus{
+		{Version: 1, Name: "first", Applied: true},
+		{Version: 2, Name: "second", Applied: false},
+	}, status.Migrations)
+
+	n, err = m.Down(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, uint(0), driver.version)
+	assert.Equal(t, []string{"up 1", "up 2", "down 2", "down 1"}, log)
+}
+
+func TestMigratorFailureLeavesDirty(t *testing.T) {
+	driver := &fakeDriver{}
+	m := migrate.New(driver, []migrate.Migration{
+		{Version: 1, Name: "broken", Up: func(context.Context) error { return errors.New("boom") }},
+	})
+
+	_, err := m.Up(context.Background())
+	assert.Error(t, err)
+	assert.True(t, driver.dirty)
+	assert.False(t, driver.locked)
+
+	_, err = m.Up(context.Background())
+	assert.ErrorIs(t, err, migrate.ErrDirty)
+}
+
+func TestLoadSQL(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_create.up.sql":   {Data: []byte("CREATE")},
+		"000001_create.down.sql": {Data: []byte("DROP")},
+		"000002_seed.up.sql":     {Data: []byte("INSERT")},
+		"README.md":              {Data: []byte("ignored")},
+	}
+
+	var executed []string
+	ms, err := migrate.LoadSQL(fsys, func(ctx context.Context, query string) error {
+		executed = append(executed, query)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+	assert.Equal(t, uint(1), ms[0].Version)
+	assert.Equal(t, "create", ms[0].Name)
+	assert.Nil(t, ms[1].Down)
+
+	require.NoError(t, ms[0].Up(context.Background()))
+	require.NoError(t, ms[0].Down(context.Background()))
+	assert.Equal(t, []string{"CREATE", "DROP"}, executed)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	ms, err := migrate.LoadSQL(migrations.FS, func(context.Context, string) error { return nil })
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+	for i, m := range ms {
+		assert.Equal(t, uint(i+1), m.Version, "versions are contiguous")
+		assert.NotNil(t, m.Down, "migration %d has a down file", m.Version)
+	}
+}
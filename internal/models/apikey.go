@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// APIKey lets a machine client call the API on behalf of User, limited to
+// Scopes. Only a hash of the key is stored; the key itself is shown once,
+// when it is issued. Prefix identifies the key in lists and logs.
+type APIKey struct {
+	ID         int        `json:"id" bson:"id"`
+	TenantID   string     `json:"-" bson:"tenant_id"`
+	User       string     `json:"user" bson:"user"`
+	Name       string     `json:"name" bson:"name"`
+	Prefix     string     `json:"prefix" bson:"prefix"`
+	Hash       string     `json:"-" bson:"hash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bson:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at"`
+}
+
+// Active reports whether the key may be used at now: it is neither revoked
+// nor expired.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry records one change: who made it, in which request and from
+// where, and the entity before and after as JSON. Before is empty for a
+// creation and After for a deletion. Entries are never changed or removed.
+type AuditEntry struct {
+	ID        int             `json:"id" bson:"id"`
+	TenantID  string          `json:"-" bson:"tenant_id"`
+	Time      time.Time       `json:"time" bson:"time"`
+	Actor     string          `json:"actor" bson:"actor"`
+	Action    string          `json:"action" bson:"action"`
+	Entity    string          `json:"entity" bson:"entity"`
+	EntityID  string          `json:"entity_id" bson:"entity_id"`
+	Before    json.RawMessage `json:"before,omitempty" bson:"before"`
+	After     json.RawMessage `json:"after,omitempty" bson:"after"`
+	RequestID string          `json:"request_id,omitempty" bson:"request_id"`
+	ClientIP  string          `json:"client_ip,omitempty" bson:"client_ip"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Post event types, as recorded in the outbox and reported to observers.
+const (
+	PostCreated = "post.created"
+	PostUpdated = "post.updated"
+	PostDeleted = "post.deleted"
+)
+
+// OutboxEvent is a post event written in the same transaction as the change
+// it describes. Payload is the post as stored. DispatchedAt is set once the
+// event has been published.
+type OutboxEvent struct {
+	ID           int             `json:"id" bson:"id"`
+	TenantID     string          `json:"-" bson:"tenant_id"`
+	Type         string          `json:"type" bson:"type"`
+	Payload      json.RawMessage `json:"payload" bson:"payload"`
+	CreatedAt    time.Time       `json:"created_at" bson:"created_at"`
+	DispatchedAt *time.Time      `json:"dispatched_at" bson:"dispatched_at"`
+}
+
+// NewPostEvent encodes post as the payload of an event of type typ.
+func NewPostEvent(typ string, post Post) (OutboxEvent, error) {
+	payload, err := json.Marshal(post)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	return OutboxEvent{Type: typ, Payload: payload}, nil
+}
@@ -1,12 +1,25 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// Post represents a blog post.
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = validator.New()
+
+// Post represents a blog post. TenantID is set by the database and never
+// exposed through the API.
 type Post struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title" validate:"required"`
-	Body      string    `json:"body" validate:"required"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int       `json:"id" bson:"id"`
+	TenantID  string    `json:"-" bson:"tenant_id"`
+	Title     string    `json:"title" bson:"title" validate:"required"`
+	Body      string    `json:"body" bson:"body" validate:"required"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Validate checks the fields a client must provide.
+func (p Post) Validate() error {
+	return validate.Struct(p)
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook subscribes a URL to post events. Secret signs every delivery; it
+// is only shown when the webhook is created.
+type Webhook struct {
+	ID        int       `json:"id" bson:"id"`
+	TenantID  string    `json:"-" bson:"tenant_id"`
+	URL       string    `json:"url" bson:"url" validate:"required,http_url"`
+	Events    []string  `json:"events" bson:"events" validate:"required,min=1,dive,oneof=post.created post.updated post.deleted"`
+	Secret    string    `json:"secret,omitempty" bson:"secret"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Validate checks the fields a client must provide.
+func (w Webhook) Validate() error {
+	return validate.Struct(w)
+}
+
+// Subscribes reports whether the webhook wants event.
+func (w Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is where a webhook delivery is in its lifecycle.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending is waiting for its first attempt or a retry at
+	// NextAttemptAt.
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded got a 2xx response.
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead failed every attempt and is only retried on request.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event sent, or to be sent, to one webhook. Payload
+// is the exact body that is signed and posted.
+type WebhookDelivery struct {
+	ID             int             `json:"id" bson:"id"`
+	TenantID       string          `json:"-" bson:"tenant_id"`
+	WebhookID      int             `json:"webhook_id" bson:"webhook_id"`
+	Event          string          `json:"event" bson:"event"`
+	Payload        json.RawMessage `json:"payload" bson:"payload"`
+	Status         DeliveryStatus  `json:"status" bson:"status"`
+	Attempts       int             `json:"attempts" bson:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty" bson:"response_status"`
+	LastError      string          `json:"last_error,omitempty" bson:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" bson:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,320 @@
+// Package oidc signs staff in through an OpenID Connect identity provider,
+// using the authorization code flow with PKCE.
+//
+// The provider's discovery document and signing keys are fetched on first
+// use and cached; keys are fetched again early when a token names one that
+// is not cached, as happens when the provider rotates them. Claims of the
+// ID token are mapped to a local user and roles.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"olbcloud.com/webapi/internal/auth"
+)
+
+var (
+	// ErrProvider means the identity provider could not be reached or
+	// refused a request.
+	ErrProvider = errors.New("identity provider error")
+	// ErrInvalidToken means the ID token did not check out, or its claims
+	// name no user.
+	ErrInvalidToken = errors.New("invalid ID token")
+)
+
+// Options configures the client. Zero values fall back to the defaults.
+type Options struct {
+	// Issuer is the provider's issuer URL. The discovery document is read
+	// from Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// BaseURL is the public address of the blog, prefix included. Users
+	// return below it once signed in.
+	BaseURL string
+	// RedirectURL is the callback registered with the provider. Defaults
+	// to BaseURL + "/auth/oidc/callback".
+	RedirectURL string
+	// Scopes are requested besides openid. Defaults to email and profile.
+	Scopes []string
+	// UserClaim holds the local user name. Defaults to email, which must
+	// then not be marked unverified.
+	UserClaim string
+	// RolesClaim lists the user's groups or roles at the provider.
+	// Defaults to groups.
+	RolesClaim string
+	// RoleMap maps values of RolesClaim to local roles such as
+	// auth.RoleAdmin; others are ignored.
+	RoleMap map[string]string
+	// DefaultRoles are given to everyone who signs in.
+	DefaultRoles []string
+	// CacheTTL is how long the discovery document and keys are kept.
+	// Defaults to 1 hour.
+	CacheTTL time.Duration
+	// MinRefreshInterval spaces out fetching the keys again for tokens
+	// signed with an unknown key. Defaults to 10 seconds.
+	MinRefreshInterval time.Duration
+	// Client talks to the provider. Defaults to a client with a 10 second
+	// timeout.
+	Client *http.Client
+}
+
+func (o Options) withDefaults() Options {
+	o.Issuer = strings.TrimSuffix(o.Issuer, "/")
+	o.BaseURL = strings.TrimSuffix(o.BaseURL, "/")
+	if o.RedirectURL == "" {
+		o.RedirectURL = o.BaseURL + "/auth/oidc/callback"
+	}
+	if o.Scopes == nil {
+		o.Scopes = []string{"email", "profile"}
+	}
+	if o.UserClaim == "" {
+		o.UserClaim = "email"
+	}
+	if o.RolesClaim == "" {
+		o.RolesClaim = "groups"
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = time.Hour
+	}
+	if o.MinRefreshInterval <= 0 {
+		o.MinRefreshInterval = 10 * time.Second
+	}
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return o
+}
+
+// Provider is the client of one identity provider.
+type Provider struct {
+	opts Options
+	now  func() time.Time
+
+	mu          sync.Mutex
+	meta        metadata
+	metaFetched time.Time
+
+	keysMu      sync.Mutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// metadata is the part of the discovery document the flow uses.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// New returns a client for the provider opts describes. Nothing is fetched
+// until the first sign-in.
+func New(opts Options) *Provider {
+	return &Provider{opts: opts.withDefaults(), now: time.Now}
+}
+
+// Flow is a sign-in in progress. The browser keeps it, in a signed cookie,
+// between Start and Finish.
+type Flow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// ReturnTo is the local path to go back to once signed in.
+	ReturnTo string `json:"return_to"`
+}
+
+// Start begins a sign-in and returns the provider URL to send the browser
+// to.
+func (p *Provider) Start(ctx context.Context, returnTo string) (string, Flow, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", Flow{}, err
+	}
+
+	flow := Flow{ReturnTo: returnTo}
+	for _, v := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *v, err = randomString(); err != nil {
+			return "", Flow{}, err
+		}
+	}
+
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", Flow{}, fmt.Errorf("%w: authorization_endpoint: %v", ErrProvider, err)
+	}
+	challenge := sha256.Sum256([]byte(flow.Verifier))
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.opts.ClientID)
+	q.Set("redirect_uri", p.opts.RedirectURL)
+	q.Set("scope", strings.Join(slices.Concat([]string{"openid"}, p.opts.Scopes), " "))
+	q.Set("state", flow.State)
+	q.Set("nonce", flow.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), flow, nil
+}
+
+// ReturnURL is where to send the user once flow has finished.
+func (p *Provider) ReturnURL(flow Flow) string {
+	return p.opts.BaseURL + flow.ReturnTo
+}
+
+// Finish redeems the code the provider sent back for flow and returns who
+// signed in. The caller checks the state first.
+func (p *Provider) Finish(ctx context.Context, flow Flow, code string) (auth.Principal, error) {
+	rawToken, err := p.exchange(ctx, flow, code)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	claims, err := p.verify(ctx, rawToken, flow.Nonce)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return p.principal(claims)
+}
+
+// exchange redeems code at the token endpoint and returns the ID token.
+func (p *Provider) exchange(ctx context.Context, flow Flow, code string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.opts.RedirectURL},
+		"code_verifier": {flow.Verifier},
+		"client_id":     {p.opts.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("%w: token_endpoint: %v", ErrProvider, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.opts.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.opts.ClientID), url.QueryEscape(p.opts.ClientSecret))
+	}
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &body)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("%w: token endpoint answered %d %s %s", ErrProvider, status, body.Error, body.ErrorDescription)
+	}
+	return body.IDToken, nil
+}
+
+// principal maps the claims of a verified ID token to a local user.
+func (p *Provider) principal(claims map[string]any) (auth.Principal, error) {
+	user, _ := claims[p.opts.UserClaim].(string)
+	if user == "" {
+		return auth.Principal{}, fmt.Errorf("%w: no %s claim", ErrInvalidToken, p.opts.UserClaim)
+	}
+	if verified, ok := claims["email_verified"].(bool); p.opts.UserClaim == "email" && ok && !verified {
+		return auth.Principal{}, fmt.Errorf("%w: email is not verified", ErrInvalidToken)
+	}
+
+	roles := slices.Clone(p.opts.DefaultRoles)
+	var remote []string
+	switch v := claims[p.opts.RolesClaim].(type) {
+	case string:
+		remote = strings.Fields(v)
+	case []any:
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				remote = append(remote, s)
+			}
+		}
+	}
+	for _, r := range remote {
+		if local, ok := p.opts.RoleMap[r]; ok {
+			roles = append(roles, local)
+		}
+	}
+	roles = slices.Compact(slices.Sorted(slices.Values(roles)))
+	return auth.Principal{User: user, Roles: roles, Scopes: auth.RoleScopes(roles)}, nil
+}
+
+// metadata returns the discovery document, fetching it when it is not
+// cached or has expired.
+func (p *Provider) metadata(ctx context.Context) (metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.metaFetched.IsZero() && p.now().Sub(p.metaFetched) < p.opts.CacheTTL {
+		return p.meta, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return metadata{}, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	var meta metadata
+	status, err := p.do(req, &meta)
+	if err != nil {
+		return metadata{}, err
+	}
+	if status != http.StatusOK {
+		return metadata{}, fmt.Errorf("%w: discovery answered %d", ErrProvider, status)
+	}
+	// The issuer must be the one configured, or tokens from another
+	// provider sharing the discovery host would be accepted.
+	if strings.TrimSuffix(meta.Issuer, "/") != p.opts.Issuer {
+		return metadata{}, fmt.Errorf("%w: discovery names issuer %q", ErrProvider, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return metadata{}, fmt.Errorf("%w: discovery document lacks an endpoint", ErrProvider)
+	}
+	p.meta, p.metaFetched = meta, p.now()
+	return meta, nil
+}
+
+// do sends req and decodes the JSON answer into v, whatever its status.
+func (p *Provider) do(req *http.Request, v any) (int, error) {
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: %s: %v", ErrProvider, req.URL.Path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
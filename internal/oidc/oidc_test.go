@@ -0,0 +1,203 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/oidc"
+	"olbcloud.com/webapi/internal/oidc/oidctest"
+)
+
+const redirectURL = "https://blog.example/auth/oidc/callback"
+
+func newProvider(t *testing.T, opts oidc.Options) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	idp := oidctest.NewProvider(t, "blog", "s3cret/+")
+	idp.SetClaims(map[string]any{"email": "alice@example.com", "email_verified": true})
+	opts.Issuer = idp.Issuer()
+	opts.ClientID = idp.ClientID
+	opts.ClientSecret = idp.ClientSecret
+	opts.RedirectURL = redirectURL
+	return idp, oidc.New(opts)
+}
+
+// authorize starts a sign-in and follows it through the provider, which
+// consents at once, returning the flow and the code sent back.
+func authorize(t *testing.T, p *oidc.Provider) (oidc.Flow, string) {
+	t.Helper()
+	authURL, flow, err := p.Start(context.Background(), "/drafts")
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, redirectURL, back.Scheme+"://"+back.Host+back.Path)
+	assert.Equal(t, flow.State, back.Query().Get("state"))
+	return flow, back.Query().Get("code")
+}
+
+func TestLogin(t *testing.T) {
+	idp, p := newProvider(t, oidc.Options{
+		RoleMap:      map[string]string{"blog-admins": auth.RoleAdmin, "blog-editors": auth.RoleEditor},
+		DefaultRoles: []string{auth.RoleEditor},
+	})
+	idp.SetClaims(map[string]any{
+		"email": "alice@example.com", "email_verified": true,
+		"groups": []string{"blog-admins", "blog-editors", "payroll"},
+	})
+
+	authURL, flow, err := p.Start(context.Background(), "/drafts")
+	require.NoError(t, err)
+	assert.Equal(t, "/drafts", flow.ReturnTo)
+	q, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "S256", q.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email profile", q.Query().Get("scope"))
+	assert.NotContains(t, authURL, flow.Verifier, "only the challenge is sent")
+
+	flow, code := authorize(t, p)
+	principal, err := p.Finish(context.Background(), flow, code)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", principal.User)
+	assert.Equal(t, []string{auth.RoleAdmin, auth.RoleEditor}, principal.Roles)
+	assert.Equal(t, auth.Scopes, principal.Scopes)
+
+	_, err = p.Finish(context.Background(), flow, code)
+	assert.ErrorIs(t, err, oidc.ErrProvider, "a code is redeemed once")
+}
+
+func TestRolesAndUserClaim(t *testing.T) {
+	idp, p := newProvider(t, oidc.Options{UserClaim: "preferred_username", RolesClaim: "roles"})
+	idp.SetClaims(map[string]any{"preferred_username": "bob", "roles": "writer reader"})
+
+	flow, code := authorize(t, p)
+	principal, err := p.Finish(context.Background(), flow, code)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", principal.User)
+	assert.Empty(t, principal.Roles, "unmapped roles are ignored")
+	assert.Equal(t, []string{auth.ScopeRead}, principal.Scopes)
+}
+
+func TestCaching(t *testing.T) {
+	idp, p := newProvider(t, oidc.Options{})
+	for range 3 {
+		flow, code := authorize(t, p)
+		_, err := p.Finish(context.Background(), flow, code)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, idp.Requests("/.well-known/openid-configuration"))
+	assert.Equal(t, 1, idp.Requests("/jwks"))
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp, p := newProvider(t, oidc.Options{MinRefreshInterval: time.Nanosecond})
+	flow, code := authorize(t, p)
+	_, err := p.Finish(context.Background(), flow, code)
+	require.NoError(t, err)
+
+	require.NoError(t, idp.RotateKey())
+	flow, code = authorize(t, p)
+	_, err = p.Finish(context.Background(), flow, code)
+	require.NoError(t, err, "keys are fetched again for an unknown key")
+	assert.Equal(t, 2, idp.Requests("/jwks"))
+}
+
+func TestKeyRefreshIsRateLimited(t *testing.T) {
+	idp, p := newProvider(t, oidc.Options{MinRefreshInterval: time.Hour})
+	flow, code := authorize(t, p)
+	_, err := p.Finish(context.Background(), flow, code)
+	require.NoError(t, err)
+
+	require.NoError(t, idp.RotateKey())
+	flow, code = authorize(t, p)
+	_, err = p.Finish(context.Background(), flow, code)
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+	assert.Equal(t, 1, idp.Requests("/jwks"))
+}
+
+func TestFinishRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]any
+		flow   func(*oidc.Flow)
+		err    error
+	}{
+		{
+			name: "wrong nonce",
+			flow: func(f *oidc.Flow) { f.Nonce = "replayed" },
+			err:  oidc.ErrInvalidToken,
+		},
+		{
+			name: "wrong verifier",
+			flow: func(f *oidc.Flow) { f.Verifier = "guessed" },
+			err:  oidc.ErrProvider,
+		},
+		{
+			name:   "expired",
+			claims: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()},
+			err:    oidc.ErrInvalidToken,
+		},
+		{
+			name:   "other audience",
+			claims: map[string]any{"aud": "another-client"},
+			err:    oidc.ErrInvalidToken,
+		},
+		{
+			name:   "other authorized party",
+			claims: map[string]any{"aud": []string{"blog", "another-client"}, "azp": "another-client"},
+			err:    oidc.ErrInvalidToken,
+		},
+		{
+			name:   "other issuer",
+			claims: map[string]any{"iss": "https://evil.example"},
+			err:    oidc.ErrInvalidToken,
+		},
+		{
+			name:   "unverified email",
+			claims: map[string]any{"email_verified": false},
+			err:    oidc.ErrInvalidToken,
+		},
+		{
+			name:   "no user",
+			claims: map[string]any{"email": ""},
+			err:    oidc.ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp, p := newProvider(t, oidc.Options{})
+			claims := map[string]any{"email": "alice@example.com", "email_verified": true}
+			for k, v := range tt.claims {
+				claims[k] = v
+			}
+			idp.SetClaims(claims)
+
+			flow, code := authorize(t, p)
+			if tt.flow != nil {
+				tt.flow(&flow)
+			}
+			_, err := p.Finish(context.Background(), flow, code)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestDiscoveryFailure(t *testing.T) {
+	idp := oidctest.NewProvider(t, "blog", "secret")
+	p := oidc.New(oidc.Options{Issuer: idp.Issuer() + "/tenant", ClientID: "blog", RedirectURL: redirectURL})
+	_, _, err := p.Start(context.Background(), "/")
+	assert.ErrorIs(t, err, oidc.ErrProvider)
+}
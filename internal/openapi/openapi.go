@@ -0,0 +1,283 @@
+// Package openapi builds an OpenAPI 3.1 document from the routes the server
+// registers, deriving schemas from the Go types the handlers encode.
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is one way of authenticating, named in the components.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to the scopes an
+// operation needs from them. An operation is allowed when any one of its
+// requirements is met.
+type SecurityRequirement map[string][]string
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is the subset of JSON Schema the API uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	// ReadOnly properties appear in responses; requests may not set them.
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// goType is a named struct to be expanded into components.
+	goType reflect.Type
+}
+
+// SchemaOf describes the JSON encoding of v's type. Named structs become
+// references to components, which Build fills in.
+func SchemaOf(v any) *Schema {
+	return reflectSchema(reflect.TypeOf(v))
+}
+
+// Object is a schema for a JSON object with the given properties, all of
+// them required.
+func Object(props map[string]*Schema) *Schema {
+	s := &Schema{Type: "object", Properties: props, AdditionalProperties: false}
+	for name := range props {
+		s.Required = append(s.Required, name)
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+func String() *Schema  { return &Schema{Type: "string"} }
+func Integer() *Schema { return &Schema{Type: "integer"} }
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+
+// JSON is the schema of any JSON value.
+func JSON() *Schema { return &Schema{} }
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func reflectSchema(t reflect.Type) *Schema {
+	if t == nil {
+		return JSON()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return JSON()
+	case t.Kind() == reflect.Pointer:
+		s := reflectSchema(t.Elem())
+		if s.Ref != "" {
+			return &Schema{Ref: s.Ref, goType: s.goType}
+		}
+		s.Type = []any{s.Type, "null"}
+		return s
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return &Schema{Ref: "#/components/schemas/" + t.Name(), goType: t}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return String()
+	case reflect.Slice, reflect.Array:
+		return ArrayOf(reflectSchema(t.Elem()))
+	case reflect.Map:
+		// A nil map encodes as null.
+		return &Schema{Type: []any{"object", "null"}, AdditionalProperties: reflectSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return JSON()
+}
+
+// structSchema follows encoding/json's field naming. Fields tagged
+// validate:"required" are required and may not be empty, and fields the
+// struct does not have are not allowed.
+func structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := reflectSchema(f.Type)
+		if strings.Contains(f.Tag.Get("validate"), "required") {
+			s.Required = append(s.Required, name)
+			if f.Type.Kind() == reflect.String {
+				one := 1
+				prop.MinLength = &one
+			}
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// Route is one documented endpoint.
+type Route struct {
+	Method    string
+	Path      string
+	Operation *Operation
+}
+
+// Build assembles the document and the component schemas the operations
+// refer to.
+func Build(info Info, routes []Route) Document {
+	doc := Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+
+	for _, r := range routes {
+		if r.Operation == nil {
+			continue
+		}
+		item := doc.Paths[r.Path]
+		if item == nil {
+			item = PathItem{}
+			doc.Paths[r.Path] = item
+		}
+		item[strings.ToLower(r.Method)] = r.Operation
+
+		for _, p := range r.Operation.Parameters {
+			collect(p.Schema, doc.Components.Schemas)
+		}
+		if r.Operation.RequestBody != nil {
+			for _, mt := range r.Operation.RequestBody.Content {
+				collect(mt.Schema, doc.Components.Schemas)
+			}
+		}
+		for _, resp := range r.Operation.Responses {
+			for _, mt := range resp.Content {
+				collect(mt.Schema, doc.Components.Schemas)
+			}
+		}
+	}
+	return doc
+}
+
+// collect registers every named struct reachable from s.
+func collect(s *Schema, defs map[string]*Schema) {
+	if s == nil {
+		return
+	}
+	if s.goType != nil {
+		name := s.goType.Name()
+		if _, ok := defs[name]; !ok {
+			def := structSchema(s.goType)
+			defs[name] = def
+			collect(def, defs)
+		}
+	}
+	for _, p := range s.Properties {
+		collect(p, defs)
+	}
+	collect(s.Items, defs)
+	if ap, ok := s.AdditionalProperties.(*Schema); ok {
+		collect(ap, defs)
+	}
+}
@@ -0,0 +1,242 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError is one part of a request that does not match its schema. In is
+// "path", "query" or "body"; Field is the parameter name or, for bodies, the
+// dotted path to the value, omitted for the body itself.
+type FieldError struct {
+	In      string `json:"in"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidateParam checks the raw value of p. present tells an empty value from
+// an absent one.
+func (c Components) ValidateParam(p Parameter, raw string, present bool) *FieldError {
+	fail := func(msg string) *FieldError {
+		return &FieldError{In: p.In, Field: p.Name, Message: msg}
+	}
+	if !present {
+		if p.Required {
+			return fail("is required")
+		}
+		return nil
+	}
+
+	s := c.resolve(p.Schema)
+	var v any = raw
+	switch {
+	case s == nil:
+		return nil
+	case hasType(s, "integer"):
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fail("must be an integer")
+		}
+		v = json.Number(strconv.FormatInt(n, 10))
+	case hasType(s, "number"):
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return fail("must be a number")
+		}
+		v = json.Number(raw)
+	case hasType(s, "boolean"):
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fail("must be true or false")
+		}
+		v = b
+	}
+
+	if errs := c.validate(s, v, ""); len(errs) > 0 {
+		return fail(errs[0].Message)
+	}
+	return nil
+}
+
+// ValidateJSON checks a body decoded with json.Decoder.UseNumber against s.
+// Errors are sorted by field.
+func (c Components) ValidateJSON(s *Schema, v any) []FieldError {
+	errs := c.validate(s, v, "")
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func (c Components) validate(s *Schema, v any, path string) []FieldError {
+	s = c.resolve(s)
+	if s == nil {
+		return nil
+	}
+	fail := func(format string, args ...any) []FieldError {
+		return []FieldError{{In: "body", Field: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		return fail("must be one of %s", joinAny(s.Enum))
+	}
+
+	switch v := v.(type) {
+	case nil:
+		if s.Type != nil && !hasType(s, "null") {
+			return fail("must not be null")
+		}
+	case bool:
+		if s.Type != nil && !hasType(s, "boolean") {
+			return fail("must be %s", typeName(s))
+		}
+	case string:
+		if s.Type != nil && !hasType(s, "string") {
+			return fail("must be %s", typeName(s))
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(v) < *s.MinLength {
+			if *s.MinLength == 1 {
+				return fail("must not be empty")
+			}
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return fail("must be an RFC 3339 date-time")
+			}
+		}
+	case json.Number:
+		return c.validateNumber(s, v, fail)
+	case []any:
+		if s.Type != nil && !hasType(s, "array") {
+			return fail("must be %s", typeName(s))
+		}
+		var errs []FieldError
+		for i, item := range v {
+			errs = append(errs, c.validate(s.Items, item, path+"["+strconv.Itoa(i)+"]")...)
+		}
+		return errs
+	case map[string]any:
+		if s.Type != nil && !hasType(s, "object") {
+			return fail("must be %s", typeName(s))
+		}
+		return c.validateObject(s, v, path)
+	}
+	return nil
+}
+
+func (c Components) validateNumber(s *Schema, n json.Number, fail func(string, ...any) []FieldError) []FieldError {
+	f, err := n.Float64()
+	if err != nil {
+		return fail("must be a number")
+	}
+	switch {
+	case s.Type == nil:
+	case hasType(s, "integer"):
+		// Only what encoding/json will decode into an int.
+		if _, err := strconv.ParseInt(n.String(), 10, 64); err != nil {
+			return fail("must be an integer")
+		}
+	case !hasType(s, "number"):
+		return fail("must be %s", typeName(s))
+	}
+	if s.Minimum != nil && f < *s.Minimum {
+		return fail("must be at least %s", formatFloat(*s.Minimum))
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		return fail("must be at most %s", formatFloat(*s.Maximum))
+	}
+	return nil
+}
+
+func (c Components) validateObject(s *Schema, obj map[string]any, path string) []FieldError {
+	var errs []FieldError
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, FieldError{In: "body", Field: join(path, name), Message: "is required"})
+		}
+	}
+	for name, v := range obj {
+		if prop, ok := s.Properties[name]; ok {
+			if c.resolve(prop).ReadOnly {
+				errs = append(errs, FieldError{In: "body", Field: join(path, name), Message: "is read-only"})
+				continue
+			}
+			errs = append(errs, c.validate(prop, v, join(path, name))...)
+			continue
+		}
+		switch ap := s.AdditionalProperties.(type) {
+		case bool:
+			if !ap {
+				errs = append(errs, FieldError{In: "body", Field: join(path, name), Message: "is not a known field"})
+			}
+		case *Schema:
+			errs = append(errs, c.validate(ap, v, join(path, name))...)
+		}
+	}
+	return errs
+}
+
+// resolve follows a reference to the component schemas, which Build fills.
+func (c Components) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = c.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func hasType(s *Schema, name string) bool {
+	switch t := s.Type.(type) {
+	case string:
+		return t == name
+	case []any:
+		return slices.Contains(t, any(name))
+	}
+	return false
+}
+
+func typeName(s *Schema) string {
+	switch t := s.Type.(type) {
+	case string:
+		return article(t)
+	case []any:
+		names := make([]string, len(t))
+		for i, e := range t {
+			names[i] = article(fmt.Sprint(e))
+		}
+		return strings.Join(names, " or ")
+	}
+	return "valid"
+}
+
+func article(t string) string {
+	switch t {
+	case "null":
+		return "null"
+	case "array", "integer", "object":
+		return "an " + t
+	}
+	return "a " + t
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func joinAny(values []any) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,227 @@
+// Package outbox relays the post events that backends record in their
+// outbox to an EventPublisher.
+//
+// The relay publishes events in the order they were recorded and marks
+// each one dispatched only after Publish returns, so an event is published
+// at least once: a crash between the two publishes it again on restart.
+// Run one relay per database, or publishers will also see duplicates from
+// relays racing each other. It is for consumers that need each event once
+// per database, such as webhooks.
+//
+// What every server must see, such as its own event stream subscribers,
+// follows the outbox with a Follower instead, which marks nothing.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// EventPublisher sends an event on. An error leaves the event pending and
+// stops the batch, so later events are not published ahead of it.
+type EventPublisher interface {
+	Publish(ctx context.Context, e models.OutboxEvent) error
+}
+
+// PublisherFunc adapts a function to EventPublisher.
+type PublisherFunc func(ctx context.Context, e models.OutboxEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, e models.OutboxEvent) error {
+	return f(ctx, e)
+}
+
+// Observers publishes to in-process services observers, the same ones the
+// PostService would otherwise call directly. Every observer is called, and
+// the event fails if any of them does.
+func Observers(observers ...services.Observer) EventPublisher {
+	return PublisherFunc(func(ctx context.Context, e models.OutboxEvent) error {
+		var post models.Post
+		if err := json.Unmarshal(e.Payload, &post); err != nil {
+			return err
+		}
+		var errs []error
+		for _, o := range observers {
+			if err := o(services.Event{Type: services.EventType(e.Type), Post: post}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// Publishers publishes to each publisher in turn, stopping at the first
+// error. The event is then published again to all of them, so each sees it
+// at least once.
+func Publishers(publishers ...EventPublisher) EventPublisher {
+	return PublisherFunc(func(ctx context.Context, e models.OutboxEvent) error {
+		for _, p := range publishers {
+			if err := p.Publish(ctx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Options tunes the relay. Zero values fall back to the defaults below.
+type Options struct {
+	// PollInterval is how often the outbox is checked. Defaults to 1 second.
+	PollInterval time.Duration
+	// BatchSize is how many events are read per query. Defaults to 100.
+	BatchSize int
+	// Retention is how long dispatched events are kept before they are
+	// purged, checked hourly. Defaults to 7 days; negative keeps them.
+	Retention time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.Retention == 0 {
+		o.Retention = 7 * 24 * time.Hour
+	}
+	return o
+}
+
+// purgeInterval is how often dispatched events past Retention are deleted.
+const purgeInterval = time.Hour
+
+// Relay moves events from the outbox to a publisher.
+type Relay struct {
+	store     database.Outbox
+	publisher EventPublisher
+	opts      Options
+}
+
+func NewRelay(store database.Outbox, publisher EventPublisher, opts Options) *Relay {
+	return &Relay{store: store, publisher: publisher, opts: opts.withDefaults()}
+}
+
+// Run publishes pending events every PollInterval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("outbox: %v", err)
+		}
+		if r.opts.Retention > 0 && time.Since(lastPurge) >= purgeInterval {
+			if _, err := r.store.PurgeDispatched(ctx, time.Now().Add(-r.opts.Retention)); err != nil && ctx.Err() == nil {
+				log.Printf("outbox: purging dispatched events: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes pending events until the outbox is empty or an event
+// fails, and returns how many were published.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		events, err := r.store.PendingEvents(ctx, r.opts.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, e := range events {
+			if err := r.publisher.Publish(ctx, e); err != nil {
+				return published, fmt.Errorf("publishing event %d (%s): %w", e.ID, e.Type, err)
+			}
+			if err := r.store.MarkDispatched(ctx, e.ID); err != nil {
+				return published, err
+			}
+			published++
+		}
+		if len(events) < r.opts.BatchSize {
+			return published, nil
+		}
+	}
+}
+
+// Follower passes every event recorded after it starts to a publisher for
+// this process alone. Unlike a Relay it leaves events pending, so each
+// server runs its own and sees every event, whichever server's relay
+// dispatches it.
+type Follower struct {
+	store     database.Outbox
+	publisher EventPublisher
+	opts      Options
+
+	started bool
+	lastID  int
+}
+
+// NewFollower returns a Follower; Retention is not used.
+func NewFollower(store database.Outbox, publisher EventPublisher, opts Options) *Follower {
+	return &Follower{store: store, publisher: publisher, opts: opts.withDefaults()}
+}
+
+// Run publishes new events every PollInterval until ctx is done.
+func (f *Follower) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := f.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("outbox: following: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes the events recorded since the last one published and
+// returns how many there were. The first call only finds the newest
+// event, as earlier ones predate the follower. A failed event is tried
+// again on the next call, ahead of later ones.
+func (f *Follower) Flush(ctx context.Context) (int, error) {
+	if !f.started {
+		id, err := f.store.LastEventID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		f.lastID, f.started = id, true
+	}
+
+	published := 0
+	for {
+		events, err := f.store.EventsSince(ctx, f.lastID, f.opts.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, e := range events {
+			if err := f.publisher.Publish(ctx, e); err != nil {
+				return published, fmt.Errorf("publishing event %d (%s): %w", e.ID, e.Type, err)
+			}
+			f.lastID = e.ID
+			published++
+		}
+		if len(events) < f.opts.BatchSize {
+			return published, nil
+		}
+	}
+}
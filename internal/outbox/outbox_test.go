@@ -0,0 +1,241 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/outbox"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// store is an in-memory database.Outbox.
+type store struct {
+	mu     sync.Mutex
+	events []models.OutboxEvent
+}
+
+func (s *store) add(typ string, post models.Post) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, _ := models.NewPostEvent(typ, post)
+	e.ID = len(s.events) + 1
+	e.CreatedAt = time.Now()
+	s.events = append(s.events, e)
+}
+
+func (s *store) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := []models.OutboxEvent{}
+	for _, e := range s.events {
+		if e.DispatchedAt == nil && len(pending) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (s *store) EventsSince(ctx context.Context, afterID, limit int) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since := []models.OutboxEvent{}
+	for _, e := range s.events {
+		if e.ID > afterID && len(since) < limit {
+			since = append(since, e)
+		}
+	}
+	return since, nil
+}
+
+func (s *store) LastEventID(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events), nil
+}
+
+func (s *store) MarkDispatched(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.events) {
+		return database.ErrNotFound
+	}
+	now := time.Now()
+	s.events[id-1].DispatchedAt = &now
+	return nil
+}
+
+func (s *store) PurgeDispatched(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (s *store) pending() int {
+	events, _ := s.PendingEvents(context.Background(), 1000)
+	return len(events)
+}
+
+func TestFlushPublishesInOrder(t *testing.T) {
+	s := &store{}
+	for i := 1; i <= 5; i++ {
+		s.add(models.PostCreated, models.Post{ID: i})
+	}
+
+	var got []int
+	relay := outbox.NewRelay(s, outbox.PublisherFunc(func(ctx context.Context, e models.OutboxEvent) error {
+		got = append(got, e.ID)
+		return nil
+	}), outbox.Options{BatchSize: 2})
+
+	n, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, got, "batches are read until the outbox is empty")
+	assert.Zero(t, s.pending())
+
+	n, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestFlushStopsAtFailure(t *testing.T) {
+	s := &store{}
+	for i := 1; i <= 3; i++ {
+		s.add(models.PostUpdated, models.Post{ID: i})
+	}
+
+	failing := errors.New("broker down")
+	var attempts []int
+	fail := true
+	relay := outbox.NewRelay(s, outbox.PublisherFunc(func(ctx context.Context, e models.OutboxEvent) error {
+		attempts = append(attempts, e.ID)
+		if e.ID == 2 && fail {
+			return failing
+		}
+		return nil
+	}), outbox.Options{})
+
+	n, err := relay.Flush(context.Background())
+	assert.ErrorIs(t, err, failing)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []int{1, 2}, attempts, "later events wait for the failed one")
+	assert.Equal(t, 2, s.pending())
+
+	fail = false
+	n, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int{1, 2, 2, 3}, attempts, "the failed event is published again")
+}
+
+func TestRunDeliversToObservers(t *testing.T) {
+	s := &store{}
+	s.add(models.PostCreated, models.Post{ID: 7, Title: "Hello"})
+
+	events := make(chan services.Event, 1)
+	relay := outbox.NewRelay(s, outbox.Observers(func(e services.Event) error {
+		events <- e
+		return nil
+	}),
+		outbox.Options{PollInterval: 5 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	e := <-events
+	assert.Equal(t, services.EventPostCreated, e.Type)
+	assert.Equal(t, 7, e.Post.ID)
+	assert.Equal(t, "Hello", e.Post.Title)
+
+	s.add(models.PostUpdated, models.Post{ID: 7, Title: "Changed"})
+	e = <-events
+	assert.Equal(t, services.EventPostUpdated, e.Type)
+	assert.Equal(t, "Changed", e.Post.Title)
+	require.Eventually(t, func() bool { return s.pending() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestObserversRejectsBadPayload(t *testing.T) {
+	called := false
+	err := outbox.Observers(func(services.Event) error {
+		called = true
+		return nil
+	}).
+		Publish(context.Background(), models.OutboxEvent{Type: models.PostCreated, Payload: json.RawMessage(`"nope"`)})
+	assert.Error(t, err)
+	assert.False(t, called)
+}
+
+func TestObserversFailWithAnyObserver(t *testing.T) {
+	var calls int
+	failing := errors.New("queue down")
+	publisher := outbox.Observers(
+		func(services.Event) error { calls++; return failing },
+		func(services.Event) error { calls++; return nil },
+	)
+
+	s := &store{}
+	s.add(models.PostCreated, models.Post{ID: 1})
+	relay := outbox.NewRelay(s, publisher, outbox.Options{})
+
+	_, err := relay.Flush(context.Background())
+	assert.ErrorIs(t, err, failing)
+	assert.Equal(t, 2, calls, "every observer is called")
+	assert.Equal(t, 1, s.pending(), "the event stays pending")
+}
+
+func TestFollowerSeesNewEventsWithoutDispatching(t *testing.T) {
+	s := &store{}
+	s.add(models.PostCreated, models.Post{ID: 1})
+
+	var got []int
+	fail := false
+	follower := outbox.NewFollower(s, outbox.PublisherFunc(func(ctx context.Context, e models.OutboxEvent) error {
+		if fail {
+			return errors.New("unavailable")
+		}
+		got = append(got, e.ID)
+		return nil
+	}), outbox.Options{BatchSize: 2})
+
+	n, err := follower.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n, "events from before the follower started are skipped")
+
+	for i := 2; i <= 4; i++ {
+		s.add(models.PostUpdated, models.Post{ID: 1})
+	}
+	fail = true
+	_, err = follower.Flush(context.Background())
+	assert.Error(t, err)
+
+	fail = false
+	n, err = follower.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []int{2, 3, 4}, got, "a failed event is tried again")
+	assert.Equal(t, 4, s.pending(), "following leaves events to the relay")
+
+	other := outbox.NewFollower(s, outbox.PublisherFunc(func(ctx context.Context, e models.OutboxEvent) error {
+		return nil
+	}), outbox.Options{})
+	_, err = other.Flush(context.Background())
+	require.NoError(t, err)
+	s.add(models.PostUpdated, models.Post{ID: 1})
+	n, err = other.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "each server's follower sees every event")
+}
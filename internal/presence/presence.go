@@ -0,0 +1,375 @@
+// Package presence tells editors of a post who else has it open.
+//
+// Every post has a room of WebSocket connections. The hub announces joins,
+// leaves, mode changes and cursor positions to the rest of the room, and
+// pushes the post when it is saved so other editors know their copy is
+// stale. A connection that cannot keep up with its room is closed rather
+// than holding the others back.
+package presence
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// Mode is what a peer is doing with the post.
+type Mode string
+
+const (
+	Viewing Mode = "viewing"
+	Editing Mode = "editing"
+)
+
+// Valid reports whether m is a known mode.
+func (m Mode) Valid() bool {
+	return m == Viewing || m == Editing
+}
+
+// Peer is one connection in a room.
+type Peer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Mode Mode   `json:"mode"`
+}
+
+// Message types. Clients send cursor and mode; the hub sends the others
+// and relays cursor and mode with the sending peer.
+const (
+	TypeWelcome = "welcome"
+	TypeJoin    = "join"
+	TypeLeave   = "leave"
+	TypeMode    = "mode"
+	TypeCursor  = "cursor"
+	TypeUpdate  = "update"
+	TypeError   = "error"
+)
+
+// Message is the JSON sent over a connection.
+type Message struct {
+	Type string `json:"type"`
+	// Peer is who the message is about; in welcome, the receiver itself.
+	Peer *Peer `json:"peer,omitempty"`
+	// Peers are the others already in the room, sent in welcome.
+	Peers []Peer `json:"peers,omitempty"`
+	// Mode is the new mode in a mode message from a client.
+	Mode Mode `json:"mode,omitempty"`
+	// Cursor is passed on as the client sent it.
+	Cursor json.RawMessage `json:"cursor,omitempty"`
+	// Post is the saved post in update.
+	Post  *models.Post `json:"post,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// Options tunes the hub. Zero values fall back to the defaults below.
+type Options struct {
+	// MaxMessageBytes limits messages from clients; larger ones close the
+	// connection. Defaults to 4096.
+	MaxMessageBytes int64
+	// PingInterval is how often connections are pinged. Defaults to 30
+	// seconds.
+	PingInterval time.Duration
+	// PongTimeout is how long a connection may stay silent, pongs
+	// included, before it is closed. Defaults to twice PingInterval.
+	PongTimeout time.Duration
+	// SendBuffer is how many messages may wait for a slow connection
+	// before it is closed. Defaults to 32.
+	SendBuffer int
+	// AllowedOrigins are the origins besides the server's own that
+	// browsers may connect from. "*" allows any, but not for browsers
+	// signed in with a session cookie, which a page elsewhere could
+	// otherwise use to join as them. Defaults to none.
+	AllowedOrigins []string
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxMessageBytes <= 0 {
+		o.MaxMessageBytes = 4096
+	}
+	if o.PingInterval <= 0 {
+		o.PingInterval = 30 * time.Second
+	}
+	if o.PongTimeout <= o.PingInterval {
+		o.PongTimeout = 2 * o.PingInterval
+	}
+	if o.SendBuffer <= 0 {
+		o.SendBuffer = 32
+	}
+	return o
+}
+
+// writeTimeout bounds each write to a connection.
+const writeTimeout = 10 * time.Second
+
+// Hub tracks the rooms of every post.
+type Hub struct {
+	opts     Options
+	upgrader websocket.Upgrader
+
+	mu     sync.Mutex
+	rooms  map[int]map[*client]struct{}
+	closed bool
+}
+
+type client struct {
+	conn   *websocket.Conn
+	postID int
+	peer   Peer
+	send   chan Message
+	// closeCode is sent when send is closed.
+	closeCode int
+}
+
+// New returns an empty Hub.
+func New(opts Options) *Hub {
+	opts = opts.withDefaults()
+	h := &Hub{opts: opts, rooms: make(map[int]map[*client]struct{})}
+	h.upgrader = websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	return h
+}
+
+// checkOrigin allows requests without an Origin header, which do not come
+// from browsers, and browsers on the server's own origin or a listed one.
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(h.opts.AllowedOrigins, origin) {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	_, err := r.Cookie(auth.SessionCookie)
+	return err != nil && slices.Contains(h.opts.AllowedOrigins, "*")
+}
+
+// Serve upgrades the request and keeps peer in the room of postID until
+// the connection closes. The peer's ID is assigned here. If the upgrade
+// fails the response has been written.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, postID int, peer Peer) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	peer.ID = hex.EncodeToString(id)
+	c := &client{conn: conn, postID: postID, peer: peer, send: make(chan Message, h.opts.SendBuffer)}
+
+	if !h.join(c) {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"), time.Now().Add(writeTimeout))
+		conn.Close()
+		return
+	}
+	go h.write(c)
+	h.read(c)
+
+	h.mu.Lock()
+	h.removeLocked(c, websocket.CloseNormalClosure)
+	h.mu.Unlock()
+}
+
+// join adds c to its room, welcomes it and announces it to the others.
+func (h *Hub) join(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+
+	room := h.rooms[c.postID]
+	if room == nil {
+		room = make(map[*client]struct{})
+		h.rooms[c.postID] = room
+	}
+	peers := []Peer{}
+	for other := range room {
+		peers = append(peers, other.peer)
+	}
+	slices.SortFunc(peers, func(a, b Peer) int { return cmp.Compare(a.ID, b.ID) })
+
+	self := c.peer
+	c.send <- Message{Type: TypeWelcome, Peer: &self, Peers: peers}
+	h.broadcastLocked(c.postID, Message{Type: TypeJoin, Peer: &self}, nil)
+	room[c] = struct{}{}
+	return true
+}
+
+// read handles the client's messages until the connection fails, goes
+// silent for PongTimeout or sends a message over MaxMessageBytes.
+func (h *Hub) read(c *client) {
+	c.conn.SetReadLimit(h.opts.MaxMessageBytes)
+	alive := func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(h.opts.PongTimeout))
+	}
+	alive("")
+	c.conn.SetPongHandler(alive)
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		alive("")
+
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			h.reply(c, Message{Type: TypeError, Error: "messages must be JSON objects"})
+			continue
+		}
+		h.handle(c, m)
+	}
+}
+
+func (h *Hub) handle(c *client, m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.rooms[c.postID][c]; !ok {
+		return
+	}
+
+	switch m.Type {
+	case TypeCursor:
+		peer := c.peer
+		h.broadcastLocked(c.postID, Message{Type: TypeCursor, Peer: &peer, Cursor: m.Cursor}, c)
+	case TypeMode:
+		if !m.Mode.Valid() {
+			h.sendLocked(c, Message{Type: TypeError, Error: "mode must be viewing or editing"})
+			return
+		}
+		c.peer.Mode = m.Mode
+		peer := c.peer
+		h.broadcastLocked(c.postID, Message{Type: TypeMode, Peer: &peer}, c)
+	default:
+		h.sendLocked(c, Message{Type: TypeError, Error: "unknown message type " + m.Type})
+	}
+}
+
+func (h *Hub) reply(c *client, m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.rooms[c.postID][c]; ok {
+		h.sendLocked(c, m)
+	}
+}
+
+// write sends queued messages and pings until the queue is closed or a
+// write fails, then closes the connection.
+func (h *Hub) write(c *client) {
+	ping := time.NewTicker(h.opts.PingInterval)
+	defer ping.Stop()
+	defer c.conn.Close()
+
+	for {
+		select {
+		case m, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, ""))
+				return
+			}
+			if err := c.conn.WriteJSON(m); err != nil {
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sendLocked queues m for c, closing c if its queue is full.
+func (h *Hub) sendLocked(c *client, m Message) {
+	select {
+	case c.send <- m:
+	default:
+		h.removeLocked(c, websocket.CloseTryAgainLater)
+	}
+}
+
+// broadcastLocked sends m to everyone in the room but except.
+func (h *Hub) broadcastLocked(postID int, m Message, except *client) {
+	for c := range h.rooms[postID] {
+		if c != except {
+			h.sendLocked(c, m)
+		}
+	}
+}
+
+// removeLocked takes c out of its room, closes it with code and tells the
+// others it left. It does nothing if c is already gone.
+func (h *Hub) removeLocked(c *client, code int) {
+	room := h.rooms[c.postID]
+	if _, ok := room[c]; !ok {
+		return
+	}
+	delete(room, c)
+	if len(room) == 0 {
+		delete(h.rooms, c.postID)
+	}
+	c.closeCode = code
+	close(c.send)
+
+	peer := c.peer
+	h.broadcastLocked(c.postID, Message{Type: TypeLeave, Peer: &peer}, nil)
+}
+
+// Observe is a services.Observer that pushes saved posts to their room.
+func (h *Hub) Observe(e services.Event) error {
+	if e.Type != services.EventPostUpdated {
+		return nil
+	}
+	post := e.Post
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.broadcastLocked(post.ID, Message{Type: TypeUpdate, Post: &post}, nil)
+	return nil
+}
+
+// Peers lists who is in the room of postID.
+func (h *Hub) Peers(postID int) []Peer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	peers := []Peer{}
+	for c := range h.rooms[postID] {
+		peers = append(peers, c.peer)
+	}
+	slices.SortFunc(peers, func(a, b Peer) int { return cmp.Compare(a.ID, b.ID) })
+	return peers
+}
+
+// Close closes every connection with "going away" and refuses new ones.
+// The server does not track upgraded connections, so it has to be called
+// on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for postID, room := range h.rooms {
+		for c := range room {
+			c.closeCode = websocket.CloseGoingAway
+			close(c.send)
+		}
+		delete(h.rooms, postID)
+	}
+}
+
+// IsUpgrade reports whether r asks for a WebSocket.
+func IsUpgrade(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
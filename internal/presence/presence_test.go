@@ -0,0 +1,198 @@
+package presence_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/presence"
+	"olbcloud.com/webapi/internal/services"
+)
+
+func newHub(t *testing.T, opts presence.Options) (*presence.Hub, string) {
+	t.Helper()
+	hub := presence.New(opts)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Serve(w, r, 1, presence.Peer{Name: r.URL.Query().Get("name"), Mode: presence.Viewing})
+	}))
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+	return hub, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url, name string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?name="+name, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func receive(t *testing.T, conn *websocket.Conn) presence.Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m presence.Message
+	require.NoError(t, conn.ReadJSON(&m))
+	return m
+}
+
+func TestRoom(t *testing.T) {
+	_, url := newHub(t, presence.Options{})
+
+	ada := dial(t, url, "ada")
+	welcome := receive(t, ada)
+	assert.Equal(t, presence.TypeWelcome, welcome.Type)
+	assert.Equal(t, "ada", welcome.Peer.Name)
+	assert.Empty(t, welcome.Peers)
+
+	bob := dial(t, url, "bob")
+	welcome = receive(t, bob)
+	require.Len(t, welcome.Peers, 1)
+	assert.Equal(t, "ada", welcome.Peers[0].Name)
+	bobID := welcome.Peer.ID
+
+	join := receive(t, ada)
+	assert.Equal(t, presence.TypeJoin, join.Type)
+	assert.Equal(t, bobID, join.Peer.ID)
+
+	require.NoError(t, bob.WriteJSON(map[string]any{"type": "mode", "mode": "editing"}))
+	mode := receive(t, ada)
+	assert.Equal(t, presence.TypeMode, mode.Type)
+	assert.Equal(t, presence.Editing, mode.Peer.Mode)
+
+	require.NoError(t, bob.WriteJSON(map[string]any{"type": "cursor", "cursor": map[string]int{"line": 3, "column": 7}}))
+	cursor := receive(t, ada)
+	assert.Equal(t, presence.TypeCursor, cursor.Type)
+	assert.Equal(t, bobID, cursor.Peer.ID)
+	assert.JSONEq(t, `{"line":3,"column":7}`, string(cursor.Cursor))
+
+	require.NoError(t, bob.WriteJSON(map[string]any{"type": "mode", "mode": "typing"}))
+	assert.Equal(t, "mode must be viewing or editing", receive(t, bob).Error)
+	require.NoError(t, bob.WriteMessage(websocket.TextMessage, []byte("hello")))
+	assert.Equal(t, presence.TypeError, receive(t, bob).Type)
+
+	bob.Close()
+	leave := receive(t, ada)
+	assert.Equal(t, presence.TypeLeave, leave.Type)
+	assert.Equal(t, bobID, leave.Peer.ID)
+}
+
+func TestObservePushesUpdates(t *testing.T) {
+	hub, url := newHub(t, presence.Options{})
+	conn := dial(t, url, "ada")
+	receive(t, conn)
+
+	hub.Observe(services.Event{Type: services.EventPostCreated, Post: models.Post{ID: 1}})
+	hub.Observe(services.Event{Type: services.EventPostUpdated, Post: models.Post{ID: 2, Title: "Other"}})
+	hub.Observe(services.Event{Type: services.EventPostUpdated, Post: models.Post{ID: 1, Title: "Saved"}})
+
+	m := receive(t, conn)
+	assert.Equal(t, presence.TypeUpdate, m.Type, "only updates of this post are pushed")
+	assert.Equal(t, "Saved", m.Post.Title)
+}
+
+func TestMessageSizeLimit(t *testing.T) {
+	hub, url := newHub(t, presence.Options{MaxMessageBytes: 64})
+	conn := dial(t, url, "ada")
+	receive(t, conn)
+
+	big, _ := json.Marshal(map[string]any{"type": "cursor", "cursor": strings.Repeat("x", 100)})
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, big))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), "got %v", err)
+	require.Eventually(t, func() bool { return len(hub.Peers(1)) == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestKeepalive(t *testing.T) {
+	hub, url := newHub(t, presence.Options{PingInterval: 10 * time.Millisecond, PongTimeout: 50 * time.Millisecond})
+
+	// A client that keeps reading answers pings and stays.
+	alive := dial(t, url, "ada")
+	pings := make(chan struct{}, 10)
+	alive.SetPingHandler(func(data string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return alive.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// One that never reads never answers, and is dropped.
+	dial(t, url, "bob")
+
+	<-pings
+	require.Eventually(t, func() bool { return len(hub.Peers(1)) == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	peers := hub.Peers(1)
+	require.Len(t, peers, 1)
+	assert.Equal(t, "ada", peers[0].Name)
+}
+
+func TestClose(t *testing.T) {
+	hub, url := newHub(t, presence.Options{})
+	conn := dial(t, url, "ada")
+	receive(t, conn)
+
+	hub.Close()
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+
+	late := dial(t, url, "bob")
+	_, _, err = late.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "new connections are turned away, got %v", err)
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		session bool
+		want    bool
+	}{
+		{name: "not a browser", want: true},
+		{name: "same origin", origin: "http://HOST", session: true, want: true},
+		{name: "listed", allowed: []string{"https://cms.example"}, origin: "https://cms.example", session: true, want: true},
+		{name: "not listed", allowed: []string{"https://cms.example"}, origin: "https://evil.example"},
+		{name: "nothing listed", origin: "https://evil.example"},
+		{name: "any origin", allowed: []string{"*"}, origin: "https://evil.example", want: true},
+		{name: "any origin, signed in", allowed: []string{"*"}, origin: "https://evil.example", session: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, url := newHub(t, presence.Options{AllowedOrigins: tt.allowed})
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", strings.Replace(tt.origin, "HOST", strings.TrimPrefix(url, "ws://"), 1))
+			}
+			if tt.session {
+				header.Set("Cookie", auth.SessionCookie+"=x")
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if tt.want {
+				require.NoError(t, err)
+				conn.Close()
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
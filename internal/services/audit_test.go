@@ -0,0 +1,114 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/audit"
+	"olbcloud.com/webapi/internal/auth"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+func TestAudit(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	ps := services.NewPostService(db)
+	ctx := audit.WithRequest(auth.NewContext(context.Background(), auth.Principal{User: "alice"}),
+		audit.Request{ID: "req-1", ClientIP: "192.0.2.1"})
+
+	created, err := ps.CreatePost(ctx, models.Post{Title: "Hello", Body: "World"})
+	require.NoError(t, err)
+	_, err = ps.UpdatePost(context.Background(), models.Post{ID: created.ID, Title: "Hi", Body: "World"})
+	require.NoError(t, err)
+	id := strconv.Itoa(created.ID)
+	_, err = ps.ImportPosts(ctx, strings.NewReader(`{"id":`+id+`,"title":"Replaced","body":"b"}`+"\n"+`{"id":50,"title":"New","body":"b"}`),
+		services.ImportOptions{Mode: database.ImportUpsert, Atomic: true})
+	require.NoError(t, err)
+
+	entries, err := db.(database.AuditLog).ListAudit(context.Background(), database.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	for _, e := range entries {
+		assert.Equal(t, services.AuditEntityPost, e.Entity)
+	}
+
+	create := entries[3]
+	assert.Equal(t, "alice", create.Actor)
+	assert.Equal(t, models.AuditCreate, create.Action)
+	assert.Equal(t, id, create.EntityID)
+	assert.Equal(t, "req-1", create.RequestID)
+	assert.Equal(t, "192.0.2.1", create.ClientIP)
+	assert.Empty(t, create.Before)
+	assert.Contains(t, string(create.After), `"title":"Hello"`)
+
+	update := entries[2]
+	assert.Equal(t, audit.Anonymous, update.Actor, "changes without a principal are anonymous")
+	assert.Equal(t, models.AuditUpdate, update.Action)
+	assert.Contains(t, string(update.Before), `"title":"Hello"`)
+	assert.Contains(t, string(update.After), `"title":"Hi"`)
+
+	assert.Equal(t, models.AuditUpdate, entries[1].Action, "an upsert over a post is an update")
+	assert.Contains(t, string(entries[1].Before), `"title":"Hi"`)
+	assert.Equal(t, models.AuditCreate, entries[0].Action, "an upsert of a new ID is a creation")
+	assert.Equal(t, "50", entries[0].EntityID)
+
+	_, err = ps.UpdatePost(ctx, models.Post{ID: 99, Title: "t", Body: "b"})
+	require.ErrorIs(t, err, services.ErrPostNotFound)
+	entries, err = db.(database.AuditLog).ListAudit(context.Background(), database.AuditFilter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 4, "failed changes are not audited")
+}
+
+// auditedDB is a database whose writes are audited.
+type auditedDB interface {
+	database.DB
+	database.AuditedWriter
+}
+
+// brokenAuditLog cannot describe post changes, so it stores none.
+type brokenAuditLog struct {
+	auditedDB
+}
+
+func failAudit(before, after *models.Post) (models.AuditEntry, error) {
+	return models.AuditEntry{}, errors.New("disk full")
+}
+
+func (db brokenAuditLog) CreatePostAudited(ctx context.Context, post models.Post, _ database.AuditFunc[models.Post]) (models.Post, error) {
+	return db.auditedDB.CreatePostAudited(ctx, post, failAudit)
+}
+
+func (db brokenAuditLog) ImportPostsAudited(ctx context.Context, posts []models.Post, mode database.ImportMode, _ database.AuditFunc[models.Post]) ([]models.Post, error) {
+	return db.auditedDB.ImportPostsAudited(ctx, posts, mode, failAudit)
+}
+
+func TestAuditFailureStoresNothing(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	var events []services.Event
+	ps := services.NewPostService(brokenAuditLog{db.(auditedDB)}, func(e services.Event) error {
+		events = append(events, e)
+		return nil
+	})
+
+	_, err = ps.CreatePost(context.Background(), models.Post{Title: "t", Body: "b"})
+	assert.Error(t, err)
+	assert.Empty(t, events, "observers hear of no change")
+
+	result, err := ps.ImportPosts(context.Background(), strings.NewReader(`{"title":"a","body":"b"}`),
+		services.ImportOptions{Mode: database.ImportInsert, Atomic: true})
+	assert.Error(t, err)
+	assert.Zero(t, result.Imported)
+
+	n, err := db.CountPosts(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n, "a change that cannot be audited is not stored, so retrying it is safe")
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"io"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"olbcloud.com/webapi/internal/cache"
+	"olbcloud.com/webapi/internal/models"
+)
+
+const allPostsKey = "posts:all"
+
+// cacheMetrics is published on /debug/vars as post_cache.
+var cacheMetrics = expvar.NewMap("post_cache")
+
+type cachedPostService struct {
+	next  PostService
+	cache cache.Cache
+	ttl   time.Duration
+	group singleflight.Group
+	// generation counts invalidations, so a load that overlapped one
+	// knows its value may predate the write and must not be cached.
+	generation atomic.Uint64
+}
+
+// NewCachedPostService decorates next with a read-through cache. Reads are
+// served from c for up to ttl; concurrent misses on the same key share one
+// call to next. Writes go straight to next and then invalidate the affected
+// keys. Cache failures are logged and fall back to next.
+func NewCachedPostService(next PostService, c cache.Cache, ttl time.Duration) PostService {
+	return &cachedPostService{next: next, cache: c, ttl: ttl}
+}
+
+func (s *cachedPostService) GetPosts() ([]models.Post, error) {
+	var posts []models.Post
+	err := s.readThrough(allPostsKey, &posts, func() (interface{}, error) {
+		return s.next.GetPosts()
+	})
+	return posts, err
+}
+
+func (s *cachedPostService) GetPostByID(id string) (models.Post, error) {
+	// Only canonical integer IDs are cached so that updates can find the
+	// key to invalidate.
+	n, err := strconv.Atoi(id)
+	if err != nil || strconv.Itoa(n) != id {
+		return s.next.GetPostByID(id)
+	}
+
+	var post models.Post
+	err = s.readThrough(postKey(n), &post, func() (interface{}, error) {
+		return s.next.GetPostByID(id)
+	})
+	return post, err
+}
+
+func (s *cachedPostService) CreatePost(ctx context.Context, post models.Post) (models.Post, error) {
+	created, err := s.next.CreatePost(ctx, post)
+	if err != nil {
+		return created, err
+	}
+	s.invalidate(allPostsKey)
+	return created, nil
+}
+
+func (s *cachedPostService) UpdatePost(ctx context.Context, post models.Post) (models.Post, error) {
+	updated, err := s.next.UpdatePost(ctx, post)
+	if err != nil {
+		return updated, err
+	}
+	s.invalidate(allPostsKey, postKey(post.ID))
+	return updated, nil
+}
+
+// GetPostsByIDs is not cached; it serves batched lookups that would mostly
+// miss anyway.
+func (s *cachedPostService) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	return s.next.GetPostsByIDs(ctx, ids)
+}
+
+// ListPosts and CountPosts are not cached either: pages would need
+// invalidating on every write and each one is a single indexed query.
+func (s *cachedPostService) ListPosts(ctx context.Context, after, limit int) ([]models.Post, error) {
+	return s.next.ListPosts(ctx, after, limit)
+}
+
+func (s *cachedPostService) CountPosts(ctx context.Context) (int, error) {
+	return s.next.CountPosts(ctx)
+}
+
+func (s *cachedPostService) EachPost(ctx context.Context, fn func(models.Post) error) error {
+	return s.next.EachPost(ctx, fn)
+}
+
+func (s *cachedPostService) ExportPosts(ctx context.Context, w io.Writer) error {
+	return s.next.ExportPosts(ctx, w)
+}
+
+func (s *cachedPostService) ImportPosts(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	result, err := s.next.ImportPosts(ctx, r, opts)
+	if result.Imported > 0 {
+		keys := []string{allPostsKey}
+		for _, id := range result.IDs {
+			keys = append(keys, postKey(id))
+		}
+		s.invalidate(keys...)
+	}
+	return result, err
+}
+
+// readThrough decodes the cached value of key into dst, or loads it with
+// load, caches it and decodes that. Errors from load are not cached.
+func (s *cachedPostService) readThrough(key string, dst interface{}, load func() (interface{}, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	data, found, err := s.cache.Get(ctx, key)
+	if err != nil {
+		cacheMetrics.Add("errors", 1)
+		log.Printf("cache get %s: %v", key, err)
+	}
+	if found {
+		if err := json.Unmarshal(data, dst); err == nil {
+			cacheMetrics.Add("hits", 1)
+			return nil
+		}
+	}
+	cacheMetrics.Add("misses", 1)
+
+	v, err, _ := s.group.Do(key, func() (interface{}, error) {
+		generation := s.generation.Load()
+		value, err := load()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if s.generation.Load() != generation {
+			return data, nil
+		}
+		if err := s.cache.Set(ctx, key, data, s.ttl); err != nil {
+			cacheMetrics.Add("errors", 1)
+			log.Printf("cache set %s: %v", key, err)
+		}
+		// An invalidation between the check and the Set may have deleted
+		// the key before the stale value landed; take it out again.
+		if s.generation.Load() != generation {
+			s.delete(ctx, key)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(v.([]byte), dst)
+}
+
+// invalidate drops keys after a write. Loads already in flight may have
+// read the old value: they are forgotten so later reads load again, and
+// the generation tells them not to cache what they read.
+func (s *cachedPostService) invalidate(keys ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	s.generation.Add(1)
+	for _, key := range keys {
+		s.group.Forget(key)
+	}
+	s.delete(ctx, keys...)
+}
+
+func (s *cachedPostService) delete(ctx context.Context, keys ...string) {
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		cacheMetrics.Add("errors", 1)
+		log.Printf("cache delete %v: %v", keys, err)
+	}
+}
+
+func postKey(id int) string {
+	return "posts:id:" + strconv.Itoa(id)
+}
@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/cache"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/mocks"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+var post1 = models.Post{ID: 1, Title: "Post 1", Body: "Content 1", CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+func newCached(db database.DB) services.PostService {
+	return services.NewCachedPostService(services.NewPostService(db), cache.NewLRU(100), time.Minute)
+}
+
+func TestCachedPostServiceReadThrough(t *testing.T) {
+	db := mocks.NewDB(t)
+	db.On("GetPostByID", "1").Return(post1, nil).Once()
+	db.On("GetPosts").Return([]models.Post{post1}, nil).Once()
+	ps := newCached(db)
+
+	for i := 0; i < 3; i++ {
+		got, err := ps.GetPostByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, post1, got)
+
+		posts, err := ps.GetPosts()
+		require.NoError(t, err)
+		assert.Equal(t, []models.Post{post1}, posts)
+	}
+}
+
+func TestCachedPostServiceDoesNotCacheErrors(t *testing.T) {
+	db := mocks.NewDB(t)
+	db.On("GetPostByID", "9").Return(models.Post{}, database.ErrNotFound).Twice()
+	ps := newCached(db)
+
+	for i := 0; i < 2; i++ {
+		_, err := ps.GetPostByID("9")
+		assert.ErrorIs(t, err, services.ErrPostNotFound)
+	}
+}
+
+func TestCachedPostServiceInvalidatesOnWrite(t *testing.T) {
+	db := mocks.NewDB(t)
+	updated := post1
+	updated.Title = "Updated"
+
+	db.On("GetPostByID", "1").Return(post1, nil).Once()
+	db.On("GetPosts").Return([]models.Post{post1}, nil).Once()
+	db.On("UpdatePost", mock.AnythingOfType("models.Post")).Return(updated, nil).Once()
+	ps := newCached(db)
+
+	_, err := ps.GetPostByID("1")
+	require.NoError(t, err)
+	_, err = ps.GetPosts()
+	require.NoError(t, err)
+
+	_, err = ps.UpdatePost(context.Background(), updated)
+	require.NoError(t, err)
+
+	db.On("GetPostByID", "1").Return(updated, nil).Once()
+	db.On("GetPosts").Return([]models.Post{updated}, nil).Once()
+
+	got, err := ps.GetPostByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", got.Title)
+
+	posts, err := ps.GetPosts()
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", posts[0].Title)
+
+	db.On("CreatePost", mock.AnythingOfType("models.Post")).Return(models.Post{ID: 2}, nil).Once()
+	_, err = ps.CreatePost(context.Background(), models.Post{Title: "New", Body: "Body"})
+	require.NoError(t, err)
+
+	db.On("GetPosts").Return([]models.Post{updated, {ID: 2}}, nil).Once()
+	posts, err = ps.GetPosts()
+	require.NoError(t, err)
+	assert.Len(t, posts, 2)
+}
+
+func TestCachedPostServiceCollapsesConcurrentMisses(t *testing.T) {
+	db := mocks.NewDB(t)
+	db.On("GetPostByID", "1").After(50*time.Millisecond).Return(post1, nil).Once()
+	ps := newCached(db)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := ps.GetPostByID("1")
+			assert.NoError(t, err)
+			assert.Equal(t, post1, got)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCachedPostServiceDoesNotCacheLoadsOverlappingWrites(t *testing.T) {
+	db := mocks.NewDB(t)
+	updated := post1
+	updated.Title = "Updated"
+	started, release := make(chan struct{}), make(chan struct{})
+	db.On("GetPostByID", "1").Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	}).Return(post1, nil).Once()
+	db.On("UpdatePost", mock.AnythingOfType("models.Post")).Return(updated, nil).Once()
+	ps := newCached(db)
+
+	// The load reads the post, then the update lands and invalidates
+	// before the load gets to cache what it read.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		got, err := ps.GetPostByID("1")
+		assert.NoError(t, err)
+		assert.Equal(t, "Post 1", got.Title)
+	}()
+	<-started
+	_, err := ps.UpdatePost(context.Background(), updated)
+	require.NoError(t, err)
+	close(release)
+	<-done
+
+	db.On("GetPostByID", "1").Return(updated, nil).Once()
+	got, err := ps.GetPostByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", got.Title, "the stale read was not cached")
+}
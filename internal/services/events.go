@@ -0,0 +1,40 @@
+package services
+
+import (
+	"log"
+
+	"olbcloud.com/webapi/internal/models"
+)
+
+type EventType string
+
+const (
+	EventPostCreated EventType = models.PostCreated
+	EventPostUpdated EventType = models.PostUpdated
+	// EventPostDeleted is reserved for post deletion, which the service does
+	// not offer yet, so nothing emits it. Webhooks can already subscribe.
+	EventPostDeleted EventType = models.PostDeleted
+)
+
+// Event describes a change made through the PostService.
+type Event struct {
+	Type EventType
+	Post models.Post
+}
+
+// Observer is told about every successful change. It runs synchronously on
+// the caller's goroutine, so it should be quick and must not call back into
+// the service. An error says the event was not taken in: the outbox relay
+// then publishes it again, while the service, whose change is already made,
+// can only log it.
+type Observer func(Event) error
+
+func (ps *postService) notify(typ EventType, posts ...models.Post) {
+	for _, p := range posts {
+		for _, o := range ps.observers {
+			if err := o(Event{Type: typ, Post: p}); err != nil {
+				log.Printf("observing %s of post %d: %v", typ, p.ID, err)
+			}
+		}
+	}
+}
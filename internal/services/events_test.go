@@ -0,0 +1,44 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+func TestObservers(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	var events []services.Event
+	ps := services.NewPostService(db, func(e services.Event) error {
+		events = append(events, e)
+		return nil
+	})
+
+	created, err := ps.CreatePost(context.Background(), models.Post{Title: "t", Body: "b"})
+	require.NoError(t, err)
+	_, err = ps.UpdatePost(context.Background(), models.Post{ID: created.ID, Title: "t2", Body: "b2"})
+	require.NoError(t, err)
+	_, err = ps.UpdatePost(context.Background(), models.Post{ID: 99, Title: "t", Body: "b"})
+	require.ErrorIs(t, err, services.ErrPostNotFound)
+	_, err = ps.ImportPosts(context.Background(),
+		strings.NewReader(`{"id":5,"title":"t","body":"b"}`+"\n"),
+		services.ImportOptions{Mode: database.ImportUpsert})
+	require.NoError(t, err)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, services.EventPostCreated, events[0].Type)
+	assert.Equal(t, created, events[0].Post)
+	assert.Equal(t, services.EventPostUpdated, events[1].Type)
+	assert.Equal(t, "t2", events[1].Post.Title)
+	assert.Equal(t, services.EventPostUpdated, events[2].Type)
+	assert.Equal(t, 5, events[2].Post.ID)
+}
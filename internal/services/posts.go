@@ -1,27 +1,48 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"io"
 
+	"olbcloud.com/webapi/internal/audit"
 	"olbcloud.com/webapi/internal/database"
 	"olbcloud.com/webapi/internal/models"
 )
 
 var ErrPostNotFound = errors.New("the requested post was not found")
 
+// AuditEntityPost is the entity of audit entries about posts.
+const AuditEntityPost = "post"
+
 type PostService interface {
 	GetPosts() ([]models.Post, error)
 	GetPostByID(id string) (models.Post, error)
-	CreatePost(post models.Post) (models.Post, error)
-	UpdatePost(post models.Post) (models.Post, error)
+	CreatePost(ctx context.Context, post models.Post) (models.Post, error)
+	UpdatePost(ctx context.Context, post models.Post) (models.Post, error)
+	GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error)
+	ListPosts(ctx context.Context, after, limit int) ([]models.Post, error)
+	CountPosts(ctx context.Context) (int, error)
+	EachPost(ctx context.Context, fn func(models.Post) error) error
+	ExportPosts(ctx context.Context, w io.Writer) error
+	ImportPosts(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error)
 }
 
 type postService struct {
-	db database.DB
+	db        database.DB
+	audit     database.AuditedWriter
+	observers []Observer
 }
 
-func NewPostService(db database.DB) PostService {
-	return &postService{db: db}
+// NewPostService returns a PostService backed by db. Observers are told
+// about every post created, updated or imported. When db keeps an audit
+// log every one of those changes is recorded in it, in the transaction
+// making the change, with the actor and request taken from the context,
+// whichever API made it.
+func NewPostService(db database.DB, observers ...Observer) PostService {
+	ps := &postService{db: db, observers: observers}
+	ps.audit, _ = db.(database.AuditedWriter)
+	return ps
 }
 
 // GetPosts returns all posts
@@ -46,12 +67,28 @@ func (ps *postService) GetPostByID(id string) (models.Post, error) {
 	return post, nil
 }
 
-func (ps *postService) CreatePost(post models.Post) (models.Post, error) {
-	return ps.db.CreatePost(post)
+func (ps *postService) CreatePost(ctx context.Context, post models.Post) (models.Post, error) {
+	var err error
+	if ps.audit != nil {
+		post, err = ps.audit.CreatePostAudited(context.WithoutCancel(ctx), post, describePosts(ctx))
+	} else {
+		post, err = ps.db.CreatePost(post)
+	}
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	ps.notify(EventPostCreated, post)
+	return post, nil
 }
 
-func (ps *postService) UpdatePost(post models.Post) (models.Post, error) {
-	post, err := ps.db.UpdatePost(post)
+func (ps *postService) UpdatePost(ctx context.Context, post models.Post) (models.Post, error) {
+	var err error
+	if ps.audit != nil {
+		post, err = ps.audit.UpdatePostAudited(context.WithoutCancel(ctx), post, describePosts(ctx))
+	} else {
+		post, err = ps.db.UpdatePost(post)
+	}
 	if err != nil {
 		if err == database.ErrNotFound {
 			return models.Post{}, ErrPostNotFound
@@ -59,5 +96,32 @@ func (ps *postService) UpdatePost(post models.Post) (models.Post, error) {
 		return models.Post{}, err
 	}
 
+	ps.notify(EventPostUpdated, post)
 	return post, nil
 }
+
+// describePosts describes the post changes made in ctx for the audit log.
+// CreatePost and UpdatePost write without ctx's cancellation, like the
+// unaudited writes, which take no context.
+func describePosts(ctx context.Context) database.AuditFunc[models.Post] {
+	return audit.Func(ctx, AuditEntityPost, func(p models.Post) int { return p.ID })
+}
+
+// GetPostsByIDs returns the posts that exist among ids, in ID order.
+func (ps *postService) GetPostsByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	return ps.db.GetPostsByIDs(ctx, ids)
+}
+
+// ListPosts returns up to limit posts after the ID after, in ID order.
+func (ps *postService) ListPosts(ctx context.Context, after, limit int) ([]models.Post, error) {
+	return ps.db.ListPosts(ctx, after, limit)
+}
+
+func (ps *postService) CountPosts(ctx context.Context) (int, error) {
+	return ps.db.CountPosts(ctx)
+}
+
+// EachPost streams every post in ID order, stopping at fn's first error.
+func (ps *postService) EachPost(ctx context.Context, fn func(models.Post) error) error {
+	return ps.db.EachPost(ctx, fn)
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+)
+
+// maxImportLine bounds a single NDJSON line.
+const maxImportLine = 4 << 20
+
+var ErrInvalidImportMode = errors.New("import mode must be 'insert' or 'upsert'")
+
+// ImportOptions controls ImportPosts.
+type ImportOptions struct {
+	Mode database.ImportMode
+	// Atomic stores either every line or, if any line fails, none.
+	// Without it each valid line is stored on its own.
+	Atomic bool
+}
+
+// ImportResult reports the outcome of an import.
+type ImportResult struct {
+	Imported int               `json:"imported"`
+	IDs      []int             `json:"ids"`
+	Errors   []ImportLineError `json:"errors"`
+}
+
+// ImportLineError describes why a line was not imported. Line is 1-based.
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ExportPosts writes every post to w as newline-delimited JSON.
+func (ps *postService) ExportPosts(ctx context.Context, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return ps.db.EachPost(ctx, func(p models.Post) error {
+		return enc.Encode(p)
+	})
+}
+
+// ImportPosts reads newline-delimited JSON posts from r. Lines that fail to
+// parse or validate are reported in the result instead of aborting the
+// import; the returned error is for failures of the import as a whole.
+func (ps *postService) ImportPosts(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	if opts.Mode != database.ImportInsert && opts.Mode != database.ImportUpsert {
+		return ImportResult{}, ErrInvalidImportMode
+	}
+
+	result := ImportResult{IDs: []int{}, Errors: []ImportLineError{}}
+	var batch []models.Post
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		post, err := parseImportLine(scanner.Bytes(), opts.Mode)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportLineError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		if opts.Atomic {
+			batch = append(batch, post)
+			continue
+		}
+		stored, err := ps.importPosts(ctx, []models.Post{post}, opts.Mode)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportLineError{Line: line, Error: err.Error()})
+			continue
+		}
+		result.add(stored)
+		ps.notifyImported(opts.Mode, stored)
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("line %d: %w", line+1, err)
+	}
+
+	if !opts.Atomic || len(result.Errors) > 0 || len(batch) == 0 {
+		return result, nil
+	}
+
+	stored, err := ps.importPosts(ctx, batch, opts.Mode)
+	if err != nil {
+		return result, err
+	}
+	result.add(stored)
+	ps.notifyImported(opts.Mode, stored)
+	return result, nil
+}
+
+// importPosts stores posts and records them in the audit log in the same
+// transaction: as updates of the posts an upsert replaced and otherwise as
+// creations.
+func (ps *postService) importPosts(ctx context.Context, posts []models.Post, mode database.ImportMode) ([]models.Post, error) {
+	if ps.audit == nil {
+		return ps.db.ImportPosts(ctx, posts, mode)
+	}
+	return ps.audit.ImportPostsAudited(ctx, posts, mode, describePosts(ctx))
+}
+
+// notifyImported reports inserted posts as created and upserted ones as
+// updated, since an upsert may overwrite an existing post.
+func (ps *postService) notifyImported(mode database.ImportMode, posts []models.Post) {
+	if mode == database.ImportInsert {
+		ps.notify(EventPostCreated, posts...)
+		return
+	}
+	ps.notify(EventPostUpdated, posts...)
+}
+
+func (r *ImportResult) add(posts []models.Post) {
+	for _, p := range posts {
+		r.IDs = append(r.IDs, p.ID)
+	}
+	r.Imported += len(posts)
+}
+
+func parseImportLine(data []byte, mode database.ImportMode) (models.Post, error) {
+	var post models.Post
+	if err := json.Unmarshal(data, &post); err != nil {
+		return models.Post{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := post.Validate(); err != nil {
+		return models.Post{}, errors.New("missing required fields")
+	}
+	if mode == database.ImportUpsert && post.ID <= 0 {
+		return models.Post{}, errors.New("upsert requires a positive id")
+	}
+	return post, nil
+}
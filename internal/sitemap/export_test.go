@@ -0,0 +1,6 @@
+package sitemap
+
+// SetPageSize lets tests split the sitemap without 50k posts.
+func (s *Sitemap) SetPageSize(n int) {
+	s.pageSize = n
+}
@@ -0,0 +1,244 @@
+// Package sitemap keeps an XML sitemap of every post up to date as posts
+// change, splitting it into a sitemap index when it outgrows one file.
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"olbcloud.com/webapi/internal/models"
+)
+
+// MaxURLs is the most URLs the sitemap protocol allows in one file.
+const MaxURLs = 50000
+
+const xmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Loader streams every post, for example database.DB.EachPost.
+type Loader func(ctx context.Context, fn func(models.Post) error) error
+
+type entry struct {
+	id      int
+	lastmod time.Time
+}
+
+// Sitemap holds one entry per post, ordered by ID, and caches each rendered
+// page until an entry on it changes. It loads the posts on first use and is
+// kept current afterwards through Set.
+type Sitemap struct {
+	baseURL  string
+	load     Loader
+	pageSize int
+
+	mu      sync.Mutex
+	loaded  bool
+	entries []entry
+	pages   map[int][]byte
+}
+
+// New creates a sitemap whose URLs start with baseURL (no trailing slash).
+func New(baseURL string, load Loader) *Sitemap {
+	return &Sitemap{baseURL: baseURL, load: load, pageSize: MaxURLs, pages: map[int][]byte{}}
+}
+
+// Set records that a post exists and when it last changed. Posts are never
+// removed, as the API cannot delete them.
+func (s *Sitemap) Set(id int, lastmod time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Before the first load there is nothing to update; the load will see
+	// the change.
+	if !s.loaded {
+		return
+	}
+
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].id >= id })
+	if i < len(s.entries) && s.entries[i].id == id {
+		s.entries[i].lastmod = lastmod
+		delete(s.pages, i/s.pageSize)
+		return
+	}
+
+	// New IDs are normally the highest, so this is usually an append that
+	// only invalidates the last page.
+	s.entries = append(s.entries, entry{})
+	copy(s.entries[i+1:], s.entries[i:])
+	s.entries[i] = entry{id: id, lastmod: lastmod}
+	for page := i / s.pageSize; page <= (len(s.entries)-1)/s.pageSize; page++ {
+		delete(s.pages, page)
+	}
+}
+
+// Index renders /sitemap.xml: the URL set itself when every post fits in one
+// file, otherwise an index of the pages served by Page.
+func (s *Sitemap) Index(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+	if len(s.entries) <= s.pageSize {
+		return s.page(0), nil
+	}
+
+	idx := sitemapIndex{NS: xmlns}
+	for page := 0; page*s.pageSize < len(s.entries); page++ {
+		idx.Sitemaps = append(idx.Sitemaps, sitemapRef{
+			Loc:     s.baseURL + "/sitemaps/" + PageName(page+1),
+			LastMod: formatTime(s.pageLastMod(page)),
+		})
+	}
+	return marshal(idx), nil
+}
+
+// Page renders the n-th (1-based) page of a split sitemap. ok is false when
+// there is no such page.
+func (s *Sitemap) Page(ctx context.Context, n int) (data []byte, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoaded(ctx); err != nil {
+		return nil, false, err
+	}
+	if n < 1 || (n-1)*s.pageSize >= len(s.entries) {
+		return nil, false, nil
+	}
+	return s.page(n - 1), true, nil
+}
+
+// PageName is the file name of the n-th (1-based) page.
+func PageName(n int) string {
+	return fmt.Sprintf("sitemap-%d.xml", n)
+}
+
+// ParsePageName is the inverse of PageName.
+func ParsePageName(name string) (int, bool) {
+	num, ok := strings.CutPrefix(name, "sitemap-")
+	if !ok {
+		return 0, false
+	}
+	num, ok = strings.CutSuffix(num, ".xml")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || strconv.Itoa(n) != num {
+		return 0, false
+	}
+	return n, true
+}
+
+// Robots renders robots.txt with the given disallowed paths and a pointer
+// to the sitemap.
+func Robots(baseURL string, disallow []string) []byte {
+	var b bytes.Buffer
+	b.WriteString("User-agent: *\n")
+	if len(disallow) == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	for _, path := range disallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", baseURL)
+	return b.Bytes()
+}
+
+// ensureLoaded reads every post on first use. Callers must hold the lock.
+func (s *Sitemap) ensureLoaded(ctx context.Context) error {
+	if s.loaded {
+		return nil
+	}
+
+	var entries []entry
+	err := s.load(ctx, func(p models.Post) error {
+		entries = append(entries, entry{id: p.ID, lastmod: p.UpdatedAt})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+
+	s.entries = entries
+	s.loaded = true
+	return nil
+}
+
+// page renders a page, or returns it from the cache. Callers must hold the
+// lock.
+func (s *Sitemap) page(page int) []byte {
+	if data, ok := s.pages[page]; ok {
+		return data
+	}
+
+	set := urlSet{NS: xmlns, URLs: []url{}}
+	for _, e := range s.pageEntries(page) {
+		set.URLs = append(set.URLs, url{
+			Loc:     s.baseURL + "/posts/" + strconv.Itoa(e.id),
+			LastMod: formatTime(e.lastmod),
+		})
+	}
+	data := marshal(set)
+	s.pages[page] = data
+	return data
+}
+
+func (s *Sitemap) pageEntries(page int) []entry {
+	start := page * s.pageSize
+	end := min(start+s.pageSize, len(s.entries))
+	return s.entries[start:end]
+}
+
+func (s *Sitemap) pageLastMod(page int) time.Time {
+	var latest time.Time
+	for _, e := range s.pageEntries(page) {
+		if e.lastmod.After(latest) {
+			latest = e.lastmod
+		}
+	}
+	return latest
+}
+
+func marshal(v any) []byte {
+	// The types below always marshal.
+	data, _ := xml.MarshalIndent(v, "", "  ")
+	return append([]byte(xml.Header), data...)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	NS      string   `xml:"xmlns,attr"`
+	URLs    []url    `xml:"url"`
+}
+
+type url struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	NS       string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
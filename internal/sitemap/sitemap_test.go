@@ -0,0 +1,122 @@
+package sitemap_test
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/sitemap"
+)
+
+var base = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	URLs    []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// loader serves posts and counts how often it is called.
+func loader(posts []models.Post, calls *int) sitemap.Loader {
+	return func(ctx context.Context, fn func(models.Post) error) error {
+		*calls++
+		for _, p := range posts {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	calls := 0
+	sm := sitemap.New("https://blog.example", loader([]models.Post{
+		{ID: 2, UpdatedAt: base.Add(time.Hour)},
+		{ID: 1, UpdatedAt: base},
+	}, &calls))
+
+	// Changes before the first load are left to the load.
+	sm.Set(1, base.Add(time.Minute))
+
+	data, err := sm.Index(context.Background())
+	require.NoError(t, err)
+	var set urlSet
+	require.NoError(t, xml.Unmarshal(data, &set))
+	require.Len(t, set.URLs, 2)
+	assert.Equal(t, "https://blog.example/posts/1", set.URLs[0].Loc)
+	assert.Equal(t, "2024-05-01T12:00:00Z", set.URLs[0].LastMod)
+
+	sm.Set(3, base.Add(2*time.Hour))
+	sm.Set(1, base.Add(3*time.Hour))
+
+	data, err = sm.Index(context.Background())
+	require.NoError(t, err)
+	set = urlSet{}
+	require.NoError(t, xml.Unmarshal(data, &set))
+	require.Len(t, set.URLs, 3)
+	assert.Equal(t, "2024-05-01T15:00:00Z", set.URLs[0].LastMod)
+	assert.Equal(t, "https://blog.example/posts/3", set.URLs[2].Loc)
+	assert.Equal(t, 1, calls, "updates are applied without reloading")
+}
+
+func TestSitemapSplitsIntoIndex(t *testing.T) {
+	calls := 0
+	var posts []models.Post
+	for id := 1; id <= 5; id++ {
+		posts = append(posts, models.Post{ID: id, UpdatedAt: base})
+	}
+	sm := sitemap.New("https://blog.example", loader(posts, &calls))
+	sm.SetPageSize(2)
+
+	data, err := sm.Index(context.Background())
+	require.NoError(t, err)
+	var idx index
+	require.NoError(t, xml.Unmarshal(data, &idx))
+	require.Len(t, idx.Sitemaps, 3)
+	assert.Equal(t, "https://blog.example/sitemaps/sitemap-3.xml", idx.Sitemaps[2].Loc)
+
+	sm.Set(6, base)
+	page, ok, err := sm.Page(context.Background(), 3)
+	require.NoError(t, err)
+	require.True(t, ok)
+	var set urlSet
+	require.NoError(t, xml.Unmarshal(page, &set))
+	require.Len(t, set.URLs, 2)
+	assert.Equal(t, "https://blog.example/posts/6", set.URLs[1].Loc)
+
+	_, ok, err = sm.Page(context.Background(), 4)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestParsePageName(t *testing.T) {
+	n, ok := sitemap.ParsePageName(sitemap.PageName(12))
+	assert.True(t, ok)
+	assert.Equal(t, 12, n)
+
+	for _, name := range []string{"sitemap.xml", "sitemap-01.xml", "sitemap-x.xml", "sitemap-1.txt"} {
+		_, ok := sitemap.ParsePageName(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	assert.Equal(t,
+		"User-agent: *\nDisallow: /debug/\n\nSitemap: https://blog.example/sitemap.xml\n",
+		string(sitemap.Robots("https://blog.example", []string{"/debug/"})))
+	assert.Contains(t, string(sitemap.Robots("https://blog.example", nil)), "Disallow:\n")
+}
@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"olbcloud.com/webapi/internal/database"
+)
+
+// ErrHistoryGap is returned by History.Since when the event a client last
+// saw is no longer retained, so events after it may be missing.
+var ErrHistoryGap = errors.New("events since the given ID are no longer retained")
+
+// History keeps recent events so a reconnecting client can resume after
+// the ID it last saw.
+type History interface {
+	// Add stores e and returns it with its ID.
+	Add(ctx context.Context, e Event) (Event, error)
+	// Since returns the events after id, oldest first, or ErrHistoryGap.
+	Since(ctx context.Context, id int) ([]Event, error)
+}
+
+// Ring is an in-memory History of the latest events. IDs start from the
+// current time in microseconds, so IDs from before a restart are never
+// mistaken for new ones.
+type Ring struct {
+	mu     sync.Mutex
+	events []Event
+	start  int
+	lastID int
+}
+
+// NewRing returns a Ring holding up to size events.
+func NewRing(size int) *Ring {
+	return &Ring{events: make([]Event, 0, max(size, 1)), lastID: int(time.Now().UnixMicro())}
+}
+
+// Add assigns the next ID, replacing any ID e has.
+func (r *Ring) Add(ctx context.Context, e Event) (Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	e.ID = r.lastID
+	if len(r.events) < cap(r.events) {
+		r.events = append(r.events, e)
+	} else {
+		r.events[r.start] = e
+		r.start = (r.start + 1) % len(r.events)
+	}
+	return e, nil
+}
+
+func (r *Ring) Since(ctx context.Context, id int) ([]Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.events {
+		if r.events[(r.start+i)%len(r.events)].ID != id {
+			continue
+		}
+		since := make([]Event, 0, len(r.events)-i-1)
+		for j := i + 1; j < len(r.events); j++ {
+			since = append(since, r.events[(r.start+j)%len(r.events)])
+		}
+		return since, nil
+	}
+	return nil, ErrHistoryGap
+}
+
+// OutboxHistory resumes from the events the backend records in its outbox,
+// which survive restarts and are shared by every server. Events must come
+// from the outbox relay through Broker.Publish so they carry outbox IDs.
+// Outbox events become visible in ID order, so a client that saw an ID has
+// seen every event before it and resumes from that ID alone.
+type OutboxHistory struct {
+	store database.Outbox
+	limit int
+}
+
+// NewOutboxHistory returns a History that replays at most limit events;
+// clients further behind are told to reload.
+func NewOutboxHistory(store database.Outbox, limit int) *OutboxHistory {
+	return &OutboxHistory{store: store, limit: max(limit, 1)}
+}
+
+// Add keeps e as it is; the outbox already stored it.
+func (h *OutboxHistory) Add(ctx context.Context, e Event) (Event, error) {
+	return e, nil
+}
+
+// Since also reads event id itself, which must still be in the outbox:
+// IDs can skip numbers, so only its presence shows nothing after it was
+// purged.
+func (h *OutboxHistory) Since(ctx context.Context, id int) ([]Event, error) {
+	events, err := h.store.EventsSince(ctx, id-1, h.limit+2)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 || events[0].ID != id || len(events) > h.limit+1 {
+		return nil, ErrHistoryGap
+	}
+
+	since := make([]Event, 0, len(events)-1)
+	for _, e := range events[1:] {
+		data, err := json.Marshal(postData{Post: e.Payload})
+		if err != nil {
+			return nil, err
+		}
+		since = append(since, Event{ID: e.ID, Type: e.Type, Data: data})
+	}
+	return since, nil
+}
@@ -0,0 +1,198 @@
+// Package stream fans post changes out to Server-Sent Events clients.
+//
+// A Broker keeps a History of recent events so clients can resume with
+// Last-Event-ID, and a bounded buffer per subscriber: a client that cannot
+// keep up is disconnected rather than slowing everyone else down, and
+// catches up from the history when it reconnects.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+// ErrClosed is returned by Subscribe once the broker is closed.
+var ErrClosed = errors.New("stream closed")
+
+// Event is one message on the stream. Data is {"post": ...}.
+type Event struct {
+	ID   int
+	Type string
+	Data json.RawMessage
+}
+
+type postData struct {
+	Post json.RawMessage `json:"post"`
+}
+
+// Options tunes a Broker. Zero values fall back to the defaults below.
+type Options struct {
+	// HistorySize is how many events are kept for resuming. Defaults to
+	// 1000.
+	HistorySize int
+	// BufferSize is how many events may wait for a slow subscriber before
+	// it is disconnected. Defaults to 64.
+	BufferSize int
+	// Heartbeat is the interval of the comments that keep idle
+	// connections open through proxies. Defaults to 15 seconds.
+	Heartbeat time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.HistorySize <= 0 {
+		o.HistorySize = 1000
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = 64
+	}
+	if o.Heartbeat <= 0 {
+		o.Heartbeat = 15 * time.Second
+	}
+	return o
+}
+
+// Broker broadcasts events to subscribers.
+type Broker struct {
+	history History
+	opts    Options
+
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+}
+
+// New returns a Broker resuming from history, or from a Ring of
+// opts.HistorySize events when history is nil.
+func New(history History, opts Options) *Broker {
+	opts = opts.withDefaults()
+	if history == nil {
+		history = NewRing(opts.HistorySize)
+	}
+	return &Broker{history: history, opts: opts, subs: make(map[chan Event]struct{})}
+}
+
+// Heartbeat is the interval between heartbeat comments.
+func (b *Broker) Heartbeat() time.Duration {
+	return b.opts.Heartbeat
+}
+
+// Observe is a services.Observer for servers without an outbox.
+func (b *Broker) Observe(e services.Event) error {
+	post, err := json.Marshal(e.Post)
+	if err != nil {
+		return fmt.Errorf("stream: encoding %s: %w", e.Type, err)
+	}
+	if err := b.send(context.Background(), string(e.Type), post, 0); err != nil {
+		return fmt.Errorf("stream: %w", err)
+	}
+	return nil
+}
+
+// Publish is an outbox.EventPublisher, used with OutboxHistory.
+func (b *Broker) Publish(ctx context.Context, e models.OutboxEvent) error {
+	return b.send(ctx, e.Type, e.Payload, e.ID)
+}
+
+func (b *Broker) send(ctx context.Context, typ string, post json.RawMessage, id int) error {
+	data, err := json.Marshal(postData{Post: post})
+	if err != nil {
+		return err
+	}
+	e, err := b.history.Add(ctx, Event{ID: id, Type: typ, Data: data})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// Too slow: drop it; it resumes from the history.
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// Subscription is one client's view of the stream.
+type Subscription struct {
+	// Backlog holds the events missed since the ID passed to Subscribe.
+	Backlog []Event
+	// Reset is set when the missed events are no longer all retained, so
+	// the client has to reload.
+	Reset bool
+	// Events delivers new events. It is closed when the subscriber falls
+	// behind or the broker closes.
+	Events <-chan Event
+
+	b  *Broker
+	ch chan Event
+}
+
+// Subscribe starts receiving events. With resume set, the events after
+// lastID are returned in Backlog; live events repeating them are for the
+// caller to skip by ID.
+func (b *Broker) Subscribe(ctx context.Context, lastID int, resume bool) (*Subscription, error) {
+	ch := make(chan Event, b.opts.BufferSize)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrClosed
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	sub := &Subscription{Events: ch, b: b, ch: ch}
+	if resume {
+		backlog, err := b.history.Since(ctx, lastID)
+		switch {
+		case errors.Is(err, ErrHistoryGap):
+			sub.Reset = true
+		case err != nil:
+			sub.Close()
+			return nil, err
+		}
+		sub.Backlog = backlog
+	}
+	return sub, nil
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	if _, ok := s.b.subs[s.ch]; ok {
+		delete(s.b.subs, s.ch)
+		close(s.ch)
+	}
+}
+
+// Close ends every subscription and refuses new ones, letting stream
+// handlers return so the server can shut down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// WriteEvent writes e in the text/event-stream format.
+func WriteEvent(w io.Writer, e Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, strings.ReplaceAll(string(e.Data), "\n", "\ndata: "))
+	return err
+}
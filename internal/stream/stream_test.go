@@ -0,0 +1,160 @@
+package stream_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/stream"
+)
+
+func TestRingResumes(t *testing.T) {
+	ctx := context.Background()
+	ring := stream.NewRing(3)
+
+	var ids []int
+	for range 5 {
+		e, err := ring.Add(ctx, stream.Event{Type: models.PostCreated})
+		require.NoError(t, err)
+		ids = append(ids, e.ID)
+	}
+	for i := 1; i < len(ids); i++ {
+		assert.Equal(t, ids[i-1]+1, ids[i])
+	}
+
+	since, err := ring.Since(ctx, ids[2])
+	require.NoError(t, err)
+	require.Len(t, since, 2)
+	assert.Equal(t, ids[3], since[0].ID)
+	assert.Equal(t, ids[4], since[1].ID)
+
+	since, err = ring.Since(ctx, ids[4])
+	require.NoError(t, err)
+	assert.Empty(t, since)
+
+	_, err = ring.Since(ctx, ids[1])
+	assert.ErrorIs(t, err, stream.ErrHistoryGap, "evicted events cannot be resumed from")
+	_, err = stream.NewRing(3).Since(ctx, ids[4])
+	assert.ErrorIs(t, err, stream.ErrHistoryGap, "IDs from another ring are unknown")
+}
+
+func TestBrokerBroadcastsAndResumes(t *testing.T) {
+	ctx := context.Background()
+	b := stream.New(nil, stream.Options{})
+
+	first, err := b.Subscribe(ctx, 0, false)
+	require.NoError(t, err)
+	defer first.Close()
+
+	b.Observe(services.Event{Type: services.EventPostCreated, Post: models.Post{ID: 1, Title: "Hello"}})
+	e := <-first.Events
+	assert.Equal(t, "post.created", e.Type)
+	var data struct{ Post models.Post }
+	require.NoError(t, json.Unmarshal(e.Data, &data))
+	assert.Equal(t, "Hello", data.Post.Title)
+
+	b.Observe(services.Event{Type: services.EventPostUpdated, Post: models.Post{ID: 1, Title: "Changed"}})
+	second, err := b.Subscribe(ctx, e.ID, true)
+	require.NoError(t, err)
+	defer second.Close()
+	assert.False(t, second.Reset)
+	require.Len(t, second.Backlog, 1)
+	assert.Equal(t, "post.updated", second.Backlog[0].Type)
+
+	gap, err := b.Subscribe(ctx, 1, true)
+	require.NoError(t, err)
+	defer gap.Close()
+	assert.True(t, gap.Reset)
+	assert.Empty(t, gap.Backlog)
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := stream.New(nil, stream.Options{BufferSize: 2})
+	slow, err := b.Subscribe(context.Background(), 0, false)
+	require.NoError(t, err)
+	defer slow.Close()
+
+	for i := range 3 {
+		b.Observe(services.Event{Type: services.EventPostCreated, Post: models.Post{ID: i + 1}})
+	}
+	<-slow.Events
+	<-slow.Events
+	_, open := <-slow.Events
+	assert.False(t, open, "a subscriber that falls behind is disconnected")
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := stream.New(nil, stream.Options{})
+	sub, err := b.Subscribe(context.Background(), 0, false)
+	require.NoError(t, err)
+
+	b.Close()
+	_, open := <-sub.Events
+	assert.False(t, open)
+	sub.Close()
+
+	_, err = b.Subscribe(context.Background(), 0, false)
+	assert.ErrorIs(t, err, stream.ErrClosed)
+}
+
+// outboxStore is an in-memory database.Outbox holding events with
+// gapped IDs, as a sequence can leave them.
+type outboxStore struct {
+	database.Outbox
+	events []models.OutboxEvent
+}
+
+func (s *outboxStore) EventsSince(ctx context.Context, afterID, limit int) ([]models.OutboxEvent, error) {
+	since := []models.OutboxEvent{}
+	for _, e := range s.events {
+		if e.ID > afterID && len(since) < limit {
+			since = append(since, e)
+		}
+	}
+	return since, nil
+}
+
+func TestOutboxHistory(t *testing.T) {
+	ctx := context.Background()
+	s := &outboxStore{}
+	for _, id := range []int{3, 5, 6, 9} {
+		s.events = append(s.events, models.OutboxEvent{ID: id, Type: models.PostUpdated, Payload: json.RawMessage(`{"id":1}`)})
+	}
+	b := stream.New(stream.NewOutboxHistory(s, 2), stream.Options{})
+
+	sub, err := b.Subscribe(ctx, 5, true)
+	require.NoError(t, err)
+	defer sub.Close()
+	require.Len(t, sub.Backlog, 2)
+	assert.Equal(t, 6, sub.Backlog[0].ID)
+	assert.Equal(t, 9, sub.Backlog[1].ID)
+	assert.JSONEq(t, `{"post":{"id":1}}`, string(sub.Backlog[0].Data))
+
+	for name, id := range map[string]int{"purged": 4, "too far behind": 3} {
+		sub, err := b.Subscribe(ctx, id, true)
+		require.NoError(t, err)
+		assert.True(t, sub.Reset, name)
+		sub.Close()
+	}
+
+	require.NoError(t, b.Publish(ctx, models.OutboxEvent{ID: 10, Type: models.PostCreated, Payload: json.RawMessage(`{"id":2}`)}))
+	select {
+	case e := <-sub.Events:
+		assert.Equal(t, 10, e.ID, "published events keep their outbox ID")
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var sb strings.Builder
+	require.NoError(t, stream.WriteEvent(&sb, stream.Event{ID: 7, Type: "post.created", Data: json.RawMessage("{\n}")}))
+	assert.Equal(t, "id: 7\nevent: post.created\ndata: {\ndata: }\n\n", sb.String())
+}
@@ -0,0 +1,167 @@
+// Package tenant serves several blogs from one deployment.
+//
+// A tenant is picked by the Host header of the request, by a path prefix or
+// both, and sees only its own posts, webhooks and events through
+// database.DB.ForTenant. A tenant with neither hosts nor a prefix catches
+// the requests no other tenant matches.
+package tenant
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Tenant is one blog.
+type Tenant struct {
+	// ID scopes the tenant's data in the database, so it must not change
+	// once posts are stored under it.
+	ID string `json:"id"`
+	// Title and Description are used in the feed.
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// BaseURL is the public address of the blog, prefix included, used in
+	// the feed, sitemap and robots.txt.
+	BaseURL string `json:"base_url"`
+	// Hosts are the host names the tenant is served on, without port.
+	Hosts []string `json:"hosts"`
+	// PathPrefix, such as "/team", serves the tenant under a path. It is
+	// stripped before routing.
+	PathPrefix string `json:"path_prefix"`
+	// CORSOrigins are the origins browsers may call the API from; "*"
+	// allows any.
+	CORSOrigins []string `json:"cors_origins"`
+}
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Load reads a JSON array of tenants from path.
+func Load(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tenants, nil
+}
+
+// Resolver picks the tenant of a request.
+type Resolver struct {
+	// tenants are ordered most specific first: those with hosts, then by
+	// prefix length.
+	tenants []Tenant
+}
+
+// NewResolver checks that every tenant has a valid, unique ID and that no
+// two tenants match the same requests.
+func NewResolver(tenants []Tenant) (*Resolver, error) {
+	if len(tenants) == 0 {
+		return nil, errors.New("no tenants")
+	}
+	ids := make(map[string]bool)
+	routes := make(map[string]string)
+	for i, t := range tenants {
+		if !validID.MatchString(t.ID) {
+			return nil, fmt.Errorf("tenant %d: id %q must be lowercase letters, digits, '-' and '_'", i, t.ID)
+		}
+		if ids[t.ID] {
+			return nil, fmt.Errorf("tenant %s: duplicate id", t.ID)
+		}
+		ids[t.ID] = true
+
+		if t.PathPrefix != "" && (!strings.HasPrefix(t.PathPrefix, "/") || strings.HasSuffix(t.PathPrefix, "/")) {
+			return nil, fmt.Errorf("tenant %s: path_prefix %q must start and not end with '/'", t.ID, t.PathPrefix)
+		}
+		hosts := t.Hosts
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		for _, host := range hosts {
+			route := strings.ToLower(host) + t.PathPrefix
+			if other, ok := routes[route]; ok {
+				return nil, fmt.Errorf("tenant %s: matches the same requests as tenant %s", t.ID, other)
+			}
+			routes[route] = t.ID
+		}
+	}
+
+	sorted := slices.Clone(tenants)
+	slices.SortStableFunc(sorted, func(a, b Tenant) int {
+		if hostA, hostB := len(a.Hosts) > 0, len(b.Hosts) > 0; hostA != hostB {
+			if hostA {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(len(b.PathPrefix), len(a.PathPrefix))
+	})
+	return &Resolver{tenants: sorted}, nil
+}
+
+// Resolve returns the tenant r belongs to.
+func (res *Resolver) Resolve(r *http.Request) (Tenant, bool) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, t := range res.tenants {
+		if len(t.Hosts) > 0 && !slices.ContainsFunc(t.Hosts, func(h string) bool { return strings.EqualFold(h, host) }) {
+			continue
+		}
+		if t.PathPrefix != "" && !hasPathPrefix(r.URL.Path, t.PathPrefix) {
+			continue
+		}
+		return t, true
+	}
+	return Tenant{}, false
+}
+
+// Handler sends each request to the handler of its tenant, keyed by ID,
+// with the tenant's path prefix removed. Requests no tenant matches get a
+// 404.
+func (res *Resolver) Handler(handlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := res.Resolve(r)
+		h := handlers[t.ID]
+		if !ok || h == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unknown tenant"})
+			return
+		}
+		if t.PathPrefix != "" {
+			r = stripPrefix(r, t.PathPrefix)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// hasPathPrefix reports whether path is prefix or below it.
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// stripPrefix returns a copy of r without prefix in its path, like
+// http.StripPrefix.
+func stripPrefix(r *http.Request, prefix string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = cmp.Or(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = cmp.Or(strings.TrimPrefix(r.URL.RawPath, prefix), "/")
+	}
+	return r2
+}
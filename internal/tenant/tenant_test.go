@@ -0,0 +1,151 @@
+package tenant_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/apiserver"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/handlers"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/tenant"
+)
+
+var tenants = []tenant.Tenant{
+	{ID: "default"},
+	{ID: "team", PathPrefix: "/team"},
+	{ID: "team-docs", PathPrefix: "/team/docs"},
+	{ID: "acme", Hosts: []string{"blog.acme.test"}},
+	{ID: "acme-news", Hosts: []string{"blog.acme.test"}, PathPrefix: "/news"},
+}
+
+func TestResolve(t *testing.T) {
+	res, err := tenant.NewResolver(tenants)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		host, path, want string
+	}{
+		{"example.test", "/posts", "default"},
+		{"example.test", "/team", "team"},
+		{"example.test", "/team/posts/1", "team"},
+		{"example.test", "/teams/posts", "default"},
+		{"example.test", "/team/docs/posts", "team-docs"},
+		{"blog.acme.test", "/posts", "acme"},
+		{"BLOG.ACME.TEST:8080", "/posts", "acme"},
+		{"blog.acme.test", "/news/posts", "acme-news"},
+		{"blog.acme.test", "/team/posts", "acme"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		r.Host = tc.host
+		got, ok := res.Resolve(r)
+		require.True(t, ok, "%s%s", tc.host, tc.path)
+		assert.Equal(t, tc.want, got.ID, "%s%s", tc.host, tc.path)
+	}
+}
+
+func TestResolveWithoutCatchAll(t *testing.T) {
+	res, err := tenant.NewResolver(tenants[1:])
+	require.NoError(t, err)
+
+	h := res.Handler(map[string]http.Handler{})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"unknown tenant"}`, w.Body.String())
+}
+
+func TestNewResolverRejects(t *testing.T) {
+	for name, ts := range map[string][]tenant.Tenant{
+		"no tenants":        nil,
+		"empty id":          {{ID: ""}},
+		"invalid id":        {{ID: "Team A"}},
+		"duplicate id":      {{ID: "a", Hosts: []string{"a.test"}}, {ID: "a", Hosts: []string{"b.test"}}},
+		"relative prefix":   {{ID: "a", PathPrefix: "a"}},
+		"trailing slash":    {{ID: "a", PathPrefix: "/a/"}},
+		"two catch-alls":    {{ID: "a"}, {ID: "b"}},
+		"same host":         {{ID: "a", Hosts: []string{"x.test"}}, {ID: "b", Hosts: []string{"X.test"}}},
+		"same prefix":       {{ID: "a", PathPrefix: "/p"}, {ID: "b", PathPrefix: "/p"}},
+		"same host, prefix": {{ID: "a", Hosts: []string{"x.test"}, PathPrefix: "/p"}, {ID: "b", Hosts: []string{"x.test"}, PathPrefix: "/p"}},
+	} {
+		_, err := tenant.NewResolver(ts)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"id": "acme", "title": "Acme", "hosts": ["blog.acme.test"], "cors_origins": ["https://acme.test"]}
+	]`), 0o600))
+
+	ts, err := tenant.Load(path)
+	require.NoError(t, err)
+	require.Len(t, ts, 1)
+	assert.Equal(t, "Acme", ts[0].Title)
+	assert.Equal(t, []string{"blog.acme.test"}, ts[0].Hosts)
+	assert.Equal(t, []string{"https://acme.test"}, ts[0].CORSOrigins)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"id": "acme"}`), 0o600))
+	_, err = tenant.Load(path)
+	assert.Error(t, err)
+}
+
+// TestTenantsCannotReadEachOther serves two tenants from one database, as
+// main does, and checks neither sees the other's posts.
+func TestTenantsCannotReadEachOther(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+
+	ts := []tenant.Tenant{{ID: "acme", Hosts: []string{"blog.acme.test"}}, {ID: "team", PathPrefix: "/team"}}
+	res, err := tenant.NewResolver(ts)
+	require.NoError(t, err)
+	sites := map[string]http.Handler{}
+	for _, tt := range ts {
+		hd := handlers.NewHandlers(services.NewPostService(db.ForTenant(tt.ID)))
+		sites[tt.ID] = apiserver.NewServer(hd, apiserver.Options{})
+	}
+	h := res.Handler(sites)
+
+	do := func(method, host, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Host = host
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodPost, "blog.acme.test", "/posts", `{"title":"Acme only","body":"Secret"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct{ Post models.Post }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	id := strconv.Itoa(created.Post.ID)
+
+	w = do(http.MethodGet, "blog.acme.test", "/posts/"+id, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do(http.MethodGet, "example.test", "/team/posts/"+id, "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "another tenant cannot read the post")
+
+	w = do(http.MethodGet, "example.test", "/team/posts", "")
+	assert.NotContains(t, w.Body.String(), "Acme only", "another tenant does not list the post")
+
+	w = do(http.MethodPut, "example.test", "/team/posts/"+id, `{"title":"Taken","body":"Taken"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, "another tenant cannot update the post")
+
+	w = do(http.MethodGet, "example.test", "/posts", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "requests of no tenant are refused")
+	assert.JSONEq(t, `{"error":"unknown tenant"}`, w.Body.String())
+}
@@ -0,0 +1,401 @@
+// Package webhooks delivers post events to subscribed URLs.
+//
+// Every event is fanned out into one delivery row per subscribed webhook as
+// soon as the PostService reports it, so deliveries survive a restart. A
+// pool of workers posts the due deliveries, signed with the webhook secret,
+// and reschedules failures with exponential backoff until the retry policy
+// runs out, at which point the delivery is dead until it is redelivered.
+// Delivery is at least once: receivers should use the X-Webhook-Delivery
+// header to drop duplicates.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"olbcloud.com/webapi/internal/audit"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// AuditEntity is the entity of audit entries about webhooks.
+const AuditEntity = "webhook"
+
+// Options tunes delivery. Zero values fall back to the defaults below.
+type Options struct {
+	// Workers is the number of concurrent deliveries. Defaults to 4.
+	Workers int
+	// Retry schedules failed attempts. Retry.MaxAttempts is the total
+	// number of attempts before a delivery is dead. Defaults to 8 attempts
+	// backing off from 1 second up to 1 hour.
+	Retry database.RetryPolicy
+	// Timeout bounds each attempt. Defaults to 10 seconds.
+	Timeout time.Duration
+	// PollInterval is how often the store is checked for due deliveries
+	// when nothing wakes the dispatcher earlier. Defaults to 1 second.
+	PollInterval time.Duration
+	// Client sends the requests. Defaults to a client with Timeout.
+	Client *http.Client
+}
+
+func (o Options) withDefaults() Options {
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	if o.Retry.MaxAttempts <= 0 {
+		o.Retry.MaxAttempts = 8
+	}
+	if o.Retry.InitialBackoff <= 0 {
+		o.Retry.InitialBackoff = time.Second
+	}
+	if o.Retry.MaxBackoff <= 0 {
+		o.Retry.MaxBackoff = time.Hour
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: o.Timeout}
+	}
+	return o
+}
+
+// Dispatcher manages webhook subscriptions and delivers events to them.
+type Dispatcher struct {
+	store database.WebhookStore
+	audit database.AuditedWriter
+	opts  Options
+
+	wake chan struct{}
+	work chan models.WebhookDelivery
+
+	mu       sync.Mutex
+	inflight map[int]bool
+}
+
+// New returns a Dispatcher backed by store. Nothing is delivered until Run
+// is called. When store keeps an audit log every subscription and
+// unsubscription is recorded in it, in the transaction making it.
+func New(store database.WebhookStore, opts Options) *Dispatcher {
+	d := &Dispatcher{
+		store:    store,
+		opts:     opts.withDefaults(),
+		wake:     make(chan struct{}, 1),
+		work:     make(chan models.WebhookDelivery),
+		inflight: make(map[int]bool),
+	}
+	d.audit, _ = store.(database.AuditedWriter)
+	return d
+}
+
+// Payload is the JSON body posted for an event.
+type Payload struct {
+	Event     services.EventType `json:"event"`
+	CreatedAt time.Time          `json:"created_at"`
+	Data      PayloadData        `json:"data"`
+}
+
+type PayloadData struct {
+	Post models.Post `json:"post"`
+}
+
+// Observe is a services.Observer. It records a pending delivery for every
+// webhook subscribed to e and wakes the workers; the requests themselves are
+// made in the background. It fails if any delivery could not be recorded,
+// after recording the others, so a retried event may queue some twice.
+func (d *Dispatcher) Observe(e services.Event) error {
+	ctx := context.Background()
+
+	hooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return fmt.Errorf("webhooks: listing subscriptions for %s: %w", e.Type, err)
+	}
+
+	var payload []byte
+	var errs []error
+	for _, hook := range hooks {
+		if !hook.Subscribes(string(e.Type)) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(Payload{Event: e.Type, CreatedAt: time.Now().UTC(), Data: PayloadData{Post: e.Post}})
+			if err != nil {
+				return fmt.Errorf("webhooks: encoding %s: %w", e.Type, err)
+			}
+		}
+		_, err := d.store.CreateDelivery(ctx, models.WebhookDelivery{
+			WebhookID: hook.ID,
+			Event:     string(e.Type),
+			Payload:   payload,
+			Status:    models.DeliveryPending,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhooks: queueing %s for webhook %d: %w", e.Type, hook.ID, err))
+		}
+	}
+	d.notify()
+	return errors.Join(errs...)
+}
+
+// Run delivers pending deliveries, including those left over from a
+// previous run, until ctx is done. It waits for in-flight attempts before
+// returning.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range d.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range d.work {
+				d.attempt(ctx, delivery)
+				d.mu.Lock()
+				delete(d.inflight, delivery.ID)
+				d.mu.Unlock()
+			}
+		}()
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			close(d.work)
+			wg.Wait()
+			return
+		case <-timer.C:
+		case <-d.wake:
+			timer.Stop()
+		}
+		timer.Reset(d.dispatchDue(ctx))
+	}
+}
+
+// dispatchDue hands every due delivery that is not already being attempted
+// to the workers and returns how long to sleep before looking again.
+func (d *Dispatcher) dispatchDue(ctx context.Context) time.Duration {
+	sleep := d.opts.PollInterval
+
+	pending, err := d.store.PendingDeliveries(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("webhooks: listing pending deliveries: %v", err)
+		}
+		return sleep
+	}
+
+	now := time.Now()
+	for _, delivery := range pending {
+		if wait := delivery.NextAttemptAt.Sub(now); wait > 0 {
+			// Pending deliveries come ordered by next_attempt_at.
+			return min(sleep, wait)
+		}
+
+		d.mu.Lock()
+		busy := d.inflight[delivery.ID]
+		d.inflight[delivery.ID] = true
+		d.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		select {
+		case d.work <- delivery:
+		case <-ctx.Done():
+			return sleep
+		}
+	}
+	return sleep
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// attempt makes one request for delivery and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	hook, err := d.store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		// A deleted webhook takes its deliveries with it.
+		if !errors.Is(err, database.ErrNotFound) {
+			log.Printf("webhooks: loading webhook %d: %v", delivery.WebhookID, err)
+		}
+		return
+	}
+
+	status, err := d.post(ctx, hook, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the delivery stays pending for the next run.
+		return
+	}
+
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+	case delivery.Attempts >= d.opts.Retry.MaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = time.Now().Add(d.opts.Retry.Backoff(delivery.Attempts - 1))
+		delivery.LastError = err.Error()
+	}
+
+	if _, err := d.store.UpdateDelivery(ctx, delivery); err != nil && !errors.Is(err, database.ErrNotFound) {
+		log.Printf("webhooks: recording delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// post sends the signed payload and returns the response status, with an
+// error for anything but a 2xx.
+func (d *Dispatcher) post(ctx context.Context, hook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "blog-webhooks/1")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Subscribe stores hook, generating a secret if it has none. The returned
+// webhook is the only one that carries the secret.
+func (d *Dispatcher) Subscribe(ctx context.Context, hook models.Webhook) (models.Webhook, error) {
+	if hook.Secret == "" {
+		secret := make([]byte, 24)
+		if _, err := rand.Read(secret); err != nil {
+			return models.Webhook{}, err
+		}
+		hook.Secret = "whsec_" + hex.EncodeToString(secret)
+	}
+	if d.audit != nil {
+		return d.audit.CreateWebhookAudited(ctx, hook, describe(ctx))
+	}
+	return d.store.CreateWebhook(ctx, hook)
+}
+
+// Unsubscribe deletes a webhook and its delivery log.
+func (d *Dispatcher) Unsubscribe(ctx context.Context, id int) error {
+	var err error
+	if d.audit != nil {
+		err = d.audit.DeleteWebhookAudited(ctx, id, describe(ctx))
+	} else {
+		err = d.store.DeleteWebhook(ctx, id)
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// describe describes the webhooks subscribed and unsubscribed in ctx for
+// the audit log, leaving their secrets out.
+func describe(ctx context.Context) database.AuditFunc[models.Webhook] {
+	entry := audit.Func(ctx, AuditEntity, func(h models.Webhook) int { return h.ID })
+	return func(before, after *models.Webhook) (models.AuditEntry, error) {
+		return entry(withoutSecret(before), withoutSecret(after))
+	}
+}
+
+func withoutSecret(hook *models.Webhook) *models.Webhook {
+	if hook == nil {
+		return nil
+	}
+	redacted := *hook
+	redacted.Secret = ""
+	return &redacted
+}
+
+// Webhooks lists the subscriptions without their secrets.
+func (d *Dispatcher) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	hooks, err := d.store.ListWebhooks(ctx)
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, err
+}
+
+// Webhook returns one subscription without its secret.
+func (d *Dispatcher) Webhook(ctx context.Context, id int) (models.Webhook, error) {
+	hook, err := d.store.GetWebhook(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+	hook.Secret = ""
+	return hook, err
+}
+
+// Deliveries returns the delivery log of a webhook, newest first.
+func (d *Dispatcher) Deliveries(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error) {
+	if _, err := d.Webhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	return d.store.ListDeliveries(ctx, webhookID)
+}
+
+// Redeliver queues a delivery of the webhook again with a fresh set of
+// attempts, typically one that is dead.
+func (d *Dispatcher) Redeliver(ctx context.Context, webhookID, deliveryID int) (models.WebhookDelivery, error) {
+	delivery, err := d.store.GetDelivery(ctx, deliveryID)
+	if errors.Is(err, database.ErrNotFound) || err == nil && delivery.WebhookID != webhookID {
+		return models.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery, err = d.store.UpdateDelivery(ctx, delivery)
+	if errors.Is(err, database.ErrNotFound) {
+		return models.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	d.notify()
+	return delivery, nil
+}
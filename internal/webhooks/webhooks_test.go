@@ -0,0 +1,223 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"olbcloud.com/webapi/internal/database"
+	"olbcloud.com/webapi/internal/database/memory"
+	"olbcloud.com/webapi/internal/models"
+	"olbcloud.com/webapi/internal/services"
+	"olbcloud.com/webapi/internal/webhooks"
+)
+
+// receiver records the requests it gets and answers with the next status
+// in statuses, then 204.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []received
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, received{header: r.Header.Clone(), body: body})
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received(nil), rc.requests...)
+}
+
+func setup(t *testing.T, rc *receiver, maxAttempts int) (*webhooks.Dispatcher, database.WebhookStore, string) {
+	t.Helper()
+
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	store := db.(database.WebhookStore)
+
+	dispatcher := webhooks.New(store, webhooks.Options{
+		Workers:      2,
+		Retry:        database.RetryPolicy{MaxAttempts: maxAttempts, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+		PollInterval: 5 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return dispatcher, store, server.URL
+}
+
+func waitForStatus(t *testing.T, store database.WebhookStore, id int, status models.DeliveryStatus) models.WebhookDelivery {
+	t.Helper()
+
+	var d models.WebhookDelivery
+	require.Eventually(t, func() bool {
+		var err error
+		d, err = store.GetDelivery(context.Background(), id)
+		return err == nil && d.Status == status
+	}, 2*time.Second, 5*time.Millisecond)
+	return d
+}
+
+func TestDeliverySigned(t *testing.T) {
+	rc := &receiver{}
+	dispatcher, store, url := setup(t, rc, 3)
+
+	hook, err := dispatcher.Subscribe(context.Background(), models.Webhook{URL: url, Events: []string{"post.created"}})
+	require.NoError(t, err)
+	assert.Regexp(t, `^whsec_[0-9a-f]{48}$`, hook.Secret, "Subscribe generates a secret")
+
+	dispatcher.Observe(services.Event{Type: services.EventPostUpdated, Post: models.Post{ID: 1}})
+	dispatcher.Observe(services.Event{Type: services.EventPostCreated, Post: models.Post{ID: 1, Title: "Post 1", Body: "Content 1"}})
+
+	d := waitForStatus(t, store, 1, models.DeliverySucceeded)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusNoContent, d.ResponseStatus)
+
+	requests := rc.received()
+	require.Len(t, requests, 1, "only subscribed events are delivered")
+	req := requests[0]
+	assert.Equal(t, "post.created", req.header.Get("X-Webhook-Event"))
+	assert.Equal(t, "1", req.header.Get("X-Webhook-Delivery"))
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	require.NoError(t, webhooks.Verify(hook.Secret, req.header.Get(webhooks.SignatureHeader), req.body, time.Minute))
+	assert.ErrorIs(t, webhooks.Verify("other", req.header.Get(webhooks.SignatureHeader), req.body, time.Minute), webhooks.ErrInvalidSignature)
+
+	var payload webhooks.Payload
+	require.NoError(t, json.Unmarshal(req.body, &payload))
+	assert.Equal(t, services.EventPostCreated, payload.Event)
+	assert.Equal(t, "Post 1", payload.Data.Post.Title)
+}
+
+func TestDeliveryRetriesThenSucceeds(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	dispatcher, store, url := setup(t, rc, 5)
+
+	_, err := dispatcher.Subscribe(context.Background(), models.Webhook{URL: url, Events: []string{"post.created"}, Secret: "s"})
+	require.NoError(t, err)
+	dispatcher.Observe(services.Event{Type: services.EventPostCreated, Post: models.Post{ID: 1}})
+
+	d := waitForStatus(t, store, 1, models.DeliverySucceeded)
+	assert.Equal(t, 3, d.Attempts)
+	assert.Empty(t, d.LastError)
+	assert.Len(t, rc.received(), 3)
+}
+
+func TestDeliveryDeadLetterAndRedeliver(t *testing.T) {
+	rc := &receiver{statuses: []int{500, 500, 500}}
+	dispatcher, store, url := setup(t, rc, 3)
+	ctx := context.Background()
+
+	hook, err := dispatcher.Subscribe(ctx, models.Webhook{URL: url, Events: []string{"post.created"}, Secret: "s"})
+	require.NoError(t, err)
+	dispatcher.Observe(services.Event{Type: services.EventPostCreated, Post: models.Post{ID: 1}})
+
+	d := waitForStatus(t, store, 1, models.DeliveryDead)
+	assert.Equal(t, 3, d.Attempts)
+	assert.Equal(t, 500, d.ResponseStatus)
+	assert.Equal(t, "unexpected status 500", d.LastError)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, rc.received(), 3, "dead deliveries are not retried")
+
+	_, err = dispatcher.Redeliver(ctx, hook.ID+1, d.ID)
+	assert.ErrorIs(t, err, webhooks.ErrDeliveryNotFound)
+
+	_, err = dispatcher.Redeliver(ctx, hook.ID, d.ID)
+	require.NoError(t, err)
+	d = waitForStatus(t, store, 1, models.DeliverySucceeded)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Len(t, rc.received(), 4)
+
+	log, err := dispatcher.Deliveries(ctx, hook.ID)
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	assert.Equal(t, models.DeliverySucceeded, log[0].Status)
+}
+
+func TestSubscriptionsHideSecret(t *testing.T) {
+	dispatcher, _, url := setup(t, &receiver{}, 3)
+	ctx := context.Background()
+
+	hook, err := dispatcher.Subscribe(ctx, models.Webhook{URL: url, Events: []string{"post.created"}, Secret: "s"})
+	require.NoError(t, err)
+	assert.Equal(t, "s", hook.Secret)
+
+	got, err := dispatcher.Webhook(ctx, hook.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Secret)
+
+	hooks, err := dispatcher.Webhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	assert.Empty(t, hooks[0].Secret)
+
+	require.NoError(t, dispatcher.Unsubscribe(ctx, hook.ID))
+	assert.ErrorIs(t, dispatcher.Unsubscribe(ctx, hook.ID), webhooks.ErrWebhookNotFound)
+	_, err = dispatcher.Webhook(ctx, hook.ID)
+	assert.ErrorIs(t, err, webhooks.ErrWebhookNotFound)
+}
+
+func TestVerifyRejectsStaleSignature(t *testing.T) {
+	body := []byte(`{}`)
+	header := webhooks.Sign("s", time.Now().Add(-time.Hour), body)
+	assert.ErrorIs(t, webhooks.Verify("s", header, body, 5*time.Minute), webhooks.ErrInvalidSignature)
+	assert.NoError(t, webhooks.Verify("s", header, body, 0))
+	assert.ErrorIs(t, webhooks.Verify("s", "garbage", body, 0), webhooks.ErrInvalidSignature)
+}
+
+// failingDeliveries refuses to record deliveries.
+type failingDeliveries struct {
+	database.WebhookStore
+}
+
+func (failingDeliveries) CreateDelivery(ctx context.Context, d models.WebhookDelivery) (models.WebhookDelivery, error) {
+	return models.WebhookDelivery{}, errors.New("disk full")
+}
+
+func TestObserveFailsWhenDeliveriesCannotBeQueued(t *testing.T) {
+	db, err := memory.NewMemory("")
+	require.NoError(t, err)
+	store := db.(database.WebhookStore)
+	_, err = store.CreateWebhook(context.Background(), models.Webhook{URL: "http://example.com", Events: []string{"post.created"}, Secret: "s"})
+	require.NoError(t, err)
+
+	dispatcher := webhooks.New(failingDeliveries{store}, webhooks.Options{})
+	err = dispatcher.Observe(services.Event{Type: services.EventPostCreated, Post: models.Post{ID: 1}})
+	assert.ErrorContains(t, err, "disk full", "the outbox relay keeps the event to publish it again")
+	assert.NoError(t, dispatcher.Observe(services.Event{Type: services.EventPostUpdated, Post: models.Post{ID: 1}}),
+		"events nobody subscribes to need no delivery")
+}
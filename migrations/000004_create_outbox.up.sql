@@ -0,0 +1,9 @@
+CREATE TABLE outbox (
+    id SERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX outbox_pending ON outbox (id) WHERE dispatched_at IS NULL;
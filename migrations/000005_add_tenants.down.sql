@@ -0,0 +1,9 @@
+DROP INDEX outbox_tenant_pending;
+DROP INDEX webhook_deliveries_tenant_pending;
+DROP INDEX webhooks_tenant;
+DROP INDEX posts_tenant;
+
+ALTER TABLE outbox DROP COLUMN tenant_id;
+ALTER TABLE webhook_deliveries DROP COLUMN tenant_id;
+ALTER TABLE webhooks DROP COLUMN tenant_id;
+ALTER TABLE posts DROP COLUMN tenant_id;
@@ -0,0 +1,9 @@
+ALTER TABLE posts ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE webhooks ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_deliveries ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE outbox ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX posts_tenant ON posts (tenant_id, id);
+CREATE INDEX webhooks_tenant ON webhooks (tenant_id, id);
+CREATE INDEX webhook_deliveries_tenant_pending ON webhook_deliveries (tenant_id, next_attempt_at) WHERE status = 'pending';
+CREATE INDEX outbox_tenant_pending ON outbox (tenant_id, id) WHERE dispatched_at IS NULL;
@@ -0,0 +1,28 @@
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    at TIMESTAMP NOT NULL DEFAULT NOW(),
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    before TEXT,
+    after TEXT,
+    request_id TEXT NOT NULL,
+    client_ip TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_tenant_at ON audit_log (tenant_id, at);
+CREATE INDEX audit_log_tenant_actor ON audit_log (tenant_id, actor, at);
+CREATE INDEX audit_log_tenant_entity ON audit_log (tenant_id, entity, entity_id, at);
+
+-- The audit log is append-only, whoever connects.
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations so the server can apply them
+// without the golang-migrate binary.
+package migrations
+
+import "embed"
+
+// FS holds the NNNNNN_name.up.sql / .down.sql files in this directory.
+//
+//go:embed *.sql
+var FS embed.FS